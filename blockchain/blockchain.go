@@ -3,28 +3,54 @@ package blockchain
 import (
 	"fmt"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"github.com/umbracle/minimal/consensus"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/evm"
+	transition "github.com/umbracle/minimal/state"
 	"github.com/umbracle/minimal/storage"
 )
 
 // Blockchain is a blockchain reference
 type Blockchain struct {
 	db        *storage.Storage
+	state     state.Database
 	consensus consensus.Consensus
+	processor *transition.Processor
 	genesis   *types.Header
+
+	// head is the cached head of the chain, only updated once
+	// the data has been committed to the storage
+	head atomic.Value
+
+	// writeLock serializes the writers of the chain, it is held from
+	// the moment the head is read until the batch is committed
+	writeLock sync.Mutex
+
+	subsLock sync.Mutex
+	subs     map[*Subscription]struct{}
 }
 
 // NewBlockchain creates a new blockchain object
-func NewBlockchain(db *storage.Storage, consensus consensus.Consensus) *Blockchain {
-	return &Blockchain{db, consensus, nil}
+func NewBlockchain(db *storage.Storage, st state.Database, consensus consensus.Consensus, config *chain.Params) *Blockchain {
+	return &Blockchain{
+		db:        db,
+		state:     st,
+		consensus: consensus,
+		processor: transition.NewProcessor(config),
+		subs:      map[*Subscription]struct{}{},
+	}
 }
 
 // GetParent return the parent
-func (b *Blockchain) GetParent(header *types.Header) *types.Header {
+func (b *Blockchain) GetParent(header *types.Header) (*types.Header, error) {
 	return b.db.ReadHeader(header.ParentHash)
 }
 
@@ -35,38 +61,67 @@ func (b *Blockchain) Genesis() *types.Header {
 
 // WriteGenesis writes the genesis block if not present
 func (b *Blockchain) WriteGenesis(header *types.Header) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
 	b.genesis = header
 
-	hash := b.db.ReadHeadHash()
-	if hash != nil {
+	head, err := b.readHead(b.db)
+	if err == nil {
+		// the chain is already initialized
+		b.head.Store(head)
 		return nil
 	}
+	if err != storage.ErrNotFound {
+		return fmt.Errorf("failed to read the head: %v", err)
+	}
 
-	// add genesis block
-	if err := b.addHeader(header); err != nil {
+	// add genesis block, its total difficulty is its own difficulty
+	batch := b.db.NewBatch()
+	if err := b.addHeader(batch.Storage, header); err != nil {
+		return err
+	}
+	if err := batch.WriteDiff(header.Hash(), header.Difficulty); err != nil {
 		return err
 	}
-	if err := b.advanceHead(header); err != nil {
+	if err := b.advanceHead(batch.Storage, header); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
 		return err
 	}
 
+	b.head.Store(header)
 	return nil
 }
 
-func (b *Blockchain) advanceHead(h *types.Header) error {
-	b.db.WriteHeadHash(h.Hash())
-	b.db.WriteHeadNumber(h.Number)
+// advanceHead makes the header the head of the chain and its canonical entry
+func (b *Blockchain) advanceHead(db *storage.Storage, h *types.Header) error {
+	if err := db.WriteCanonicalHash(h.Number, h.Hash()); err != nil {
+		return err
+	}
+	if err := db.WriteHeadHash(h.Hash()); err != nil {
+		return err
+	}
+	if err := db.WriteHeadNumber(h.Number); err != nil {
+		return err
+	}
 	return nil
 }
 
+// readHead reads the head header from the storage
+func (b *Blockchain) readHead(db *storage.Storage) (*types.Header, error) {
+	hash, err := db.ReadHeadHash()
+	if err != nil {
+		return nil, err
+	}
+	return db.ReadHeader(hash)
+}
+
 // Header returns the header of the blockchain
 func (b *Blockchain) Header() *types.Header {
-	hash := b.db.ReadHeadHash()
-	if hash == nil {
-		return nil
-	}
-	header := b.db.ReadHeader(*hash)
-	return header
+	head, _ := b.head.Load().(*types.Header)
+	return head
 }
 
 // CommitChain writes all the other data related to the chain (body and receipts)
@@ -82,45 +137,101 @@ func (b *Blockchain) CommitChain(blocks []*types.Block, receipts [][]*types.Rece
 		if blocks[i].ParentHash() != blocks[i-1].Hash() {
 			return fmt.Errorf("parent hash not correct")
 		}
-		// TODO, validate bodies
 	}
 
+	for indx, block := range blocks {
+		if err := validateBody(block, receipts[indx]); err != nil {
+			return fmt.Errorf("block %d (%s) not valid: %v", block.NumberU64(), block.Hash().String(), err)
+		}
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	batch := b.db.NewBatch()
 	for indx, block := range blocks {
 		r := receipts[indx]
 
 		hash := block.Hash()
-		b.db.WriteBody(hash, block.Body())
-		b.db.WriteReceipts(hash, r)
+		if err := batch.WriteBody(hash, block.Body()); err != nil {
+			return fmt.Errorf("failed to write body %d: %v", block.NumberU64(), err)
+		}
+		if err := batch.WriteReceipts(hash, r); err != nil {
+			return fmt.Errorf("failed to write receipts %d: %v", block.NumberU64(), err)
+		}
+
+		// only the transactions of the canonical chain are indexed
+		canonical, err := batch.ReadCanonicalHash(block.Number())
+		if err != nil && err != storage.ErrNotFound {
+			return err
+		}
+		if canonical == hash {
+			if err := b.writeTxLookups(batch.Storage, block.Header()); err != nil {
+				return fmt.Errorf("failed to write tx lookups %d: %v", block.NumberU64(), err)
+			}
+		}
 	}
+	return batch.Commit()
+}
 
+// validateBody checks that the transactions, uncles and receipts match the ones in the header
+func validateBody(block *types.Block, receipts types.Receipts) error {
+	header := block.Header()
+
+	if hash := types.DeriveSha(block.Transactions()); hash != header.TxHash {
+		return fmt.Errorf("transactions root not correct: expected %s but found %s", header.TxHash.String(), hash.String())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
+		return fmt.Errorf("uncle hash not correct: expected %s but found %s", header.UncleHash.String(), hash.String())
+	}
+	if hash := types.DeriveSha(receipts); hash != header.ReceiptHash {
+		return fmt.Errorf("receipts root not correct: expected %s but found %s", header.ReceiptHash.String(), hash.String())
+	}
 	return nil
 }
 
 // GetReceiptsByHash returns the receipts by their hash
-func (b *Blockchain) GetReceiptsByHash(hash common.Hash) types.Receipts {
-	r := b.db.ReadReceipts(hash)
-	return r
+func (b *Blockchain) GetReceiptsByHash(hash common.Hash) (types.Receipts, error) {
+	return b.db.ReadReceipts(hash)
 }
 
 // GetBodyByHash returns the body by their hash
-func (b *Blockchain) GetBodyByHash(hash common.Hash) *types.Body {
+func (b *Blockchain) GetBodyByHash(hash common.Hash) (*types.Body, error) {
 	return b.db.ReadBody(hash)
 }
 
 // GetHeaderByHash returns the header by his hash
-func (b *Blockchain) GetHeaderByHash(hash common.Hash) *types.Header {
+func (b *Blockchain) GetHeaderByHash(hash common.Hash) (*types.Header, error) {
 	return b.db.ReadHeader(hash)
 }
 
+// GetTD returns the total difficulty of the chain up to the header with the given hash
+func (b *Blockchain) GetTD(hash common.Hash) (*big.Int, error) {
+	return b.db.ReadDiff(hash)
+}
+
 // GetHeaderByNumber returns the header by his number
-func (b *Blockchain) GetHeaderByNumber(n *big.Int) *types.Header {
-	hash := b.db.ReadCanonicalHash(n)
-	h := b.db.ReadHeader(hash)
-	return h
+func (b *Blockchain) GetHeaderByNumber(n *big.Int) (*types.Header, error) {
+	hash, err := b.db.ReadCanonicalHash(n)
+	if err != nil {
+		return nil, err
+	}
+	return b.db.ReadHeader(hash)
 }
 
-// WriteHeaders writes a batch of headers
+// WriteHeaders writes a batch of headers. Either all the headers, their canonical
+// entries and the new head are written or none of them.
 func (b *Blockchain) WriteHeaders(headers []*types.Header) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	parent, err := b.db.ReadHeader(headers[0].ParentHash)
+	if err == storage.ErrNotFound {
+		return fmt.Errorf("parent of %s (%d) not found", headers[0].Hash().String(), headers[0].Number.Uint64())
+	} else if err != nil {
+		return err
+	}
 
 	// validate chain
 	for i := 1; i < len(headers); i++ {
@@ -130,122 +241,631 @@ func (b *Blockchain) WriteHeaders(headers []*types.Header) error {
 		if headers[i].ParentHash != headers[i-1].Hash() {
 			return fmt.Errorf("parent hash not correct")
 		}
-		// TODO: check the first header
-		if err := b.consensus.VerifyHeader(headers[i-1], headers[i], true); err != nil {
-			return fmt.Errorf("failed to verify the header: %v", err)
-		}
+	}
+	if err := b.verifyHeaders(parent, headers); err != nil {
+		return err
 	}
 
-	// NOTE: Add headers in batches, check if the parent of the first header
-	// exists, write all the blocks and set the last block as the head.
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
 
+	// Stage all the headers in a batch, nothing reaches the storage
+	// unless every header in the batch is written correctly.
+	batch := b.db.NewBatch()
+	events := []*Event{}
 	for indx, h := range headers {
-		if err := b.WriteHeader(h); err != nil {
-			fmt.Printf("Failed at sequence: %d\n", indx)
+		evnt, err := b.writeHeader(batch.Storage, h)
+		if err != nil {
+			return fmt.Errorf("failed to write header at sequence %d (%d): %v", indx, h.Number.Uint64(), err)
+		}
+		events = append(events, evnt)
+	}
+	if err := b.commit(batch); err != nil {
+		return fmt.Errorf("failed to commit the headers batch: %v", err)
+	}
+	b.dispatch(events)
 
-			headerNumber := b.db.ReadHeadNumber()
+	return nil
+}
 
-			// head number should match the last one you just added
-			fmt.Printf("Head number is %s\n", headerNumber.String())
+// WriteBlocks executes the transactions of the blocks on top of the state of their parent
+// and writes them if the resulting state matches the one in the header. Either all the
+// blocks are written or none of them.
+func (b *Blockchain) WriteBlocks(blocks []*types.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
 
-			// rollback? we have to remove all the blocks written, cache
-			return err
+	parent, err := b.db.ReadHeader(blocks[0].ParentHash())
+	if err == storage.ErrNotFound {
+		return fmt.Errorf("parent of %s (%d) not found", blocks[0].Hash().String(), blocks[0].NumberU64())
+	} else if err != nil {
+		return err
+	}
+
+	// validate chain
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		prev := parent
+		if i != 0 {
+			prev = headers[i-1]
+		}
+		if block.NumberU64()-1 != prev.Number.Uint64() {
+			return fmt.Errorf("number sequence not correct at %d, %d and %d", i, block.NumberU64(), prev.Number.Uint64())
+		}
+		if block.ParentHash() != prev.Hash() {
+			return fmt.Errorf("parent hash not correct")
+		}
+		headers[i] = block.Header()
+	}
+	if err := b.verifyHeaders(parent, headers); err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	batch := b.db.NewBatch()
+	events := []*Event{}
+	for indx, block := range blocks {
+		if err := b.processBlock(batch.Storage, block); err != nil {
+			return fmt.Errorf("failed to process block at sequence %d (%d): %v", indx, block.NumberU64(), err)
+		}
+
+		evnt, err := b.writeHeader(batch.Storage, block.Header())
+		if err != nil {
+			return fmt.Errorf("failed to write block at sequence %d (%d): %v", indx, block.NumberU64(), err)
 		}
+		events = append(events, evnt)
+	}
+	if err := b.commit(batch); err != nil {
+		return fmt.Errorf("failed to commit the blocks batch: %v", err)
 	}
+	b.dispatch(events)
 
-	fmt.Printf("Done: last header written was %s at %s\n", headers[len(headers)-1].Hash().String(), headers[len(headers)-1].Number.String())
+	return nil
+}
 
+// verifyHeaders verifies the headers with the consensus engine, the seals
+// are checked concurrently
+func (b *Blockchain) verifyHeaders(parent *types.Header, headers []*types.Header) error {
+	abort, results := consensus.VerifyHeaders(b.consensus, parent, headers, runtime.NumCPU())
+	defer close(abort)
+
+	for _, header := range headers {
+		if err := <-results; err != nil {
+			return fmt.Errorf("failed to verify the header %d: %v", header.Number.Uint64(), err)
+		}
+	}
 	return nil
 }
 
-func (b *Blockchain) addHeader(header *types.Header) error {
-	b.db.WriteHeader(header)
-	b.db.WriteCanonicalHash(header.Number, header.Hash())
+// processBlock executes the block, validates the result and writes the state,
+// the body and the receipts
+func (b *Blockchain) processBlock(db *storage.Storage, block *types.Block) error {
+	parent, err := db.ReadHeader(block.ParentHash())
+	if err != nil {
+		return err
+	}
+
+	statedb, err := state.New(parent.Root, b.state)
+	if err != nil {
+		return fmt.Errorf("state of the parent not found: %v", err)
+	}
+
+	if err := b.consensus.VerifyUncles(db, block); err != nil {
+		return err
+	}
+
+	receipts, usedGas, err := b.processor.Process(statedb, block, b.getHashFn(db, parent))
+	if err != nil {
+		return err
+	}
+	if err := b.consensus.Finalize(statedb, block); err != nil {
+		return err
+	}
+	if err := b.processor.Validate(statedb, block, receipts, usedGas); err != nil {
+		return err
+	}
+
+	// the state is only referenced once the header is committed, so it
+	// can be written before the batch
+	root, err := statedb.Commit(b.processor.DeleteEmptyObjects(block.Number()))
+	if err != nil {
+		return fmt.Errorf("failed to commit the state: %v", err)
+	}
+	if err := b.state.TrieDB().Commit(root, false); err != nil {
+		return fmt.Errorf("failed to write the state: %v", err)
+	}
+
+	if err := db.WriteBody(block.Hash(), block.Body()); err != nil {
+		return err
+	}
+	return db.WriteReceipts(block.Hash(), receipts)
+}
+
+// BuildBlock executes the transactions on top of the state of the parent of the
+// header and returns the block with the header completed with the result (state
+// root, receipts, bloom and gas used). The block is not written.
+// The transactions that fail are not included and returned apart, the ones
+// that do not fit in the block are neither included nor returned.
+func (b *Blockchain) BuildBlock(header *types.Header, txs []*types.Transaction) (*types.Block, []*types.Transaction, error) {
+	parent, err := b.db.ReadHeader(header.ParentHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parent of %d not found: %v", header.Number.Uint64(), err)
+	}
+
+	statedb, err := state.New(parent.Root, b.state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("state of the parent not found: %v", err)
+	}
+
+	included, receipts, usedGas, failed := b.processor.Build(statedb, header, txs, b.getHashFn(b.db, parent))
+	if err := b.consensus.Finalize(statedb, types.NewBlock(header, included, nil, nil)); err != nil {
+		return nil, nil, err
+	}
+
+	header = types.CopyHeader(header)
+	header.GasUsed = usedGas
+	header.Root = statedb.IntermediateRoot(b.processor.DeleteEmptyObjects(header.Number))
+
+	return types.NewBlock(header, included, nil, receipts), failed, nil
+}
+
+// getHashFn returns the hash of the ancestors of the header by their number
+func (b *Blockchain) getHashFn(db *storage.Storage, header *types.Header) evm.GetHashByNumber {
+	cache := map[uint64]common.Hash{
+		header.Number.Uint64(): header.Hash(),
+	}
+	last := header
+
+	return func(n uint64) common.Hash {
+		if hash, ok := cache[n]; ok {
+			return hash
+		}
+		for last.Number.Uint64() > n {
+			parent, err := db.ReadHeader(last.ParentHash)
+			if err != nil {
+				return common.Hash{}
+			}
+			last = parent
+			cache[last.Number.Uint64()] = last.Hash()
+		}
+		return cache[n]
+	}
+}
+
+// commit commits the batch and updates the cached head with the one
+// written in the batch, the caller must hold the write lock
+func (b *Blockchain) commit(batch *storage.Batch) error {
+	head, err := b.readHead(batch.Storage)
+	if err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	b.head.Store(head)
 	return nil
 }
 
+func (b *Blockchain) addHeader(db *storage.Storage, header *types.Header) error {
+	return db.WriteHeader(header)
+}
+
 // WriteHeader writes a block and the data, assumes the genesis is already set
 func (b *Blockchain) WriteHeader(header *types.Header) error {
-	head := b.Header()
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	batch := b.db.NewBatch()
+	evnt, err := b.writeHeader(batch.Storage, header)
+	if err != nil {
+		return err
+	}
+	if err := b.commit(batch); err != nil {
+		return err
+	}
+	b.dispatch([]*Event{evnt})
+	return nil
+}
 
-	//fmt.Println("-- current header --")
-	//fmt.Println(b.db.ReadHeadHash().String())
+// writeHeader writes the header and updates the head of the chain. It returns
+// the event describing the change in the chain.
+func (b *Blockchain) writeHeader(db *storage.Storage, header *types.Header) (*Event, error) {
+	head, err := b.readHead(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the head: %v", err)
+	}
 
-	parent := b.db.ReadHeader(header.ParentHash)
-	if parent == nil {
-		return fmt.Errorf("parent of %s (%d) not found", header.Hash().String(), header.Number.Uint64())
+	parentTD, err := db.ReadDiff(header.ParentHash)
+	if err == storage.ErrNotFound {
+		return nil, fmt.Errorf("parent of %s (%d) not found", header.Hash().String(), header.Number.Uint64())
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the total difficulty of the parent of %s (%d): %v", header.Hash().String(), header.Number.Uint64(), err)
 	}
 
-	// local difficulty of the block
-	localDiff := big.NewInt(1).Add(parent.Difficulty, header.Difficulty)
+	headTD, err := db.ReadDiff(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the total difficulty of the head: %v", err)
+	}
+
+	// total difficulty of the chain that ends in the header
+	headerTD := big.NewInt(0).Add(parentTD, header.Difficulty)
 
 	// Write the data
-	if err := b.addHeader(header); err != nil {
-		return err
+	if err := b.addHeader(db, header); err != nil {
+		return nil, err
+	}
+	if err := db.WriteDiff(header.Hash(), headerTD); err != nil {
+		return nil, err
 	}
 
 	if header.ParentHash == head.Hash() {
 		// advance the chain
-		if err := b.advanceHead(header); err != nil {
-			return err
+		if err := b.advanceHead(db, header); err != nil {
+			return nil, err
 		}
-	} else if head.Difficulty.Cmp(localDiff) < 0 {
+		if err := b.writeTxLookups(db, header); err != nil {
+			return nil, err
+		}
+		return &Event{Type: EventHead, Header: header}, nil
+	} else if headTD.Cmp(headerTD) < 0 {
 		// reorg
-		if err := b.handleReorg(head, header); err != nil {
-			return err
+		reorg, err := b.handleReorg(db, head, header)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		// fork
-		if err := b.writeFork(header); err != nil {
-			return err
+		for _, h := range reorg.Removed {
+			if err := b.deleteTxLookups(db, h); err != nil {
+				return nil, err
+			}
+		}
+		for _, h := range reorg.Added {
+			if err := b.writeTxLookups(db, h); err != nil {
+				return nil, err
+			}
 		}
+		return &Event{Type: EventReorg, Header: header, OldChain: reorg.Removed, NewChain: reorg.Added}, nil
 	}
 
-	return nil
+	// fork
+	if err := b.updateForks(db, header.ParentHash, header.Hash()); err != nil {
+		return nil, err
+	}
+	return &Event{Type: EventFork, Header: header}, nil
 }
 
-func (b *Blockchain) writeFork(header *types.Header) error {
-	forks := b.db.ReadForks()
+// updateForks replaces the fork tip oldTip (if present) with newTip
+func (b *Blockchain) updateForks(db *storage.Storage, oldTip, newTip common.Hash) error {
+	forks, err := db.ReadForks()
+	if err != nil {
+		return err
+	}
 
 	newForks := []common.Hash{}
 	for _, fork := range forks {
-		if fork != header.ParentHash {
+		if fork != oldTip {
 			newForks = append(newForks, fork)
 		}
 	}
-	newForks = append(newForks, header.Hash())
-	b.db.WriteForks(newForks)
-	return nil
+	newForks = append(newForks, newTip)
+	return db.WriteForks(newForks)
 }
 
-func (b *Blockchain) handleReorg(oldHeader *types.Header, newHeader *types.Header) error {
+// Reorg is a change of the canonical chain to a different branch
+type Reorg struct {
+	// Ancestor is the last header both branches have in common
+	Ancestor *types.Header
+
+	// Removed are the headers that are not canonical anymore, ordered
+	// from the old head to the ancestor
+	Removed []*types.Header
+
+	// Added are the headers that are now canonical, ordered from
+	// the ancestor to the new head
+	Added []*types.Header
+}
+
+func (b *Blockchain) handleReorg(db *storage.Storage, oldHeader *types.Header, newHeader *types.Header) (*Reorg, error) {
 	newChainHead := newHeader
 	oldChainHead := oldHeader
 
+	removed := []*types.Header{}
+	added := []*types.Header{}
+
+	var err error
 	for oldHeader.Number.Cmp(newHeader.Number) > 0 {
-		oldHeader = b.db.ReadHeader(oldHeader.ParentHash)
+		removed = append(removed, oldHeader)
+		if oldHeader, err = db.ReadHeader(oldHeader.ParentHash); err != nil {
+			return nil, err
+		}
 	}
 
 	for newHeader.Number.Cmp(oldHeader.Number) > 0 {
-		newHeader = b.db.ReadHeader(newHeader.ParentHash)
+		added = append(added, newHeader)
+		if newHeader, err = db.ReadHeader(newHeader.ParentHash); err != nil {
+			return nil, err
+		}
 	}
 
 	for oldHeader.Hash() != newHeader.Hash() {
-		oldHeader = b.db.ReadHeader(oldHeader.ParentHash)
-		newHeader = b.db.ReadHeader(newHeader.ParentHash)
+		removed = append(removed, oldHeader)
+		added = append(added, newHeader)
+
+		if oldHeader, err = db.ReadHeader(oldHeader.ParentHash); err != nil {
+			return nil, err
+		}
+		if newHeader, err = db.ReadHeader(newHeader.ParentHash); err != nil {
+			return nil, err
+		}
+	}
+
+	// added was collected from the new head backwards
+	for i, j := 0, len(added)-1; i < j; i, j = i+1, j-1 {
+		added[i], added[j] = added[j], added[i]
+	}
+
+	// the old head becomes a fork and the new branch is not a fork anymore
+	if err := b.updateForks(db, newChainHead.ParentHash, oldChainHead.Hash()); err != nil {
+		return nil, fmt.Errorf("failed to write the old header as fork: %v", err)
+	}
+
+	// rewrite the canonical chain with the new branch and remove
+	// the entries of the old branch past the new head
+	for _, h := range added {
+		if err := db.WriteCanonicalHash(h.Number, h.Hash()); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range removed {
+		if h.Number.Cmp(newChainHead.Number) > 0 {
+			if err := db.DeleteCanonicalHash(h.Number); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := b.advanceHead(db, newChainHead); err != nil {
+		return nil, err
+	}
+
+	reorg := &Reorg{
+		Ancestor: oldHeader,
+		Removed:  removed,
+		Added:    added,
+	}
+	return reorg, nil
+}
+
+// SetHead rewinds the canonical chain to the given number. The canonical entries,
+// headers, total difficulty, bodies and receipts of the blocks above it are removed,
+// along with the forks that branch off the removed blocks.
+func (b *Blockchain) SetHead(number uint64) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	head := b.Header()
+	if head == nil {
+		return fmt.Errorf("the chain is empty")
+	}
+	if number > head.Number.Uint64() {
+		return fmt.Errorf("cannot set the head to %d, the current head is %d", number, head.Number.Uint64())
+	}
+
+	newHead, err := b.GetHeaderByNumber(new(big.Int).SetUint64(number))
+	if err != nil {
+		return fmt.Errorf("failed to read the header %d: %v", number, err)
+	}
+
+	batch := b.db.NewBatch()
+	removed := []*types.Header{}
+	for n := head.Number.Uint64(); n > number; n-- {
+		header, err := b.GetHeaderByNumber(new(big.Int).SetUint64(n))
+		if err != nil {
+			return fmt.Errorf("failed to read the header %d: %v", n, err)
+		}
+		if err := b.deleteTxLookups(batch.Storage, header); err != nil {
+			return err
+		}
+		if err := batch.DeleteCanonicalHash(header.Number); err != nil {
+			return err
+		}
+		if err := b.deleteBlock(batch.Storage, header); err != nil {
+			return fmt.Errorf("failed to delete block %d: %v", n, err)
+		}
+		removed = append(removed, header)
+	}
+	if err := b.pruneForks(batch.Storage, number, removed); err != nil {
+		return fmt.Errorf("failed to prune the forks: %v", err)
+	}
+	if err := b.advanceHead(batch.Storage, newHead); err != nil {
+		return err
+	}
+	if err := b.commit(batch); err != nil {
+		return err
+	}
+
+	if len(removed) != 0 {
+		b.dispatch([]*Event{{Type: EventReorg, Header: newHead, OldChain: removed, NewChain: []*types.Header{}}})
+	}
+	return nil
+}
+
+// pruneForks removes the forks that branch off the removed canonical headers,
+// the forks that branch off at or below the number are kept
+func (b *Blockchain) pruneForks(db *storage.Storage, number uint64, removed []*types.Header) error {
+	forks, err := db.ReadForks()
+	if err != nil {
+		return err
 	}
 
-	if err := b.writeFork(oldChainHead); err != nil {
-		return fmt.Errorf("failed to write the old header as fork: %v", err)
+	removedHashes := map[common.Hash]struct{}{}
+	for _, h := range removed {
+		removedHashes[h.Hash()] = struct{}{}
 	}
 
-	// NOTE. this loops are used to know the oldblocks not belonging anymore
-	// to the canonical chain and updating the tx and state
+	newForks := []common.Hash{}
+	for _, fork := range forks {
+		header, err := db.ReadHeader(fork)
+		if err != nil {
+			return err
+		}
+
+		// walk the fork back until it reaches the kept chain or a removed block
+		branch := []*types.Header{}
+		orphan := false
+		for header.Number.Uint64() > number {
+			branch = append(branch, header)
+			if _, ok := removedHashes[header.ParentHash]; ok {
+				orphan = true
+				break
+			}
+			if header, err = db.ReadHeader(header.ParentHash); err != nil {
+				return err
+			}
+		}
 
-	return b.advanceHead(newChainHead)
+		if !orphan {
+			newForks = append(newForks, fork)
+			continue
+		}
+
+		// the fork is not canonical, it does not have canonical entries
+		// nor transaction lookups
+		for _, h := range branch {
+			if err := b.deleteBlock(db, h); err != nil {
+				return err
+			}
+			// other forks may branch off this one
+			removedHashes[h.Hash()] = struct{}{}
+		}
+	}
+	return db.WriteForks(newForks)
+}
+
+// deleteBlock removes the header, total difficulty, body and receipts of a block
+func (b *Blockchain) deleteBlock(db *storage.Storage, header *types.Header) error {
+	hash := header.Hash()
+
+	if err := db.DeleteHeader(hash); err != nil {
+		return err
+	}
+	if err := db.DeleteDiff(hash); err != nil {
+		return err
+	}
+	if err := db.DeleteBody(hash); err != nil {
+		return err
+	}
+	return db.DeleteReceipts(hash)
+}
+
+// writeTxLookups indexes the transactions of the block if its body is known
+func (b *Blockchain) writeTxLookups(db *storage.Storage, header *types.Header) error {
+	body, err := db.ReadBody(header.Hash())
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for indx, tx := range body.Transactions {
+		entry := &storage.TxLookupEntry{
+			BlockHash:   header.Hash(),
+			BlockNumber: header.Number.Uint64(),
+			Index:       uint64(indx),
+		}
+		if err := db.WriteTxLookup(tx.Hash(), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTxLookups removes the index of the transactions of the block
+func (b *Blockchain) deleteTxLookups(db *storage.Storage, header *types.Header) error {
+	body, err := db.ReadBody(header.Hash())
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, tx := range body.Transactions {
+		if err := db.DeleteTxLookup(tx.Hash()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBlockByHash returns the block with the given hash
+func (b *Blockchain) GetBlockByHash(hash common.Hash) (*types.Block, error) {
+	header, err := b.db.ReadHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := b.db.ReadBody(hash)
+	if err == storage.ErrNotFound {
+		// blocks without transactions and uncles do not need a body
+		if header.TxHash != types.EmptyRootHash || header.UncleHash != types.EmptyUncleHash {
+			return nil, err
+		}
+		body = &types.Body{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
+}
+
+// GetBlockByNumber returns the block of the canonical chain with the given number
+func (b *Blockchain) GetBlockByNumber(n *big.Int) (*types.Block, error) {
+	hash, err := b.db.ReadCanonicalHash(n)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetBlockByHash(hash)
+}
+
+// GetTransactionByHash returns the transaction of the canonical chain with the
+// given hash and its position
+func (b *Blockchain) GetTransactionByHash(hash common.Hash) (*types.Transaction, *storage.TxLookupEntry, error) {
+	entry, err := b.db.ReadTxLookup(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := b.db.ReadBody(entry.BlockHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.Index >= uint64(len(body.Transactions)) {
+		return nil, nil, fmt.Errorf("transaction index %d not found in block %s", entry.Index, entry.BlockHash.String())
+	}
+	return body.Transactions[entry.Index], entry, nil
+}
+
+// GetReceiptByTxHash returns the receipt of the transaction of the canonical
+// chain with the given hash
+func (b *Blockchain) GetReceiptByTxHash(hash common.Hash) (*types.Receipt, error) {
+	entry, err := b.db.ReadTxLookup(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts, err := b.db.ReadReceipts(entry.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Index >= uint64(len(receipts)) {
+		return nil, fmt.Errorf("receipt index %d not found in block %s", entry.Index, entry.BlockHash.String())
+	}
+	return receipts[entry.Index], nil
 }
 
 // GetForks returns the forks
-func (b *Blockchain) GetForks() []common.Hash {
+func (b *Blockchain) GetForks() ([]common.Hash, error) {
 	return b.db.ReadForks()
 }
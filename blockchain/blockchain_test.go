@@ -4,10 +4,15 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/umbracle/minimal/consensus/ethash"
+	"github.com/umbracle/minimal/storage"
 )
 
 func TestGenesis(t *testing.T) {
@@ -30,11 +35,11 @@ func TestGenesis(t *testing.T) {
 	}
 }
 
-type chain struct {
+type mockChain struct {
 	headers map[byte]*types.Header
 }
 
-func (c *chain) add(h *header) error {
+func (c *mockChain) add(h *header) error {
 	if _, ok := c.headers[h.hash]; ok {
 		return fmt.Errorf("hash already imported")
 	}
@@ -137,6 +142,18 @@ func TestInsertHeaders(t *testing.T) {
 			Head:  mock(0x5),
 			Forks: []*header{mock(0x6)},
 		},
+		{
+			Name: "Keep chain with higher total difficulty",
+			History: []*header{
+				mock(0x0),
+				mock(0x1),
+				mock(0x2),
+				mock(0x3),
+				mock(0x4).Parent(0x1).Diff(4).Number(2), // 1+4 is lower than 1+2+3
+			},
+			Head:  mock(0x3),
+			Forks: []*header{mock(0x4)},
+		},
 		{
 			Name: "Forks in reorgs",
 			History: []*header{
@@ -157,7 +174,7 @@ func TestInsertHeaders(t *testing.T) {
 		t.Run(cc.Name, func(tt *testing.T) {
 			b := NewTestBlockchain(t, nil)
 
-			chain := chain{
+			chain := mockChain{
 				headers: map[byte]*types.Header{},
 			}
 			for _, i := range cc.History {
@@ -189,7 +206,10 @@ func TestInsertHeaders(t *testing.T) {
 				tt.Fatal("bad2")
 			}
 
-			forks := b.GetForks()
+			forks, err := b.GetForks()
+			if err != nil {
+				tt.Fatal(err)
+			}
 			expectedForks := []common.Hash{}
 
 			for _, i := range cc.Forks {
@@ -209,6 +229,24 @@ func TestInsertHeaders(t *testing.T) {
 	}
 }
 
+func TestTotalDifficulty(t *testing.T) {
+	headers := NewTestHeaderChain(10)
+	b := NewTestBlockchain(t, headers)
+
+	td := big.NewInt(0)
+	for _, h := range headers {
+		td.Add(td, h.Difficulty)
+
+		found, err := b.GetTD(h.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Cmp(td) != 0 {
+			t.Fatalf("total difficulty at %d: expected %s but found %s", h.Number.Uint64(), td.String(), found.String())
+		}
+	}
+}
+
 func TestCommitChain(t *testing.T) {
 	// test if the data written in commitchain is retrieved correctly
 
@@ -223,7 +261,10 @@ func TestCommitChain(t *testing.T) {
 		block := blocks[i]
 
 		// check blocks
-		i := b.db.ReadBody(block.Hash())
+		i, err := b.db.ReadBody(block.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
 		if len(i.Transactions) != 1 {
 			t.Fatal("should have 1 tx")
 		}
@@ -232,7 +273,10 @@ func TestCommitChain(t *testing.T) {
 		}
 
 		// check receipts
-		r := b.db.ReadReceipts(block.Hash())
+		r, err := b.db.ReadReceipts(block.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
 		if len(r) != 1 {
 			t.Fatal("should have 1 receipt")
 		}
@@ -241,3 +285,440 @@ func TestCommitChain(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteHeadersRollback(t *testing.T) {
+	headers := NewTestHeaderChain(10)
+	b := NewTestBlockchain(t, headers[:5])
+
+	head := b.Header()
+
+	// headers[6] is not linked with the local chain
+	if err := b.WriteHeaders(headers[6:]); err == nil {
+		t.Fatal("it should fail")
+	}
+
+	if b.Header().Hash() != head.Hash() {
+		t.Fatal("the head should not have moved")
+	}
+	for _, h := range headers[6:] {
+		if _, err := b.GetHeaderByHash(h.Hash()); err != storage.ErrNotFound {
+			t.Fatalf("header %d should not be written", h.Number.Uint64())
+		}
+		if _, err := b.db.ReadCanonicalHash(h.Number); err != storage.ErrNotFound {
+			t.Fatalf("canonical hash %d should not be written", h.Number.Uint64())
+		}
+	}
+}
+
+func TestConcurrentWriteHeaders(t *testing.T) {
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+	b := NewTestBlockchain(t, []*types.Header{genesis})
+
+	// every branch starts in the genesis and has a different difficulty,
+	// the last one has the highest total difficulty
+	branches := [][]*types.Header{}
+	for i := 1; i <= 4; i++ {
+		branch := []*types.Header{}
+		parent := genesis
+		for j := 1; j <= 50; j++ {
+			header := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     big.NewInt(int64(j)),
+				Difficulty: big.NewInt(int64(i)),
+			}
+			branch = append(branch, header)
+			parent = header
+		}
+		branches = append(branches, branch)
+	}
+
+	var wg sync.WaitGroup
+	for _, branch := range branches {
+		wg.Add(1)
+		go func(branch []*types.Header) {
+			defer wg.Done()
+			for i := 0; i < len(branch); i += 5 {
+				if err := b.WriteHeaders(branch[i : i+5]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(branch)
+	}
+	wg.Wait()
+
+	expected := branches[len(branches)-1]
+	if b.Header().Hash() != expected[len(expected)-1].Hash() {
+		t.Fatal("the head should be the branch with the highest total difficulty")
+	}
+	for _, h := range expected {
+		hash, err := b.db.ReadCanonicalHash(h.Number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != h.Hash() {
+			t.Fatalf("bad canonical hash at %d", h.Number.Uint64())
+		}
+	}
+}
+
+func TestGetHeaderNotFound(t *testing.T) {
+	b := NewTestBlockchain(t, NewTestHeaderChain(5))
+
+	if _, err := b.GetHeaderByNumber(big.NewInt(10)); err != storage.ErrNotFound {
+		t.Fatalf("expected not found but found %v", err)
+	}
+	if _, err := b.GetHeaderByHash(common.HexToHash("1")); err != storage.ErrNotFound {
+		t.Fatalf("expected not found but found %v", err)
+	}
+}
+
+func TestReorgCanonicalChain(t *testing.T) {
+	b := NewTestBlockchain(t, nil)
+
+	c := mockChain{
+		headers: map[byte]*types.Header{},
+	}
+	history := []*header{
+		mock(0x0),
+		mock(0x1),
+		mock(0x2),
+		mock(0x3),
+		mock(0x4).Parent(0x1).Diff(10).Number(2), // shorter chain with higher difficulty
+	}
+	for _, i := range history {
+		if err := c.add(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.WriteGenesis(c.headers[0x0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range history[1:4] {
+		if err := b.WriteHeader(c.headers[i.hash]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch := b.db.NewBatch()
+	evnt, err := b.writeHeader(batch.Storage, c.headers[0x4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.commit(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if evnt.Type != EventReorg {
+		t.Fatalf("expected a reorg but found %s", evnt.Type)
+	}
+	if len(evnt.OldChain) != 2 || evnt.OldChain[0].Hash() != c.headers[0x3].Hash() || evnt.OldChain[1].Hash() != c.headers[0x2].Hash() {
+		t.Fatal("bad removed headers")
+	}
+	if len(evnt.NewChain) != 1 || evnt.NewChain[0].Hash() != c.headers[0x4].Hash() {
+		t.Fatal("bad added headers")
+	}
+
+	// the canonical chain is 0x0, 0x1, 0x4
+	for i, hash := range []byte{0x0, 0x1, 0x4} {
+		header, err := b.GetHeaderByNumber(big.NewInt(int64(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Hash() != c.headers[hash].Hash() {
+			t.Fatalf("bad canonical header at %d", i)
+		}
+	}
+	if _, err := b.GetHeaderByNumber(big.NewInt(3)); err != storage.ErrNotFound {
+		t.Fatal("the old canonical entry at 3 should be removed")
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	headers := NewTestHeaderChain(5)
+	b := NewTestBlockchain(t, headers[:3])
+
+	sub := b.Subscribe()
+	if err := b.WriteHeaders(headers[3:]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range headers[3:] {
+		select {
+		case evnt := <-sub.EventCh():
+			if evnt.Type != EventHead {
+				t.Fatalf("expected head event but found %s", evnt.Type)
+			}
+			if evnt.Header.Hash() != h.Hash() {
+				t.Fatalf("bad header for event at %d", h.Number.Uint64())
+			}
+		default:
+			t.Fatal("event not delivered")
+		}
+	}
+
+	sub.Unsubscribe()
+
+	// writes do not block or deliver after unsubscribe
+	fork := NewTestHeaderChainWithSeed(4, 1)
+	fork[1].ParentHash = headers[0].Hash()
+	if err := b.WriteHeader(fork[1]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.EventCh():
+		t.Fatal("no events expected after unsubscribe")
+	default:
+	}
+}
+
+func TestWriteBlocks(t *testing.T) {
+	headers := NewTestHeaderChain(1)
+	b := NewTestBlockchain(t, headers)
+
+	coinbase := common.HexToAddress("1")
+
+	// the state after the block only has the reward of the coinbase
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reward := ethash.FrontierBlockReward
+	if testParams.Forks.IsByzantium(1) {
+		reward = ethash.ByzantiumBlockReward
+	}
+	if testParams.Forks.IsConstantinople(1) {
+		reward = ethash.ConstantinopleBlockReward
+	}
+	statedb.AddBalance(coinbase, reward)
+
+	header := &types.Header{
+		ParentHash: headers[0].Hash(),
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		Coinbase:   coinbase,
+		Root:       statedb.IntermediateRoot(true),
+	}
+
+	// block with a wrong state root
+	bad := types.CopyHeader(header)
+	bad.Root = common.HexToHash("1")
+	if err := b.WriteBlocks([]*types.Block{types.NewBlock(bad, nil, nil, nil)}); err == nil {
+		t.Fatal("it should fail with a wrong state root")
+	}
+	if b.Header().Hash() != headers[0].Hash() {
+		t.Fatal("the head should not have moved")
+	}
+
+	block := types.NewBlock(header, nil, nil, nil)
+	if err := b.WriteBlocks([]*types.Block{block}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Header().Hash() != block.Hash() {
+		t.Fatal("the block should be the head")
+	}
+	if _, err := b.GetBodyByHash(block.Hash()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitChainInvalidBody(t *testing.T) {
+	headers, blocks, receipts := NewTestBodyChain(3)
+
+	t.Run("Transactions", func(t *testing.T) {
+		b := NewTestBlockchain(t, headers)
+
+		// block 2 with the transactions of block 1
+		invalid := []*types.Block{blocks[0], blocks[1], blocks[2].WithBody(blocks[1].Transactions(), nil)}
+		if err := b.CommitChain(invalid, receipts); err == nil {
+			t.Fatal("it should fail with wrong transactions")
+		}
+		if _, err := b.GetBodyByHash(blocks[1].Hash()); err != storage.ErrNotFound {
+			t.Fatal("no bodies should be written")
+		}
+	})
+
+	t.Run("Uncles", func(t *testing.T) {
+		b := NewTestBlockchain(t, headers)
+
+		invalid := []*types.Block{blocks[0], blocks[1].WithBody(blocks[1].Transactions(), []*types.Header{headers[0]}), blocks[2]}
+		if err := b.CommitChain(invalid, receipts); err == nil {
+			t.Fatal("it should fail with wrong uncles")
+		}
+	})
+
+	t.Run("Receipts", func(t *testing.T) {
+		b := NewTestBlockchain(t, headers)
+
+		invalid := [][]*types.Receipt{receipts[0], receipts[2], receipts[2]}
+		if err := b.CommitChain(blocks, invalid); err == nil {
+			t.Fatal("it should fail with wrong receipts")
+		}
+	})
+}
+
+func TestSetHead(t *testing.T) {
+	headers, blocks, receipts := NewTestBodyChain(10)
+	b := NewTestBlockchainWithBlocks(t, blocks, receipts)
+
+	sub := b.Subscribe()
+	defer sub.Unsubscribe()
+
+	if err := b.SetHead(5); err != nil {
+		t.Fatal(err)
+	}
+	if b.Header().Hash() != headers[5].Hash() {
+		t.Fatal("the head should be the block 5")
+	}
+
+	for _, block := range blocks[6:] {
+		if _, err := b.GetHeaderByNumber(block.Number()); err != storage.ErrNotFound {
+			t.Fatalf("canonical entry %d should be removed", block.NumberU64())
+		}
+		if _, err := b.GetTD(block.Hash()); err != storage.ErrNotFound {
+			t.Fatalf("total difficulty %d should be removed", block.NumberU64())
+		}
+		if _, err := b.GetBodyByHash(block.Hash()); err != storage.ErrNotFound {
+			t.Fatalf("body %d should be removed", block.NumberU64())
+		}
+		if _, err := b.GetReceiptsByHash(block.Hash()); err != storage.ErrNotFound {
+			t.Fatalf("receipts %d should be removed", block.NumberU64())
+		}
+	}
+	for _, block := range blocks[1:6] {
+		if _, err := b.GetBodyByHash(block.Hash()); err != nil {
+			t.Fatalf("body %d should be kept", block.NumberU64())
+		}
+	}
+
+	evnt := <-sub.EventCh()
+	if evnt.Type != EventReorg || len(evnt.OldChain) != 4 {
+		t.Fatal("expected a reorg event with the removed headers")
+	}
+
+	// the chain can grow again from the new head
+	if err := b.WriteHeaders(headers[6:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.SetHead(20); err == nil {
+		t.Fatal("it should fail to set the head above the current one")
+	}
+}
+
+func TestSetHeadPruneForks(t *testing.T) {
+	headers := NewTestHeaderChain(10)
+	b := NewTestBlockchain(t, headers)
+
+	// fork branches off the header at the given number, its difficulty
+	// is too low to become the canonical chain
+	fork := func(number int, n int) []*types.Header {
+		branch := []*types.Header{}
+		parent := headers[number]
+		for i := 0; i < n; i++ {
+			h := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     big.NewInt(parent.Number.Int64() + 1),
+				Difficulty: big.NewInt(0),
+				Extra:      []byte{byte(number)},
+			}
+			branch = append(branch, h)
+			parent = h
+		}
+		if err := b.WriteHeaders(branch); err != nil {
+			t.Fatal(err)
+		}
+		return branch
+	}
+
+	kept := fork(3, 2)
+	pruned := fork(7, 2)
+
+	// a fork of the pruned fork
+	nested := &types.Header{
+		ParentHash: pruned[0].Hash(),
+		Number:     pruned[1].Number,
+		Difficulty: big.NewInt(0),
+		Extra:      []byte{0x1},
+	}
+	if err := b.WriteHeader(nested); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.SetHead(5); err != nil {
+		t.Fatal(err)
+	}
+
+	forks, err := b.GetForks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(forks, []common.Hash{kept[1].Hash()}) {
+		t.Fatal("only the fork below the new head should be kept")
+	}
+	for _, h := range append(pruned, nested) {
+		if _, err := b.GetHeaderByHash(h.Hash()); err != storage.ErrNotFound {
+			t.Fatalf("header %s of the pruned fork should be removed", h.Hash().String())
+		}
+	}
+	for _, h := range kept {
+		if _, err := b.GetHeaderByHash(h.Hash()); err != nil {
+			t.Fatalf("header %s of the kept fork should exist", h.Hash().String())
+		}
+	}
+
+	// the kept fork can still grow
+	if err := b.WriteHeader(&types.Header{ParentHash: kept[1].Hash(), Number: big.NewInt(6), Difficulty: big.NewInt(0)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetBlockAndTransaction(t *testing.T) {
+	_, blocks, receipts := NewTestBodyChain(5)
+	b := NewTestBlockchainWithBlocks(t, blocks, receipts)
+
+	for _, block := range blocks {
+		found, err := b.GetBlockByNumber(block.Number())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Hash() != block.Hash() || len(found.Transactions()) != len(block.Transactions()) {
+			t.Fatalf("bad block %d", block.NumberU64())
+		}
+	}
+
+	for indx, block := range blocks[1:] {
+		tx := block.Transactions()[0]
+
+		found, entry, err := b.GetTransactionByHash(tx.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Hash() != tx.Hash() {
+			t.Fatal("bad transaction")
+		}
+		if entry.BlockHash != block.Hash() || entry.BlockNumber != block.NumberU64() || entry.Index != 0 {
+			t.Fatal("bad transaction lookup")
+		}
+
+		receipt, err := b.GetReceiptByTxHash(tx.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.TxHash != receipts[indx+1][0].TxHash {
+			t.Fatal("bad receipt")
+		}
+	}
+
+	// the transactions of the removed blocks are not indexed anymore
+	if err := b.SetHead(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetTransactionByHash(blocks[3].Transactions()[0].Hash()); err != storage.ErrNotFound {
+		t.Fatal("the transaction should not be found after set head")
+	}
+	if _, _, err := b.GetTransactionByHash(blocks[2].Transactions()[0].Hash()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// eventBufferSize is the size of the channel of each subscription
+const eventBufferSize = 16
+
+// EventType is the type of chain event
+type EventType int
+
+const (
+	// EventHead is emitted when the header extends the canonical chain
+	EventHead EventType = iota
+	// EventReorg is emitted when the canonical chain changes to another branch
+	EventReorg
+	// EventFork is emitted when the header is written in a side chain
+	EventFork
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventHead:
+		return "head"
+	case EventReorg:
+		return "reorg"
+	case EventFork:
+		return "fork"
+	default:
+		panic(fmt.Sprintf("unknown event type: %d", t))
+	}
+}
+
+// Event is a change in the chain
+type Event struct {
+	Type EventType
+
+	// Header is the header written. For head and reorg events
+	// it is the new head of the chain.
+	Header *types.Header
+
+	// OldChain are the headers removed from the canonical chain in a
+	// reorg, ordered from the old head to the common ancestor
+	OldChain []*types.Header
+
+	// NewChain are the headers added to the canonical chain in a
+	// reorg, ordered from the common ancestor to the new head
+	NewChain []*types.Header
+}
+
+// Subscription receives the events of the chain
+type Subscription struct {
+	b         *Blockchain
+	eventCh   chan *Event
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// EventCh returns the channel where the events are delivered
+func (s *Subscription) EventCh() <-chan *Event {
+	return s.eventCh
+}
+
+// Unsubscribe stops the delivery of events to the subscription
+func (s *Subscription) Unsubscribe() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+
+		s.b.subsLock.Lock()
+		delete(s.b.subs, s)
+		s.b.subsLock.Unlock()
+	})
+}
+
+// Subscribe creates a new subscription to the chain events. Events are
+// delivered in order and the writes to the chain wait for subscriptions
+// with a full buffer, so subscribers must either read or unsubscribe.
+func (b *Blockchain) Subscribe() *Subscription {
+	s := &Subscription{
+		b:       b,
+		eventCh: make(chan *Event, eventBufferSize),
+		closeCh: make(chan struct{}),
+	}
+
+	b.subsLock.Lock()
+	b.subs[s] = struct{}{}
+	b.subsLock.Unlock()
+
+	return s
+}
+
+// dispatch delivers the events to all the subscriptions
+func (b *Blockchain) dispatch(events []*Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	b.subsLock.Lock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.subsLock.Unlock()
+
+	for _, s := range subs {
+		for _, evnt := range events {
+			select {
+			case s.eventCh <- evnt:
+			case <-s.closeCh:
+			}
+		}
+	}
+}
@@ -5,7 +5,12 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/consensus"
+	"github.com/umbracle/minimal/consensus/ethash"
 	"github.com/umbracle/minimal/storage"
 )
 
@@ -16,18 +21,34 @@ func (f *fakeConsensus) VerifyHeader(parent *types.Header, header *types.Header,
 	return nil
 }
 
+func (f *fakeConsensus) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	return nil
+}
+
+// Finalize credits the ethash rewards so that the state roots of the
+// test blocks are the ones of a real chain
+func (f *fakeConsensus) Finalize(state *state.StateDB, block *types.Block) error {
+	return ethash.NewEthHash(testParams, nil).Finalize(state, block)
+}
+
 func (f *fakeConsensus) Author(header *types.Header) (common.Address, error) {
 	return common.Address{}, nil
 }
 
-func (f *fakeConsensus) Seal(block *types.Block) error {
-	return nil
+func (f *fakeConsensus) Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	return block, nil
 }
 
 func (f *fakeConsensus) Close() error {
 	return nil
 }
 
+// testParams are the chain params used by the test blockchains
+var testParams = &chain.Params{
+	Forks:   chain.AllForksEnabled,
+	ChainID: 1,
+}
+
 // NewTestHeaderChainWithSeed creates a new chain with a seed factor
 func NewTestHeaderChainWithSeed(n int, seed int) []*types.Header {
 	head := func(i int64) *types.Header {
@@ -60,7 +81,7 @@ func NewTestHeaderChain(n int) []*types.Header {
 
 // NewTestBodyChain creates a test blockchain with headers, body and receipts
 func NewTestBodyChain(n int) ([]*types.Header, []*types.Block, [][]*types.Receipt) {
-	genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), GasLimit: uint64(0)})
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0), GasLimit: uint64(0), Difficulty: big.NewInt(0)}, nil, nil, nil)
 
 	blocks := []*types.Block{genesis}
 	receipts := [][]*types.Receipt{types.Receipts{}} // genesis does not have tx
@@ -119,7 +140,7 @@ func NewTestBlockchain(t *testing.T, headers []*types.Header) *Blockchain {
 		t.Fatal(err)
 	}
 
-	b := NewBlockchain(s, &fakeConsensus{})
+	b := NewBlockchain(s, state.NewDatabase(ethdb.NewMemDatabase()), &fakeConsensus{}, testParams)
 	if headers != nil {
 		if err := b.WriteGenesis(headers[0]); err != nil {
 			t.Fatal(err)
@@ -1,9 +1,71 @@
 package chain
 
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+//go:generate go-bindata -pkg chain -nometadata -o ./builtin.go -prefix chains/ ./chains
+
+// Chain is the blockchain chain configuration
 type Chain struct {
-	Genesis   *Genesis
-	Params    *Params
-	Bootnodes Bootnodes
+	Name      string    `json:"name"`
+	Genesis   *Genesis  `json:"genesis"`
+	Params    *Params   `json:"params"`
+	Bootnodes Bootnodes `json:"bootnodes"`
 }
 
+// Bootnodes are the enodes used to join the network
 type Bootnodes []string
+
+// Builtin returns the names of the built-in chains
+func Builtin() []string {
+	names := []string{}
+	for _, asset := range AssetNames() {
+		names = append(names, strings.TrimSuffix(asset, ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImportFromName imports one of the built-in chains (i.e. mainnet)
+func ImportFromName(name string) (*Chain, error) {
+	data, err := Asset(name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("chain '%s' not found", name)
+	}
+	return importChain(data)
+}
+
+// ImportFromFile imports a chain from a json file
+func ImportFromFile(filename string) (*Chain, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return importChain(data)
+}
+
+func importChain(content []byte) (*Chain, error) {
+	var chain *Chain
+	if err := json.Unmarshal(content, &chain); err != nil {
+		return nil, err
+	}
+
+	if chain.Genesis == nil {
+		return nil, fmt.Errorf("chain has no genesis")
+	}
+	if chain.Params == nil {
+		return nil, fmt.Errorf("chain has no params")
+	}
+	if chain.Params.Forks == nil {
+		chain.Params.Forks = &Forks{}
+	}
+	if _, err := chain.Params.EngineName(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestBuiltinChains(t *testing.T) {
+	cases := map[string]common.Hash{
+		"mainnet": params.MainnetGenesisHash,
+		"ropsten": params.TestnetGenesisHash,
+		"rinkeby": params.RinkebyGenesisHash,
+		"goerli":  common.HexToHash("0xbf7e331f7f7c1dd2e05159666b3bf8bc7a8a3a9eb1d518969eab529dd9b88c1a"),
+	}
+
+	for name, hash := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, err := ImportFromName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Name != name {
+				t.Fatalf("bad name %s", c.Name)
+			}
+
+			header, err := c.Genesis.Header()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Hash() != hash {
+				t.Fatalf("expected hash %s but found %s", hash.String(), header.Hash().String())
+			}
+		})
+	}
+}
+
+func TestBuiltinNames(t *testing.T) {
+	for _, name := range Builtin() {
+		if _, err := ImportFromName(name); err != nil {
+			t.Fatalf("failed to import %s: %v", name, err)
+		}
+	}
+	if _, err := ImportFromName("unknown"); err == nil {
+		t.Fatal("it should fail")
+	}
+}
+
+func TestBuiltinAssets(t *testing.T) {
+	// builtin.go has to be generated again when a chain file changes
+	for _, name := range AssetNames() {
+		data, err := ioutil.ReadFile(filepath.Join("chains", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(MustAsset(name), data) {
+			t.Fatalf("%s is out of date, run go generate", name)
+		}
+	}
+}
+
+var customChain = `{
+	"name": "custom",
+	"genesis": {
+		"gasLimit": "0x1000",
+		"difficulty": "0x1",
+		"alloc": {}
+	},
+	"params": {
+		"forks": {
+			"homestead": 0,
+			"byzantium": 10
+		},
+		"chainID": 100,
+		"networkID": 100,
+		"engine": {
+			"ethash": {}
+		}
+	},
+	"bootnodes": []
+}`
+
+func TestImportFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minimal-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "custom.json")
+	if err := ioutil.WriteFile(filename, []byte(customChain), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ImportFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Params.NetworkID != 100 {
+		t.Fatal("bad network id")
+	}
+	if engine, _ := c.Params.EngineName(); engine != "ethash" {
+		t.Fatalf("bad engine %s", engine)
+	}
+
+	forks := c.Params.Forks
+	if !forks.Homestead.Active(0) {
+		t.Fatal("homestead should be active")
+	}
+	if forks.Byzantium.Active(9) || !forks.Byzantium.Active(10) {
+		t.Fatal("byzantium should be active at 10")
+	}
+	if forks.Constantinople != nil {
+		t.Fatal("constantinople should not be scheduled")
+	}
+}
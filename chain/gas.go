@@ -56,3 +56,15 @@ var GasTableConstantinople = &GasTable{
 	ExpByte:         50,
 	CreateBySuicide: 25000,
 }
+
+var GasTableIstanbul = &GasTable{
+	ExtcodeSize:     700,
+	ExtcodeCopy:     700,
+	ExtcodeHash:     700,
+	Balance:         700,
+	SLoad:           800,
+	Calls:           700,
+	Suicide:         5000,
+	ExpByte:         50,
+	CreateBySuicide: 25000,
+}
@@ -1,11 +1,24 @@
 package chain
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
 )
 
+// Based on geth core/genesis.go
+
+// Genesis specifies the header fields and the state of the genesis block
 type Genesis struct {
 	Nonce      uint64         `json:"nonce"`
 	Timestamp  uint64         `json:"timestamp"`
@@ -22,15 +35,242 @@ type Genesis struct {
 	ParentHash common.Hash `json:"parentHash"`
 }
 
-type GenesisAlloc struct {
+// GenesisAlloc specifies the initial state of the genesis block
+type GenesisAlloc map[common.Address]GenesisAccount
+
+// GenesisAccount is an account in the state of the genesis block
+type GenesisAccount struct {
+	Code       []byte                      `json:"code,omitempty"`
+	Storage    map[common.Hash]common.Hash `json:"storage,omitempty"`
+	Balance    *big.Int                    `json:"balance"`
+	Nonce      uint64                      `json:"nonce,omitempty"`
+	PrivateKey []byte                      `json:"secretKey,omitempty"` // for tests
+}
+
+// Commit writes the allocated state to the state database and returns
+// the genesis header with the resulting state root
+func (g *Genesis) Commit(st state.Database) (*types.Header, error) {
+	statedb, err := state.New(common.Hash{}, st)
+	if err != nil {
+		return nil, err
+	}
+	for addr, account := range g.Alloc {
+		if account.Balance != nil {
+			statedb.AddBalance(addr, account.Balance)
+		}
+		statedb.SetCode(addr, account.Code)
+		statedb.SetNonce(addr, account.Nonce)
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit the genesis state: %v", err)
+	}
+	if err := st.TrieDB().Commit(root, false); err != nil {
+		return nil, fmt.Errorf("failed to commit the genesis trie: %v", err)
+	}
+
+	header := &types.Header{
+		ParentHash:  g.ParentHash,
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    g.Coinbase,
+		Root:        root,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  g.Difficulty,
+		Number:      new(big.Int).SetUint64(g.Number),
+		GasLimit:    g.GasLimit,
+		GasUsed:     g.GasUsed,
+		Time:        new(big.Int).SetUint64(g.Timestamp),
+		Extra:       g.ExtraData,
+		MixDigest:   g.Mixhash,
+		Nonce:       types.EncodeNonce(g.Nonce),
+	}
+	if g.GasLimit == 0 {
+		header.GasLimit = params.GenesisGasLimit
+	}
+	if g.Difficulty == nil {
+		header.Difficulty = params.GenesisDifficulty
+	}
+	return header, nil
+}
+
+// Header returns the genesis header without persisting the state
+func (g *Genesis) Header() (*types.Header, error) {
+	return g.Commit(state.NewDatabase(ethdb.NewMemDatabase()))
 }
 
 // Encoding
 
+type genesisEncoding struct {
+	Nonce      *math.HexOrDecimal64                        `json:"nonce"`
+	Timestamp  *math.HexOrDecimal64                        `json:"timestamp"`
+	ExtraData  *hexutil.Bytes                              `json:"extraData"`
+	GasLimit   *math.HexOrDecimal64                        `json:"gasLimit"`
+	Difficulty *math.HexOrDecimal256                       `json:"difficulty"`
+	Mixhash    *common.Hash                                `json:"mixHash"`
+	Coinbase   *common.Address                             `json:"coinbase"`
+	Alloc      map[common.UnprefixedAddress]GenesisAccount `json:"alloc"`
+	Number     *math.HexOrDecimal64                        `json:"number"`
+	GasUsed    *math.HexOrDecimal64                        `json:"gasUsed"`
+	ParentHash *common.Hash                                `json:"parentHash"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (g *Genesis) MarshalJSON() ([]byte, error) {
+	enc := genesisEncoding{
+		Nonce:      (*math.HexOrDecimal64)(&g.Nonce),
+		Timestamp:  (*math.HexOrDecimal64)(&g.Timestamp),
+		ExtraData:  (*hexutil.Bytes)(&g.ExtraData),
+		GasLimit:   (*math.HexOrDecimal64)(&g.GasLimit),
+		Difficulty: (*math.HexOrDecimal256)(g.Difficulty),
+		Mixhash:    &g.Mixhash,
+		Coinbase:   &g.Coinbase,
+		Number:     (*math.HexOrDecimal64)(&g.Number),
+		GasUsed:    (*math.HexOrDecimal64)(&g.GasUsed),
+		ParentHash: &g.ParentHash,
+	}
+	if g.Alloc != nil {
+		enc.Alloc = make(map[common.UnprefixedAddress]GenesisAccount, len(g.Alloc))
+		for addr, account := range g.Alloc {
+			enc.Alloc[common.UnprefixedAddress(addr)] = account
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
 func (g *Genesis) UnmarshalJSON(data []byte) error {
+	var dec genesisEncoding
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+
+	if dec.GasLimit == nil {
+		return fmt.Errorf("field 'gasLimit' is required")
+	}
+	if dec.Difficulty == nil {
+		return fmt.Errorf("field 'difficulty' is required")
+	}
+	if dec.Alloc == nil {
+		return fmt.Errorf("field 'alloc' is required")
+	}
+
+	g.GasLimit = uint64(*dec.GasLimit)
+	g.Difficulty = (*big.Int)(dec.Difficulty)
+
+	g.Alloc = make(GenesisAlloc, len(dec.Alloc))
+	for addr, account := range dec.Alloc {
+		g.Alloc[common.Address(addr)] = account
+	}
+
+	if dec.Nonce != nil {
+		g.Nonce = uint64(*dec.Nonce)
+	}
+	if dec.Timestamp != nil {
+		g.Timestamp = uint64(*dec.Timestamp)
+	}
+	if dec.ExtraData != nil {
+		g.ExtraData = *dec.ExtraData
+	}
+	if dec.Mixhash != nil {
+		g.Mixhash = *dec.Mixhash
+	}
+	if dec.Coinbase != nil {
+		g.Coinbase = *dec.Coinbase
+	}
+	if dec.Number != nil {
+		g.Number = uint64(*dec.Number)
+	}
+	if dec.GasUsed != nil {
+		g.GasUsed = uint64(*dec.GasUsed)
+	}
+	if dec.ParentHash != nil {
+		g.ParentHash = *dec.ParentHash
+	}
 	return nil
 }
 
-func (g *Genesis) MarshalJSON() ([]byte, error) {
-	return nil, nil
+type genesisAccountEncoding struct {
+	Code       *hexutil.Bytes              `json:"code,omitempty"`
+	Storage    map[storageJSON]storageJSON `json:"storage,omitempty"`
+	Balance    *math.HexOrDecimal256       `json:"balance"`
+	Nonce      *math.HexOrDecimal64        `json:"nonce,omitempty"`
+	PrivateKey *hexutil.Bytes              `json:"secretKey,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (a GenesisAccount) MarshalJSON() ([]byte, error) {
+	enc := genesisAccountEncoding{
+		Balance: (*math.HexOrDecimal256)(a.Balance),
+	}
+	if a.Code != nil {
+		enc.Code = (*hexutil.Bytes)(&a.Code)
+	}
+	if a.Storage != nil {
+		enc.Storage = make(map[storageJSON]storageJSON, len(a.Storage))
+		for k, v := range a.Storage {
+			enc.Storage[storageJSON(k)] = storageJSON(v)
+		}
+	}
+	if a.Nonce != 0 {
+		enc.Nonce = (*math.HexOrDecimal64)(&a.Nonce)
+	}
+	if a.PrivateKey != nil {
+		enc.PrivateKey = (*hexutil.Bytes)(&a.PrivateKey)
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (a *GenesisAccount) UnmarshalJSON(data []byte) error {
+	var dec genesisAccountEncoding
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+
+	if dec.Balance == nil {
+		return fmt.Errorf("field 'balance' is required")
+	}
+	a.Balance = (*big.Int)(dec.Balance)
+
+	if dec.Code != nil {
+		a.Code = *dec.Code
+	}
+	if dec.Storage != nil {
+		a.Storage = make(map[common.Hash]common.Hash, len(dec.Storage))
+		for k, v := range dec.Storage {
+			a.Storage[common.Hash(k)] = common.Hash(v)
+		}
+	}
+	if dec.Nonce != nil {
+		a.Nonce = uint64(*dec.Nonce)
+	}
+	if dec.PrivateKey != nil {
+		a.PrivateKey = *dec.PrivateKey
+	}
+	return nil
+}
+
+// storageJSON is a 256 bit value that allows less than 256 bits when
+// decoding from hex
+type storageJSON common.Hash
+
+func (h *storageJSON) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(text, []byte("0x"))
+	if len(text) > 64 {
+		return fmt.Errorf("too many hex characters in storage key/value %q", text)
+	}
+	offset := len(h) - len(text)/2 // pad on the left
+	if _, err := hex.Decode(h[offset:], text); err != nil {
+		return fmt.Errorf("invalid hex storage key/value %q", text)
+	}
+	return nil
+}
+
+func (h storageJSON) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(h[:]).MarshalText()
 }
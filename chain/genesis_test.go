@@ -0,0 +1,128 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestGenesisMainnet(t *testing.T) {
+	data, err := json.Marshal(core.DefaultGenesisBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var genesis Genesis
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := genesis.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash() != params.MainnetGenesisHash {
+		t.Fatalf("expected hash %s but found %s", params.MainnetGenesisHash.String(), header.Hash().String())
+	}
+}
+
+var customGenesis = `{
+	"nonce": "0x42",
+	"timestamp": "0x0",
+	"extraData": "0x1234",
+	"gasLimit": "0x1000000",
+	"difficulty": "0x400",
+	"alloc": {
+		"0000000000000000000000000000000000000001": {
+			"balance": "0x1"
+		},
+		"0x0000000000000000000000000000000000000002": {
+			"balance": "100",
+			"nonce": "0x5",
+			"code": "0x6001600055",
+			"storage": {
+				"0x01": "0x02"
+			}
+		}
+	}
+}`
+
+func TestGenesisCustom(t *testing.T) {
+	var genesis Genesis
+	if err := json.Unmarshal([]byte(customGenesis), &genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := common.HexToAddress("0x2")
+	account, ok := genesis.Alloc[addr]
+	if !ok {
+		t.Fatal("account not found")
+	}
+	if account.Balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("bad balance %s", account.Balance.String())
+	}
+
+	db := state.NewDatabase(ethdb.NewMemDatabase())
+	header, err := genesis.Commit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Nonce.Uint64() != 0x42 {
+		t.Fatal("bad nonce")
+	}
+
+	// the state must be available under the genesis root
+	statedb, err := state.New(header.Root, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statedb.GetNonce(addr) != 5 {
+		t.Fatal("bad account nonce")
+	}
+	if !reflect.DeepEqual(statedb.GetCode(addr), []byte{0x60, 0x01, 0x60, 0x00, 0x55}) {
+		t.Fatal("bad account code")
+	}
+	if statedb.GetState(addr, common.BigToHash(big.NewInt(1))) != common.BigToHash(big.NewInt(2)) {
+		t.Fatal("bad account storage")
+	}
+
+	// encode and decode again
+	data, err := json.Marshal(&genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var genesis2 Genesis
+	if err := json.Unmarshal(data, &genesis2); err != nil {
+		t.Fatal(err)
+	}
+
+	header2, err := genesis2.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash() != header2.Hash() {
+		t.Fatal("genesis changed after encoding")
+	}
+}
+
+func TestGenesisRequiredFields(t *testing.T) {
+	cases := []string{
+		`{"difficulty": "0x1", "alloc": {}}`,
+		`{"gasLimit": "0x1", "alloc": {}}`,
+		`{"gasLimit": "0x1", "difficulty": "0x1"}`,
+		`{"gasLimit": "0x1", "difficulty": "0x1", "alloc": {"0x01": {}}}`,
+	}
+	for _, c := range cases {
+		var genesis Genesis
+		if err := json.Unmarshal([]byte(c), &genesis); err == nil {
+			t.Fatalf("expected to fail: %s", c)
+		}
+	}
+}
@@ -1,6 +1,208 @@
 package chain
 
+import (
+	"fmt"
+	"math/big"
+)
+
+// Params are the parameters of the chain
 type Params struct {
+	Forks     *Forks                 `json:"forks"`
+	ChainID   int                    `json:"chainID"`
+	NetworkID uint64                 `json:"networkID"`
+	Engine    map[string]interface{} `json:"engine"`
+}
+
+// EngineName returns the name of the consensus engine of the chain
+func (p *Params) EngineName() (string, error) {
+	if len(p.Engine) != 1 {
+		return "", fmt.Errorf("expected one consensus engine but found %d", len(p.Engine))
+	}
+	for name := range p.Engine {
+		return name, nil
+	}
+	return "", nil
+}
+
+// EngineConfig returns the config of the consensus engine of the chain
+func (p *Params) EngineConfig() map[string]interface{} {
+	for _, config := range p.Engine {
+		if config, ok := config.(map[string]interface{}); ok {
+			return config
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// Forks specifies when each fork is activated. Frontier is
+// always active, a nil fork is never activated.
+type Forks struct {
+	Homestead      *Fork `json:"homestead,omitempty"`
+	DAO            *Fork `json:"dao,omitempty"`
+	EIP150         *Fork `json:"EIP150,omitempty"`
+	EIP155         *Fork `json:"EIP155,omitempty"`
+	EIP158         *Fork `json:"EIP158,omitempty"`
+	Byzantium      *Fork `json:"byzantium,omitempty"`
+	Constantinople *Fork `json:"constantinople,omitempty"`
+	Petersburg     *Fork `json:"petersburg,omitempty"`
+	Istanbul       *Fork `json:"istanbul,omitempty"`
+	MuirGlacier    *Fork `json:"muirGlacier,omitempty"`
+	London         *Fork `json:"london,omitempty"`
+	ArrowGlacier   *Fork `json:"arrowGlacier,omitempty"`
+	GrayGlacier    *Fork `json:"grayGlacier,omitempty"`
+}
+
+func (f *Forks) active(ff *Fork, block uint64) bool {
+	if ff == nil {
+		return false
+	}
+	return ff.Active(block)
+}
+
+// IsHomestead returns true if homestead is active at the block
+func (f *Forks) IsHomestead(block uint64) bool {
+	return f.active(f.Homestead, block)
+}
+
+// IsDAO returns true if the block is the DAO hard fork block
+func (f *Forks) IsDAO(block uint64) bool {
+	return f.DAO != nil && uint64(*f.DAO) == block
 }
 
-// maybe change to fork
+// IsEIP150 returns true if EIP150 is active at the block
+func (f *Forks) IsEIP150(block uint64) bool {
+	return f.active(f.EIP150, block)
+}
+
+// IsEIP155 returns true if EIP155 is active at the block
+func (f *Forks) IsEIP155(block uint64) bool {
+	return f.active(f.EIP155, block)
+}
+
+// IsEIP158 returns true if EIP158 is active at the block
+func (f *Forks) IsEIP158(block uint64) bool {
+	return f.active(f.EIP158, block)
+}
+
+// IsByzantium returns true if byzantium is active at the block
+func (f *Forks) IsByzantium(block uint64) bool {
+	return f.active(f.Byzantium, block)
+}
+
+// IsConstantinople returns true if constantinople is active at the block
+func (f *Forks) IsConstantinople(block uint64) bool {
+	return f.active(f.Constantinople, block)
+}
+
+// IsPetersburg returns true if petersburg is active at the block
+func (f *Forks) IsPetersburg(block uint64) bool {
+	return f.active(f.Petersburg, block)
+}
+
+// IsIstanbul returns true if istanbul is active at the block
+func (f *Forks) IsIstanbul(block uint64) bool {
+	return f.active(f.Istanbul, block)
+}
+
+// IsMuirGlacier returns true if the muir glacier bomb delay is active at the block
+func (f *Forks) IsMuirGlacier(block uint64) bool {
+	return f.active(f.MuirGlacier, block)
+}
+
+// IsLondon returns true if the london bomb delay is active at the block
+func (f *Forks) IsLondon(block uint64) bool {
+	return f.active(f.London, block)
+}
+
+// IsArrowGlacier returns true if the arrow glacier bomb delay is active at the block
+func (f *Forks) IsArrowGlacier(block uint64) bool {
+	return f.active(f.ArrowGlacier, block)
+}
+
+// IsGrayGlacier returns true if the gray glacier bomb delay is active at the block
+func (f *Forks) IsGrayGlacier(block uint64) bool {
+	return f.active(f.GrayGlacier, block)
+}
+
+// At returns the forks active at the block
+func (f *Forks) At(block uint64) ForksInTime {
+	return ForksInTime{
+		Homestead:      f.IsHomestead(block),
+		Byzantium:      f.IsByzantium(block),
+		Constantinople: f.IsConstantinople(block),
+		Petersburg:     f.IsPetersburg(block),
+		Istanbul:       f.IsIstanbul(block),
+		EIP150:         f.IsEIP150(block),
+		EIP155:         f.IsEIP155(block),
+		EIP158:         f.IsEIP158(block),
+	}
+}
+
+// ForksInTime is the set of forks active at a given block
+type ForksInTime struct {
+	Homestead,
+	Byzantium,
+	Constantinople,
+	Petersburg,
+	Istanbul,
+	EIP150,
+	EIP155,
+	EIP158 bool
+}
+
+// EIP1283 returns true if the net gas metering for SSTORE of constantinople
+// is active. It was removed in petersburg.
+func (f ForksInTime) EIP1283() bool {
+	return f.Constantinople && !f.Petersburg
+}
+
+// GasTable returns the gas costs of the variable opcodes for the forks
+func (f ForksInTime) GasTable() *GasTable {
+	switch {
+	case f.Istanbul:
+		return GasTableIstanbul
+	case f.Constantinople:
+		return GasTableConstantinople
+	case f.EIP158:
+		return GasTableEIP158
+	case f.EIP150:
+		return GasTableEIP150
+	default:
+		return GasTableHomestead
+	}
+}
+
+// AllForksEnabled has all the forks active from the genesis
+var AllForksEnabled = &Forks{
+	Homestead:      NewFork(0),
+	EIP150:         NewFork(0),
+	EIP155:         NewFork(0),
+	EIP158:         NewFork(0),
+	Byzantium:      NewFork(0),
+	Constantinople: NewFork(0),
+	Petersburg:     NewFork(0),
+	Istanbul:       NewFork(0),
+	MuirGlacier:    NewFork(0),
+	London:         NewFork(0),
+	ArrowGlacier:   NewFork(0),
+	GrayGlacier:    NewFork(0),
+}
+
+// Fork is the block number at which a fork is activated
+type Fork uint64
+
+// NewFork creates a new fork at the given block
+func NewFork(n uint64) *Fork {
+	f := Fork(n)
+	return &f
+}
+
+// Active checks if the fork is active at the block
+func (f Fork) Active(block uint64) bool {
+	return block >= uint64(f)
+}
+
+// Int returns the block of the fork as a big.Int
+func (f Fork) Int() *big.Int {
+	return new(big.Int).SetUint64(uint64(f))
+}
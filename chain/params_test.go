@@ -0,0 +1,75 @@
+package chain
+
+import (
+	"testing"
+)
+
+func TestForksInTime(t *testing.T) {
+	forks := &Forks{
+		Homestead:      NewFork(0),
+		DAO:            NewFork(2),
+		EIP150:         NewFork(2),
+		Byzantium:      NewFork(5),
+		Constantinople: NewFork(10),
+		Petersburg:     NewFork(15),
+	}
+
+	cases := []struct {
+		block    uint64
+		expected ForksInTime
+	}{
+		{0, ForksInTime{Homestead: true}},
+		{2, ForksInTime{Homestead: true, EIP150: true}},
+		{5, ForksInTime{Homestead: true, EIP150: true, Byzantium: true}},
+		{10, ForksInTime{Homestead: true, EIP150: true, Byzantium: true, Constantinople: true}},
+		{15, ForksInTime{Homestead: true, EIP150: true, Byzantium: true, Constantinople: true, Petersburg: true}},
+	}
+
+	for _, c := range cases {
+		if found := forks.At(c.block); found != c.expected {
+			t.Fatalf("block %d: expected %v but found %v", c.block, c.expected, found)
+		}
+	}
+
+	if forks.IsDAO(1) || !forks.IsDAO(2) || forks.IsDAO(3) {
+		t.Fatal("the dao fork is only applied at its block")
+	}
+	if forks.IsIstanbul(100) {
+		t.Fatal("istanbul is not scheduled")
+	}
+}
+
+func TestForksEIP1283(t *testing.T) {
+	forks := &Forks{
+		Constantinople: NewFork(10),
+		Petersburg:     NewFork(15),
+	}
+
+	if forks.At(9).EIP1283() {
+		t.Fatal("eip1283 not active before constantinople")
+	}
+	if !forks.At(10).EIP1283() {
+		t.Fatal("eip1283 active on constantinople")
+	}
+	if forks.At(15).EIP1283() {
+		t.Fatal("eip1283 removed on petersburg")
+	}
+}
+
+func TestForksGasTable(t *testing.T) {
+	cases := []struct {
+		forks    ForksInTime
+		expected *GasTable
+	}{
+		{ForksInTime{}, GasTableHomestead},
+		{ForksInTime{Homestead: true}, GasTableHomestead},
+		{ForksInTime{EIP150: true}, GasTableEIP150},
+		{ForksInTime{EIP150: true, EIP158: true}, GasTableEIP158},
+		{ForksInTime{EIP150: true, EIP158: true, Constantinople: true}, GasTableConstantinople},
+	}
+	for _, c := range cases {
+		if c.forks.GasTable() != c.expected {
+			t.Fatalf("bad gas table for %v", c.forks)
+		}
+	}
+}
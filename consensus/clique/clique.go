@@ -0,0 +1,571 @@
+package clique
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/consensus"
+	"github.com/umbracle/minimal/storage"
+)
+
+// Based on geth clique.go
+
+const (
+	// checkpointInterval is the number of blocks after which the snapshot is stored
+	checkpointInterval = 1024
+	// inmemorySnapshots is the number of recent snapshots to keep in memory
+	inmemorySnapshots = 128
+	// inmemorySignatures is the number of recent block signatures to keep in memory
+	inmemorySignatures = 4096
+	// wiggleTime is the random delay (per signer) to allow concurrent signers
+	wiggleTime = 500 * time.Millisecond
+)
+
+var (
+	// extraVanity is the fixed number of extra-data bytes reserved for signer vanity
+	extraVanity = 32
+	// extraSeal is the fixed number of extra-data bytes reserved for the signer seal
+	extraSeal = 65
+
+	// nonceAuthVote is the nonce to vote on adding a new signer
+	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff")
+	// nonceDropVote is the nonce to vote on removing a signer
+	nonceDropVote = hexutil.MustDecode("0x0000000000000000")
+
+	// diffInTurn is the difficulty of in-turn signatures
+	diffInTurn = big.NewInt(2)
+	// diffNoTurn is the difficulty of out-of-turn signatures
+	diffNoTurn = big.NewInt(1)
+)
+
+var (
+	errUnknownBlock                 = errors.New("unknown block")
+	errUnknownAncestor              = errors.New("unknown ancestor")
+	errFutureBlock                  = errors.New("block in the future")
+	errInvalidCheckpointBeneficiary = errors.New("beneficiary in checkpoint block non-zero")
+	errInvalidVote                  = errors.New("vote nonce not 0x00..0 or 0xff..f")
+	errInvalidCheckpointVote        = errors.New("vote nonce in checkpoint block non-zero")
+	errMissingVanity                = errors.New("extra-data 32 byte vanity prefix missing")
+	errMissingSignature             = errors.New("extra-data 65 byte signature suffix missing")
+	errExtraSigners                 = errors.New("non-checkpoint block contains extra signer list")
+	errInvalidCheckpointSigners     = errors.New("invalid signer list on checkpoint block")
+	errMismatchingCheckpointSigners = errors.New("mismatching signer list on checkpoint block")
+	errInvalidMixDigest             = errors.New("non-zero mix digest")
+	errInvalidUncleHash             = errors.New("non empty uncle hash")
+	errUnclesNotAllowed             = errors.New("uncles not allowed")
+	errInvalidDifficulty            = errors.New("invalid difficulty")
+	errWrongDifficulty              = errors.New("wrong difficulty")
+	errInvalidTimestamp             = errors.New("invalid timestamp")
+	errInvalidVotingChain           = errors.New("invalid voting chain")
+	errUnauthorizedSigner           = errors.New("unauthorized signer")
+	errRecentlySigned               = errors.New("recently signed")
+	errNoSignerKey                  = errors.New("no key to sign blocks")
+	errSealAborted                  = errors.New("sealing aborted")
+)
+
+// sigHash returns the hash of the header without the 65 byte signature
+// at the end of the extra data. It panics if the extra data is too short.
+func sigHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+
+	rlp.Encode(hasher, []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-extraSeal],
+		header.MixDigest,
+		header.Nonce,
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// ecrecover extracts the address of the signer of the header
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	hash := header.Hash()
+	if address, ok := sigcache.Get(hash); ok {
+		return address.(common.Address), nil
+	}
+	if len(header.Extra) < extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	signature := header.Extra[len(header.Extra)-extraSeal:]
+
+	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// Clique is the proof-of-authority consensus engine (EIP-225)
+type Clique struct {
+	config *Config
+	db     *storage.Storage
+
+	recents    *lru.ARCCache
+	signatures *lru.ARCCache
+
+	lock      sync.RWMutex
+	proposals map[common.Address]bool
+	key       *ecdsa.PrivateKey
+	signer    common.Address
+
+	// pending are the checkpoint snapshots waiting for their
+	// header to be committed before they are stored
+	pendingLock sync.Mutex
+	pending     []*Snapshot
+}
+
+// NewClique creates a new clique consensus. The snapshots of the signers are
+// stored in db. The key is used to seal blocks and it can be nil if the node
+// only verifies headers.
+func NewClique(params *chain.Params, db *storage.Storage, key *ecdsa.PrivateKey) (*Clique, error) {
+	config := DefaultConfig()
+
+	data, err := json.Marshal(params.EngineConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if config.Epoch == 0 {
+		config.Epoch = DefaultConfig().Epoch
+	}
+
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+
+	c := &Clique{
+		config:     config,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  map[common.Address]bool{},
+		key:        key,
+	}
+	if key != nil {
+		c.signer = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	return c, nil
+}
+
+// VerifyHeader verifies the header is correct
+func (c *Clique) VerifyHeader(parent *types.Header, header *types.Header, seal bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return errFutureBlock
+	}
+	// Checkpoint blocks need to enforce zero beneficiary
+	checkpoint := (number % c.config.Epoch) == 0
+	if checkpoint && header.Coinbase != (common.Address{}) {
+		return errInvalidCheckpointBeneficiary
+	}
+	// Nonces must be 0x00..0 or 0xff..f, zeroes enforced on checkpoints
+	if !bytes.Equal(header.Nonce[:], nonceAuthVote) && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidVote
+	}
+	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidCheckpointVote
+	}
+	// Check that the extra-data contains both the vanity and signature
+	if len(header.Extra) < extraVanity {
+		return errMissingVanity
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	// Ensure that the extra-data contains a signer list on checkpoint, but none otherwise
+	signersBytes := len(header.Extra) - extraVanity - extraSeal
+	if !checkpoint && signersBytes != 0 {
+		return errExtraSigners
+	}
+	if checkpoint && signersBytes%common.AddressLength != 0 {
+		return errInvalidCheckpointSigners
+	}
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	// Uncles are meaningless in PoA
+	if header.UncleHash != types.EmptyUncleHash {
+		return errInvalidUncleHash
+	}
+	if number > 0 {
+		if header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0 && header.Difficulty.Cmp(diffNoTurn) != 0) {
+			return errInvalidDifficulty
+		}
+	}
+
+	// Verify the fields that depend on the parent
+	if parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return errUnknownAncestor
+	}
+	if parent.Time.Uint64()+c.config.Period > header.Time.Uint64() {
+		return errInvalidTimestamp
+	}
+
+	snap, err := c.snapshot(parent)
+	if err != nil {
+		return err
+	}
+	// If the block is a checkpoint block, verify the signer list
+	if checkpoint {
+		signers := make([]byte, len(snap.Signers)*common.AddressLength)
+		for i, signer := range snap.signers() {
+			copy(signers[i*common.AddressLength:], signer[:])
+		}
+		if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], signers) {
+			return errMismatchingCheckpointSigners
+		}
+	}
+
+	if seal {
+		if err := c.verifySeal(snap, header); err != nil {
+			return err
+		}
+	}
+
+	// Apply the header so that the snapshot is ready for its child
+	next, err := snap.apply([]*types.Header{header})
+	if err != nil {
+		return err
+	}
+	return c.addSnapshot(next)
+}
+
+// verifySeal checks that the signer of the header is authorized
+// and that the difficulty matches its turn
+func (c *Clique) verifySeal(snap *Snapshot, header *types.Header) error {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+
+	signer, err := ecrecover(header, c.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Signers[signer]; !ok {
+		return errUnauthorizedSigner
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only fail if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); seen > number-limit {
+				return errRecentlySigned
+			}
+		}
+	}
+
+	inturn := snap.inturn(number, signer)
+	if inturn && header.Difficulty.Cmp(diffInTurn) != 0 {
+		return errWrongDifficulty
+	}
+	if !inturn && header.Difficulty.Cmp(diffNoTurn) != 0 {
+		return errWrongDifficulty
+	}
+	return nil
+}
+
+// snapshot returns the snapshot of signers after the header. It walks back the
+// chain until it finds a known snapshot and applies the headers on top of it.
+func (c *Clique) snapshot(header *types.Header) (*Snapshot, error) {
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+
+	for snap == nil {
+		hash, number := header.Hash(), header.Number.Uint64()
+
+		if s, ok := c.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(c.config, c.signatures, c.db, hash); err == nil {
+				snap = s
+				break
+			}
+		}
+		// The genesis has the initial list of signers
+		if number == 0 {
+			signers := make([]common.Address, (len(header.Extra)-extraVanity-extraSeal)/common.AddressLength)
+			for i := 0; i < len(signers); i++ {
+				copy(signers[i][:], header.Extra[extraVanity+i*common.AddressLength:])
+			}
+			snap = newSnapshot(c.config, c.signatures, number, hash, signers)
+			if err := snap.store(c.db); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		headers = append(headers, header)
+		parent, err := c.db.ReadHeader(header.ParentHash)
+		if err != nil {
+			return nil, errUnknownAncestor
+		}
+		header = parent
+	}
+
+	if len(headers) == 0 {
+		return snap, nil
+	}
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.addSnapshot(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// addSnapshot keeps the snapshot in memory. The checkpoints are only stored
+// once their header is committed since the header being verified can still
+// be rejected.
+func (c *Clique) addSnapshot(snap *Snapshot) error {
+	c.recents.Add(snap.Hash, snap)
+
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	if snap.Number%checkpointInterval == 0 {
+		c.pending = append(c.pending, snap)
+	}
+	return c.storeCheckpoints(snap.Number)
+}
+
+// storeCheckpoints stores the pending checkpoints whose header is committed.
+// The ones that are not committed a whole interval later are dropped.
+func (c *Clique) storeCheckpoints(number uint64) error {
+	pending := []*Snapshot{}
+	for indx, snap := range c.pending {
+		if _, err := c.db.ReadHeader(snap.Hash); err == nil {
+			if err := snap.store(c.db); err != nil {
+				c.pending = append(pending, c.pending[indx:]...)
+				return err
+			}
+			continue
+		}
+		if snap.Number+checkpointInterval > number {
+			pending = append(pending, snap)
+		}
+	}
+	c.pending = pending
+	return nil
+}
+
+// VerifyUncles verifies the block does not have uncles,
+// they are meaningless in PoA
+func (c *Clique) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errUnclesNotAllowed
+	}
+	return nil
+}
+
+// Finalize does not modify the state since there are no block rewards in PoA
+func (c *Clique) Finalize(state *state.StateDB, block *types.Block) error {
+	return nil
+}
+
+// Author returns the signer of the header
+func (c *Clique) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, c.signatures)
+}
+
+// Propose adds a proposal to authorize or drop an address that
+// is voted on the blocks sealed by this node
+func (c *Clique) Propose(address common.Address, authorize bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.proposals[address] = authorize
+}
+
+// Discard drops a proposal
+func (c *Clique) Discard(address common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.proposals, address)
+}
+
+// Prepare sets the consensus fields of the header before its transactions are
+// executed: the vote, the difficulty, the extra data and the timestamp
+func (c *Clique) Prepare(parent *types.Header, header *types.Header) error {
+	header.Coinbase = common.Address{}
+	header.Nonce = types.BlockNonce{}
+
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	if parent.Hash() != header.ParentHash {
+		return errUnknownAncestor
+	}
+
+	snap, err := c.snapshot(parent)
+	if err != nil {
+		return err
+	}
+
+	if number%c.config.Epoch != 0 {
+		c.lock.RLock()
+		addresses := make([]common.Address, 0, len(c.proposals))
+		for address, authorize := range c.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there are pending proposals, cast a vote on one of them
+		if len(addresses) > 0 {
+			header.Coinbase = addresses[rand.Intn(len(addresses))]
+			if c.proposals[header.Coinbase] {
+				copy(header.Nonce[:], nonceAuthVote)
+			} else {
+				copy(header.Nonce[:], nonceDropVote)
+			}
+		}
+		c.lock.RUnlock()
+	}
+
+	header.Difficulty = calcDifficulty(snap, c.signer)
+
+	// Ensure the extra data has all its components
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
+	}
+	header.Extra = header.Extra[:extraVanity]
+	if number%c.config.Epoch == 0 {
+		for _, signer := range snap.signers() {
+			header.Extra = append(header.Extra, signer[:]...)
+		}
+	}
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+
+	header.MixDigest = common.Hash{}
+	header.UncleHash = types.EmptyUncleHash
+
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(c.config.Period))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Seal signs the block with the local key once it is the time of the block.
+// The header must have been prepared with Prepare.
+func (c *Clique) Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	// For 0-period chains, refuse to seal empty blocks
+	if c.config.Period == 0 && len(block.Transactions()) == 0 {
+		return nil, consensus.ErrEmptyBlock
+	}
+
+	c.lock.RLock()
+	key, signer := c.key, c.signer
+	c.lock.RUnlock()
+
+	if key == nil {
+		return nil, errNoSignerKey
+	}
+
+	parent, err := c.db.ReadHeader(header.ParentHash)
+	if err != nil {
+		return nil, errUnknownAncestor
+	}
+	snap, err := c.snapshot(parent)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := snap.Signers[signer]; !ok {
+		return nil, errUnauthorizedSigner
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only wait if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); number < limit || seen > number-limit {
+				return nil, errRecentlySigned
+			}
+		}
+	}
+
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+	if header.Difficulty.Cmp(diffNoTurn) == 0 {
+		// It's not our turn explicitly to sign, delay it a bit
+		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
+		delay += time.Duration(rand.Int63n(int64(wiggle)))
+	}
+
+	sig, err := crypto.Sign(sigHash(header).Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+
+	select {
+	case <-stop:
+		return nil, errSealAborted
+	case <-time.After(delay):
+	}
+	return block.WithSeal(header), nil
+}
+
+// calcDifficulty returns the difficulty of the next block after
+// the snapshot for the signer
+func calcDifficulty(snap *Snapshot, signer common.Address) *big.Int {
+	if snap.inturn(snap.Number+1, signer) {
+		return new(big.Int).Set(diffInTurn)
+	}
+	return new(big.Int).Set(diffNoTurn)
+}
+
+// Close closes the connection
+func (c *Clique) Close() error {
+	return nil
+}
@@ -0,0 +1,189 @@
+package clique
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/storage"
+)
+
+func testParams(period uint64) *chain.Params {
+	return &chain.Params{
+		Forks: chain.AllForksEnabled,
+		Engine: map[string]interface{}{
+			"clique": map[string]interface{}{
+				"period": period,
+				"epoch":  30000,
+			},
+		},
+	}
+}
+
+func TestRinkebySigners(t *testing.T) {
+	c, err := chain.ImportFromName("rinkeby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := c.Genesis.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := storage.NewMemoryStorage(nil)
+	engine, err := NewClique(c.Params, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.config.Period != 15 || engine.config.Epoch != 30000 {
+		t.Fatal("bad config")
+	}
+
+	snap, err := engine.snapshot(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []common.Address{
+		common.HexToAddress("0x42eb768f2244c8811c63729a21a3569731535f06"),
+		common.HexToAddress("0x7ffc57839b00206d1ad20c69a1981b489f772031"),
+		common.HexToAddress("0xb279182d99e65703f0076e4812653aab85fca0f0"),
+	}
+	signers := snap.signers()
+	if len(signers) != len(expected) {
+		t.Fatalf("expected %d signers but found %d", len(expected), len(signers))
+	}
+	for i := range signers {
+		if signers[i] != expected[i] {
+			t.Fatalf("expected signer %s but found %s", expected[i].String(), signers[i].String())
+		}
+	}
+
+	// the genesis snapshot is stored
+	if _, err := db.ReadSnapshot(genesis.Hash()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSealAndVerify(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	signers := accounts.addresses([]string{"A", "B"})
+	genesis := &types.Header{
+		Number:     big.NewInt(0),
+		Time:       big.NewInt(0),
+		Difficulty: big.NewInt(1),
+		GasLimit:   5000,
+		UncleHash:  types.EmptyUncleHash,
+		Extra:      make([]byte, extraVanity+2*common.AddressLength+extraSeal),
+	}
+	for i, signer := range signers {
+		copy(genesis.Extra[extraVanity+i*common.AddressLength:], signer[:])
+	}
+
+	db, _ := storage.NewMemoryStorage(nil)
+	if err := db.WriteHeader(genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := NewClique(testParams(1), db, accounts.key("A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   5000,
+	}
+	if err := engine.Prepare(genesis, header); err != nil {
+		t.Fatal(err)
+	}
+	block, err := engine.Seal(types.NewBlockWithHeader(header), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.VerifyHeader(genesis, block.Header(), true); err != nil {
+		t.Fatal(err)
+	}
+	author, err := engine.Author(block.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author != accounts.address("A") {
+		t.Fatal("bad author")
+	}
+
+	// a signer outside the list cannot seal
+	other, err := NewClique(testParams(1), db, accounts.key("C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Seal(types.NewBlockWithHeader(header), nil); err != errUnauthorizedSigner {
+		t.Fatalf("expected unauthorized signer but found %v", err)
+	}
+
+	// nor its blocks are valid
+	forged := block.Header()
+	accounts.sign(forged, "C")
+	if err := engine.VerifyHeader(genesis, forged, true); err != errUnauthorizedSigner {
+		t.Fatalf("expected unauthorized signer but found %v", err)
+	}
+}
+
+func TestStoreCommittedCheckpoints(t *testing.T) {
+	db, _ := storage.NewMemoryStorage(nil)
+	engine, err := NewClique(testParams(1), db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := func(number uint64) (*types.Header, *Snapshot) {
+		header := &types.Header{Number: new(big.Int).SetUint64(number)}
+		return header, newSnapshot(engine.config, engine.signatures, number, header.Hash(), nil)
+	}
+	isStored := func(snap *Snapshot) bool {
+		_, err := db.ReadSnapshot(snap.Hash)
+		return err == nil
+	}
+
+	committed, committedSnap := checkpoint(checkpointInterval)
+	_, rejectedSnap := checkpoint(checkpointInterval)
+	rejectedSnap.Hash = common.HexToHash("1")
+
+	for _, snap := range []*Snapshot{committedSnap, rejectedSnap} {
+		if err := engine.addSnapshot(snap); err != nil {
+			t.Fatal(err)
+		}
+		if isStored(snap) {
+			t.Fatal("the checkpoint should not be stored before its header is committed")
+		}
+	}
+
+	// the checkpoint is stored once its header is committed
+	if err := db.WriteHeader(committed); err != nil {
+		t.Fatal(err)
+	}
+	_, next := checkpoint(checkpointInterval + 1)
+	if err := engine.addSnapshot(next); err != nil {
+		t.Fatal(err)
+	}
+	if !isStored(committedSnap) {
+		t.Fatal("the checkpoint should be stored")
+	}
+	if len(engine.pending) != 1 {
+		t.Fatalf("expected 1 pending checkpoint but found %d", len(engine.pending))
+	}
+
+	// the rejected checkpoint is dropped an interval later
+	_, next = checkpoint(2*checkpointInterval + 1)
+	if err := engine.addSnapshot(next); err != nil {
+		t.Fatal(err)
+	}
+	if isStored(rejectedSnap) || len(engine.pending) != 0 {
+		t.Fatal("the rejected checkpoint should be dropped")
+	}
+}
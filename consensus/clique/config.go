@@ -0,0 +1,20 @@
+package clique
+
+// Config for clique engine
+type Config struct {
+	// Period is the minimum number of seconds between blocks
+	Period uint64 `json:"period"`
+
+	// Epoch is the number of blocks after which the votes are reset
+	// and the list of signers is checkpointed in the header
+	Epoch uint64 `json:"epoch"`
+}
+
+// DefaultConfig is the default clique config
+func DefaultConfig() *Config {
+	c := &Config{
+		Period: 15,
+		Epoch:  30000,
+	}
+	return c
+}
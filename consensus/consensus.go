@@ -1,7 +1,10 @@
 package consensus
 
 import (
+	"errors"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -10,12 +13,41 @@ type Consensus interface {
 	// VerifyHeader verifies the header is correct
 	VerifyHeader(parent *types.Header, header *types.Header, seal bool) error
 
+	// VerifyUncles verifies the uncles of the block are correct
+	VerifyUncles(chain ChainReader, block *types.Block) error
+
 	// Author checks the author of the header
 	Author(header *types.Header) (common.Address, error)
 
-	// Seal seals the block
-	Seal(block *types.Block) error
+	// Finalize applies the engine changes to the state after the transactions
+	// of the block are executed (i.e. block rewards)
+	Finalize(state *state.StateDB, block *types.Block) error
+
+	// Seal seals the block. The sealing is aborted if the stop channel is closed.
+	Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error)
 
 	// Close closes the connection
 	Close() error
 }
+
+// ErrEmptyBlock is returned by the engines that do not seal blocks
+// without transactions
+var ErrEmptyBlock = errors.New("sealing paused, waiting for transactions")
+
+// Sealer is implemented by the engines that can produce new blocks
+type Sealer interface {
+	Consensus
+
+	// Prepare sets the consensus fields of the header before its
+	// transactions are executed
+	Prepare(parent *types.Header, header *types.Header) error
+}
+
+// ChainReader reads the headers and bodies of the chain
+type ChainReader interface {
+	// ReadHeader reads the header by its hash
+	ReadHeader(hash common.Hash) (*types.Header, error)
+
+	// ReadBody reads the body of the block by its hash
+	ReadBody(hash common.Hash) (*types.Body, error)
+}
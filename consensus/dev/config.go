@@ -0,0 +1,16 @@
+package dev
+
+// Config for dev engine
+type Config struct {
+	// Period is the number of seconds between blocks. If zero, a block is
+	// sealed as soon as there are transactions to include.
+	Period uint64 `json:"period"`
+}
+
+// DefaultConfig is the default dev config
+func DefaultConfig() *Config {
+	c := &Config{
+		Period: 0,
+	}
+	return c
+}
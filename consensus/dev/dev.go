@@ -0,0 +1,120 @@
+package dev
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/consensus"
+)
+
+var (
+	errUnknownBlock     = errors.New("unknown block")
+	errInvalidNumber    = errors.New("invalid block number")
+	errInvalidTimestamp = errors.New("invalid timestamp")
+	errInvalidGasUsed   = errors.New("gas used above the gas limit")
+	errUnclesNotAllowed = errors.New("uncles not allowed")
+	errSealAborted      = errors.New("sealing aborted")
+)
+
+// Dev is a consensus engine for local development chains. It seals the blocks
+// instantly, without any proof, either once there are transactions to include
+// or on a fixed period. Only meant to be used with the dev chain.
+type Dev struct {
+	config *Config
+}
+
+// NewDev creates a new dev consensus from the engine config of the chain
+func NewDev(params *chain.Params) (*Dev, error) {
+	config := DefaultConfig()
+
+	data, err := json.Marshal(params.EngineConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return &Dev{config: config}, nil
+}
+
+// VerifyHeader verifies the header is correct. There are no difficulty
+// or seal checks, only the link with the parent.
+func (d *Dev) VerifyHeader(parent *types.Header, header *types.Header, seal bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	if header.Number.Uint64() != parent.Number.Uint64()+1 {
+		return errInvalidNumber
+	}
+	if header.Time.Cmp(parent.Time) < 0 {
+		return errInvalidTimestamp
+	}
+	if header.GasUsed > header.GasLimit {
+		return errInvalidGasUsed
+	}
+	return nil
+}
+
+// VerifyUncles verifies the block does not have uncles
+func (d *Dev) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errUnclesNotAllowed
+	}
+	return nil
+}
+
+// Finalize does not modify the state since there are no block rewards
+func (d *Dev) Finalize(state *state.StateDB, block *types.Block) error {
+	return nil
+}
+
+// Author returns the coinbase of the header
+func (d *Dev) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+// Prepare sets the consensus fields of the header. The timestamp is the
+// current time but never before the parent plus the period.
+func (d *Dev) Prepare(parent *types.Header, header *types.Header) error {
+	header.Difficulty = big.NewInt(1)
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = common.Hash{}
+
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(d.config.Period))
+	if now := big.NewInt(time.Now().Unix()); header.Time.Cmp(now) < 0 {
+		header.Time = now
+	}
+	return nil
+}
+
+// Seal seals the block. With a zero period the block is sealed right away
+// if it has transactions, otherwise it waits until the time of the header.
+func (d *Dev) Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	if d.config.Period == 0 {
+		if len(block.Transactions()) == 0 {
+			return nil, consensus.ErrEmptyBlock
+		}
+		return block.WithSeal(header), nil
+	}
+
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+	select {
+	case <-stop:
+		return nil, errSealAborted
+	case <-time.After(delay):
+	}
+	return block.WithSeal(header), nil
+}
+
+// Close closes the connection
+func (d *Dev) Close() error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package dev
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/consensus"
+)
+
+func newTestDev(t *testing.T, period uint64) *Dev {
+	params := &chain.Params{
+		Engine: map[string]interface{}{
+			"dev": map[string]interface{}{
+				"period": period,
+			},
+		},
+	}
+	d, err := NewDev(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func newTestBlock(t *testing.T, d *Dev, txs []*types.Transaction) (*types.Header, *types.Block) {
+	parent := &types.Header{Number: big.NewInt(0), Time: big.NewInt(time.Now().Unix())}
+
+	header := &types.Header{Number: big.NewInt(1), ParentHash: parent.Hash(), GasLimit: 8000000}
+	if err := d.Prepare(parent, header); err != nil {
+		t.Fatal(err)
+	}
+	return parent, types.NewBlock(header, txs, nil, nil)
+}
+
+func TestSealInstant(t *testing.T) {
+	d := newTestDev(t, 0)
+
+	_, block := newTestBlock(t, d, nil)
+	if _, err := d.Seal(block, nil); err != consensus.ErrEmptyBlock {
+		t.Fatalf("expected empty block error but found %v", err)
+	}
+
+	tx := types.NewTransaction(0, common.HexToAddress("1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	parent, block := newTestBlock(t, d, []*types.Transaction{tx})
+
+	sealed, err := d.Seal(block, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.VerifyHeader(parent, sealed.Header(), true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSealPeriod(t *testing.T) {
+	d := newTestDev(t, 1)
+
+	parent, block := newTestBlock(t, d, nil)
+	if block.Time().Cmp(new(big.Int).Add(parent.Time, big.NewInt(1))) != 0 {
+		t.Fatal("the timestamp should be one period after the parent")
+	}
+
+	sealed, err := d.Seal(block, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.VerifyHeader(parent, sealed.Header(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// abort a block in the future
+	d = newTestDev(t, 60)
+	_, block = newTestBlock(t, d, nil)
+
+	stop := make(chan struct{})
+	close(stop)
+
+	if _, err := d.Seal(block, stop); err != errSealAborted {
+		t.Fatalf("expected seal aborted but found %v", err)
+	}
+}
+
+func TestVerifyHeader(t *testing.T) {
+	d := newTestDev(t, 0)
+	parent := &types.Header{Number: big.NewInt(10), Time: big.NewInt(100)}
+
+	cases := []struct {
+		header *types.Header
+		err    error
+	}{
+		{&types.Header{Number: big.NewInt(11), Time: big.NewInt(100)}, nil},
+		{&types.Header{Number: big.NewInt(12), Time: big.NewInt(100)}, errInvalidNumber},
+		{&types.Header{Number: big.NewInt(11), Time: big.NewInt(99)}, errInvalidTimestamp},
+		{&types.Header{Number: big.NewInt(11), Time: big.NewInt(100), GasUsed: 1}, errInvalidGasUsed},
+	}
+
+	for _, c := range cases {
+		if err := d.VerifyHeader(parent, c.header, true); err != c.err {
+			t.Fatalf("expected %v but found %v", c.err, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package ethash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// testCacheSize is the size of the verification cache in test mode
+	testCacheSize = 1024
+	// testDatasetSize is the size of the mining dataset in test mode
+	testDatasetSize = 32 * 1024
+)
+
+// cache is the verification cache of an epoch
+type cache struct {
+	epoch uint64
+	cache []uint32
+	once  sync.Once
+}
+
+// generate creates the cache or loads it from disk if it was already
+// generated in the directory. Only the last limit caches are kept on disk,
+// none if the limit is zero.
+// Storing the cache on disk is best effort, the cache in memory is used
+// even if it cannot be written.
+func (c *cache) generate(dir string, limit int, test bool, logger *log.Logger) {
+	c.once.Do(func() {
+		size := cacheSize(c.epoch*epochLength + 1)
+		seed := seedHash(c.epoch*epochLength + 1)
+		if test {
+			size = testCacheSize
+		}
+
+		c.cache = make([]uint32, size/4)
+		if dir == "" || limit < 1 || test {
+			generateCache(c.cache, seed)
+			return
+		}
+
+		path := filepath.Join(dir, cacheFilename(seed))
+		if err := loadFile(path, c.cache); err == nil {
+			return
+		}
+		generateCache(c.cache, seed)
+
+		if err := dumpFile(path, c.cache); err != nil {
+			logger.Printf("[WARN] ethash: failed to write the cache of epoch %d: %v", c.epoch, err)
+			return
+		}
+
+		// remove the cache that left the on disk window
+		if c.epoch >= uint64(limit) {
+			os.Remove(filepath.Join(dir, cacheFilename(seedHash((c.epoch-uint64(limit))*epochLength+1))))
+		}
+	})
+}
+
+func cacheFilename(seed []byte) string {
+	return fmt.Sprintf("cache-%x", seed[:8])
+}
+
+// loadFile reads a cache or a dataset stored in little endian order
+func loadFile(path string, dest []uint32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != int64(len(dest)*4) {
+		return fmt.Errorf("file %s has %d bytes but expected %d", path, info.Size(), len(dest)*4)
+	}
+
+	r := bufio.NewReader(f)
+	buf := make([]byte, 4)
+	for i := 0; i < len(dest); i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		dest[i] = binary.LittleEndian.Uint32(buf)
+	}
+	return nil
+}
+
+// dumpFile writes a cache or a dataset in little endian order. The data is
+// written to a temporary file first so that other processes never read it half done.
+func dumpFile(path string, data []uint32) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	buf := make([]byte, 4)
+	for _, val := range data {
+		binary.LittleEndian.PutUint32(buf, val)
+		if _, err := w.Write(buf); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// caches is a lru of the most recently used verification caches
+type caches struct {
+	dir    string
+	limit  int
+	onDisk int
+	test   bool
+	logger *log.Logger
+
+	lock  sync.Mutex
+	items map[uint64]*cache
+	order []uint64
+}
+
+func newCaches(config *Config) *caches {
+	limit := config.CachesInMem
+	if limit < 1 {
+		limit = 1
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &caches{
+		dir:    config.CacheDir,
+		limit:  limit,
+		onDisk: config.CachesOnDisk,
+		test:   config.Test,
+		logger: logger,
+		items:  map[uint64]*cache{},
+	}
+}
+
+// get returns the verification cache of the epoch, generating it if necessary
+func (c *caches) get(epoch uint64) *cache {
+	item := c.lookup(epoch)
+
+	// generate outside the lock, concurrent callers wait on the same cache
+	item.generate(c.dir, c.onDisk, c.test, c.logger)
+	return item
+}
+
+// lookup returns the cache entry of the epoch, adding it and evicting the
+// least recently used one if it is not tracked yet
+func (c *caches) lookup(epoch uint64) *cache {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if item, ok := c.items[epoch]; ok {
+		c.touch(epoch)
+		return item
+	}
+
+	item := &cache{epoch: epoch}
+	c.items[epoch] = item
+	c.order = append(c.order, epoch)
+
+	if len(c.order) > c.limit {
+		delete(c.items, c.order[0])
+		c.order = c.order[1:]
+	}
+	return item
+}
+
+// touch moves the epoch to the most recently used position
+func (c *caches) touch(epoch uint64) {
+	for i, e := range c.order {
+		if e == epoch {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, epoch)
+}
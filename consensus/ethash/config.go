@@ -1,5 +1,47 @@
 package ethash
 
+import (
+	"log"
+	"os"
+)
+
 // Config for ethash engine
 type Config struct {
+	// CacheDir is the directory to store the verification caches.
+	// If empty the caches are only kept in memory.
+	CacheDir string
+
+	// CachesInMem is the number of recent caches to keep in memory
+	CachesInMem int
+
+	// CachesOnDisk is the number of recent caches to keep on disk
+	CachesOnDisk int
+
+	// DatasetDir is the directory to store the mining datasets.
+	// If empty the dataset is only kept in memory.
+	DatasetDir string
+
+	// DatasetsOnDisk is the number of recent datasets to keep on disk
+	DatasetsOnDisk int
+
+	// Threads is the number of mining goroutines. Zero uses all the cpus.
+	Threads int
+
+	// Test uses tiny caches and datasets to mine and verify blocks quickly.
+	// Only meant to be used in tests.
+	Test bool
+
+	// Logger is a logger for operator messages.
+	Logger *log.Logger
+}
+
+// DefaultConfig is the default ethash config
+func DefaultConfig() *Config {
+	c := &Config{
+		CachesInMem:    2,
+		CachesOnDisk:   3,
+		DatasetsOnDisk: 1,
+		Logger:         log.New(os.Stderr, "", log.LstdFlags),
+	}
+	return c
 }
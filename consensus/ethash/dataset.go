@@ -0,0 +1,56 @@
+package ethash
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// dataset is the full mining dataset (DAG) of an epoch
+type dataset struct {
+	epoch   uint64
+	dataset []uint32
+	once    sync.Once
+}
+
+// generate creates the dataset from the verification cache or loads it from disk
+// if it was already generated in the directory. Only the last limit datasets are
+// kept on disk, none if the limit is zero. Like the caches, storing the dataset
+// on disk is best effort.
+func (d *dataset) generate(c *cache, dir string, limit int, test bool, logger *log.Logger) {
+	d.once.Do(func() {
+		size := datasetSize(d.epoch*epochLength + 1)
+		seed := seedHash(d.epoch*epochLength + 1)
+		if test {
+			size = testDatasetSize
+		}
+
+		d.dataset = make([]uint32, size/4)
+		if dir == "" || limit < 1 || test {
+			generateDataset(d.dataset, c.cache)
+			return
+		}
+
+		path := filepath.Join(dir, datasetFilename(seed))
+		if err := loadFile(path, d.dataset); err == nil {
+			return
+		}
+		generateDataset(d.dataset, c.cache)
+
+		if err := dumpFile(path, d.dataset); err != nil {
+			logger.Printf("[WARN] ethash: failed to write the dataset of epoch %d: %v", d.epoch, err)
+			return
+		}
+
+		// remove the dataset that left the on disk window
+		if d.epoch >= uint64(limit) {
+			os.Remove(filepath.Join(dir, datasetFilename(seedHash((d.epoch-uint64(limit))*epochLength+1))))
+		}
+	})
+}
+
+func datasetFilename(seed []byte) string {
+	return fmt.Sprintf("full-%x", seed[:8])
+}
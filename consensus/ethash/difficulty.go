@@ -15,11 +15,26 @@ var (
 	big1          = big.NewInt(1)
 	big2          = big.NewInt(2)
 	big9          = big.NewInt(9)
+	big8          = big.NewInt(8)
 	big10         = big.NewInt(10)
+	big32         = big.NewInt(32)
 	bigMinus99    = big.NewInt(-99)
 )
 
-// calcDifficultyConstantinople is the Constantinople difficulty adjustment algorithm
+// calcDifficultyGrayGlacier is the Gray Glacier difficulty adjustment algorithm (EIP-5133)
+var calcDifficultyGrayGlacier = makeDifficultyCalculator(big.NewInt(11400000))
+
+// calcDifficultyArrowGlacier is the Arrow Glacier difficulty adjustment algorithm (EIP-4345)
+var calcDifficultyArrowGlacier = makeDifficultyCalculator(big.NewInt(10700000))
+
+// calcDifficultyLondon is the London difficulty adjustment algorithm (EIP-3554)
+var calcDifficultyLondon = makeDifficultyCalculator(big.NewInt(9700000))
+
+// calcDifficultyMuirGlacier is the Muir Glacier difficulty adjustment algorithm (EIP-2384)
+var calcDifficultyMuirGlacier = makeDifficultyCalculator(big.NewInt(9000000))
+
+// calcDifficultyConstantinople is the Constantinople difficulty adjustment algorithm.
+// Petersburg and Istanbul keep the same bomb delay.
 var calcDifficultyConstantinople = makeDifficultyCalculator(big.NewInt(5000000))
 
 // calcDifficultyByzantium is the Byzantium difficulty adjustment algorithm
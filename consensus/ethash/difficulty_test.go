@@ -0,0 +1,53 @@
+package ethash
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+)
+
+func TestCalcDifficultyBombDelay(t *testing.T) {
+	// with a block time of 9 seconds and no uncles the difficulty
+	// only changes by the bomb, 2^(periodCount - 2)
+	parent := &types.Header{
+		Number:     big.NewInt(11000000),
+		Time:       big.NewInt(1000),
+		Difficulty: big.NewInt(1000000000),
+		UncleHash:  types.EmptyUncleHash,
+	}
+
+	cases := []struct {
+		forks *chain.Forks
+		exp   int64
+	}{
+		{&chain.Forks{Byzantium: chain.NewFork(0)}, 78},
+		{&chain.Forks{Constantinople: chain.NewFork(0)}, 58},
+		{&chain.Forks{Petersburg: chain.NewFork(0)}, 58},
+		{&chain.Forks{Istanbul: chain.NewFork(0)}, 58},
+		{&chain.Forks{Istanbul: chain.NewFork(0), MuirGlacier: chain.NewFork(0)}, 18},
+		{&chain.Forks{MuirGlacier: chain.NewFork(0), ArrowGlacier: chain.NewFork(0)}, 1},
+		{&chain.Forks{MuirGlacier: chain.NewFork(0), ArrowGlacier: chain.NewFork(11000002)}, 18},
+		{&chain.Forks{MuirGlacier: chain.NewFork(0), London: chain.NewFork(0)}, 11},
+		{&chain.Forks{London: chain.NewFork(0), ArrowGlacier: chain.NewFork(0)}, 1},
+		{&chain.Forks{London: chain.NewFork(0), ArrowGlacier: chain.NewFork(11000002)}, 11},
+	}
+
+	for _, c := range cases {
+		e := NewEthHash(&chain.Params{Forks: c.forks}, nil)
+
+		expected := new(big.Int).Exp(big2, big.NewInt(c.exp), nil)
+		expected.Add(expected, parent.Difficulty)
+
+		if found := e.CalcDifficulty(1009, parent); found.Cmp(expected) != 0 {
+			t.Fatalf("expected %s but found %s", expected, found)
+		}
+	}
+
+	// the gray glacier delay is above the block, there is no bomb yet
+	e := NewEthHash(&chain.Params{Forks: &chain.Forks{ArrowGlacier: chain.NewFork(0), GrayGlacier: chain.NewFork(0)}}, nil)
+	if found := e.CalcDifficulty(1009, parent); found.Cmp(parent.Difficulty) != 0 {
+		t.Fatalf("expected %s but found %s", parent.Difficulty, found)
+	}
+}
@@ -1,15 +1,21 @@
 package ethash
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/consensus"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
@@ -23,16 +29,30 @@ var (
 	maxUncles = 2
 	// allowedFutureBlockTime is the max time from current time allowed for blocks, before they're considered future blocks
 	allowedFutureBlockTime = 15 * time.Second
+	// two256 is a big integer representing 2^256
+	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 )
 
 // EthHash consensus algorithm
 type EthHash struct {
-	config *consensus.ChainConfig
+	config *chain.Params
+	cfg    *Config
+	caches *caches
+
+	datasetLock sync.Mutex
+	dataset     *dataset
 }
 
 // NewEthHash creates a new ethash consensus
-func NewEthHash(config *consensus.ChainConfig) *EthHash {
-	return &EthHash{config}
+func NewEthHash(params *chain.Params, config *Config) *EthHash {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &EthHash{
+		config: params,
+		cfg:    config,
+		caches: newCaches(config),
+	}
 }
 
 // VerifyHeader verifies the header is correct
@@ -75,35 +95,169 @@ func (e *EthHash) VerifyHeader(parent *types.Header, header *types.Header, seal
 
 	// Verify the engine specific seal securing the block
 	if seal {
-		if err := e.verifySeal(header); err != nil {
+		if err := e.VerifySeal(header); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *EthHash) verifySeal(header *types.Header) error {
+// VerifySeal checks the proof-of-work of the header. It does not depend on
+// the other headers so it can be checked concurrently.
+func (e *EthHash) VerifySeal(header *types.Header) error {
+	// Ensure that we have a valid difficulty for the block
+	if header.Difficulty.Sign() <= 0 {
+		return fmt.Errorf("invalid difficulty")
+	}
+
+	number := header.Number.Uint64()
+	cache := e.caches.get(number / epochLength)
+
+	size := datasetSize(number)
+	if e.cfg.Test {
+		size = testDatasetSize
+	}
+
+	digest, result := hashimotoLight(size, cache.cache, e.sealHash(header).Bytes(), header.Nonce.Uint64())
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return fmt.Errorf("invalid mix digest")
+	}
+	target := new(big.Int).Div(two256, header.Difficulty)
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return fmt.Errorf("invalid proof-of-work")
+	}
 	return nil
 }
 
-// Author checks the author of the header
+// sealHash returns the hash of the header without the nonce and the mix digest
+func (e *EthHash) sealHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+
+	rlp.Encode(hasher, []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra,
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// Author returns the coinbase of the header
 func (e *EthHash) Author(header *types.Header) (common.Address, error) {
-	return common.Address{}, nil
+	return header.Coinbase, nil
+}
+
+// VerifyUncles verifies the uncles of the block are valid, recent
+// and not included before
+func (e *EthHash) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > maxUncles {
+		return fmt.Errorf("too many uncles")
+	}
+	if len(block.Uncles()) == 0 {
+		return nil
+	}
+
+	// Gather the set of past uncles and ancestors
+	uncles, ancestors := map[common.Hash]struct{}{}, map[common.Hash]*types.Header{}
+
+	parent := block.ParentHash()
+	for i := 0; i < 7; i++ {
+		ancestor, err := chain.ReadHeader(parent)
+		if err != nil {
+			break
+		}
+		ancestors[parent] = ancestor
+
+		// the genesis does not have a body
+		if body, err := chain.ReadBody(parent); err == nil {
+			for _, uncle := range body.Uncles {
+				uncles[uncle.Hash()] = struct{}{}
+			}
+		}
+		parent = ancestor.ParentHash
+	}
+	ancestors[block.Hash()] = block.Header()
+	uncles[block.Hash()] = struct{}{}
+
+	for _, uncle := range block.Uncles() {
+		hash := uncle.Hash()
+		if _, ok := uncles[hash]; ok {
+			return fmt.Errorf("duplicate uncle")
+		}
+		uncles[hash] = struct{}{}
+
+		if ancestors[hash] != nil {
+			return fmt.Errorf("uncle is ancestor")
+		}
+		if ancestors[uncle.ParentHash] == nil || uncle.ParentHash == block.ParentHash() {
+			return fmt.Errorf("uncle's parent is not ancestor")
+		}
+		if err := e.VerifyHeader(ancestors[uncle.ParentHash], uncle, true); err != nil {
+			return fmt.Errorf("invalid uncle: %v", err)
+		}
+	}
+	return nil
 }
 
-// Seal seals the block
-func (e *EthHash) Seal(block *types.Block) error {
+// Finalize credits the coinbase of the block and the uncles with the mining reward
+func (e *EthHash) Finalize(state *state.StateDB, block *types.Block) error {
+	header := block.Header()
+	number := header.Number.Uint64()
+
+	blockReward := FrontierBlockReward
+	if e.config.Forks.IsByzantium(number) {
+		blockReward = ByzantiumBlockReward
+	}
+	if e.config.Forks.IsConstantinople(number) {
+		blockReward = ConstantinopleBlockReward
+	}
+
+	reward := new(big.Int).Set(blockReward)
+	r := new(big.Int)
+	for _, uncle := range block.Uncles() {
+		r.Add(uncle.Number, big8)
+		r.Sub(r, header.Number)
+		r.Mul(r, blockReward)
+		r.Div(r, big8)
+		state.AddBalance(uncle.Coinbase, r)
+
+		r.Div(blockReward, big32)
+		reward.Add(reward, r)
+	}
+	state.AddBalance(header.Coinbase, reward)
 	return nil
 }
 
+// CalcDifficulty returns the difficulty of the block after the parent
+// using the difficulty bomb delay of the active fork
 func (e *EthHash) CalcDifficulty(time uint64, parent *types.Header) *big.Int {
-	next := new(big.Int).Add(parent.Number, big1)
+	next := parent.Number.Uint64() + 1
+	forks := e.config.Forks
+
 	switch {
-	case e.config.ConstantinopleBlock.Active(next):
+	case forks.IsGrayGlacier(next):
+		return calcDifficultyGrayGlacier(time, parent)
+	case forks.IsArrowGlacier(next):
+		return calcDifficultyArrowGlacier(time, parent)
+	case forks.IsLondon(next):
+		return calcDifficultyLondon(time, parent)
+	case forks.IsMuirGlacier(next):
+		return calcDifficultyMuirGlacier(time, parent)
+	case forks.IsIstanbul(next), forks.IsPetersburg(next), forks.IsConstantinople(next):
 		return calcDifficultyConstantinople(time, parent)
-	case e.config.ByzantiumBlock.Active(next):
+	case forks.IsByzantium(next):
 		return calcDifficultyByzantium(time, parent)
-	case e.config.HomesteadBlock.Active(next):
+	case forks.IsHomestead(next):
 		return calcDifficultyHomestead(time, parent)
 	default:
 		return calcDifficultyFrontier(time, parent)
@@ -0,0 +1,259 @@
+package ethash
+
+import (
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/storage"
+)
+
+// mainnetBlock1 is the first block of the mainnet
+func mainnetBlock1() *types.Header {
+	return &types.Header{
+		ParentHash:  common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.HexToAddress("0x05a56e2d52c817161883f50c441c3228cfe54d9f"),
+		Root:        common.HexToHash("0xd67e4d450343046425ae4271474353857ab860dbc0a1dde64b41b5cd3a532bf3"),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(17171480576),
+		Number:      big.NewInt(1),
+		GasLimit:    5000,
+		Time:        big.NewInt(1438269988),
+		Extra:       hexutil.MustDecode("0x476574682f76312e302e302f6c696e75782f676f312e342e32"),
+		MixDigest:   common.HexToHash("0x969b900de27b6ac6a67742365dd65f55a0526c41fd18e1b16f1a1215c2e66f59"),
+		Nonce:       types.EncodeNonce(0x539bd4979fef1ec4),
+	}
+}
+
+func TestVerifySeal(t *testing.T) {
+	header := mainnetBlock1()
+	if header.Hash() != common.HexToHash("0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6") {
+		t.Fatal("bad block 1 hash")
+	}
+
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, nil)
+	if err := e.VerifySeal(header); err != nil {
+		t.Fatal(err)
+	}
+
+	// forged nonce
+	header.Nonce = types.EncodeNonce(0x539bd4979fef1ec5)
+	if err := e.VerifySeal(header); err == nil {
+		t.Fatal("it should fail with a bad nonce")
+	}
+
+	// forged mix digest
+	header = mainnetBlock1()
+	header.MixDigest = common.Hash{0x1}
+	if err := e.VerifySeal(header); err == nil {
+		t.Fatal("it should fail with a bad mix digest")
+	}
+
+	// harder difficulty
+	header = mainnetBlock1()
+	header.Difficulty = new(big.Int).Mul(header.Difficulty, big.NewInt(1000000))
+	if err := e.VerifySeal(header); err == nil {
+		t.Fatal("it should fail with a higher difficulty")
+	}
+}
+
+func TestCacheOnDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minimal-ethash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig()
+	config.CacheDir = dir
+
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, config)
+	if err := e.VerifySeal(mainnetBlock1()); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, cacheFilename(seedHash(1)))
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new engine loads the cache from disk
+	e = NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, config)
+	if err := e.VerifySeal(mainnetBlock1()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheOnDiskFails(t *testing.T) {
+	f, err := ioutil.TempFile("", "minimal-ethash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// the directory cannot be created under a file
+	config := DefaultConfig()
+	config.CacheDir = filepath.Join(f.Name(), "ethash")
+	config.Logger = log.New(ioutil.Discard, "", 0)
+
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, config)
+	for i := 0; i < 2; i++ {
+		if err := e.VerifySeal(mainnetBlock1()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCacheNotOnDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minimal-ethash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig()
+	config.CacheDir = dir
+	config.CachesOnDisk = 0
+
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, config)
+	if err := e.VerifySeal(mainnetBlock1()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected no caches on disk but found %d", len(files))
+	}
+}
+
+func TestCachesLRU(t *testing.T) {
+	c := newCaches(&Config{CachesInMem: 2})
+
+	for _, epoch := range []uint64{0, 1, 0, 2} {
+		c.lookup(epoch)
+	}
+
+	if _, ok := c.items[1]; ok {
+		t.Fatal("epoch 1 should have been evicted")
+	}
+	if _, ok := c.items[0]; !ok {
+		t.Fatal("epoch 0 should be in the cache")
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	coinbase := common.HexToAddress("1")
+	uncleCoinbase := common.HexToAddress("2")
+
+	header := &types.Header{Number: big.NewInt(10), Coinbase: coinbase}
+	uncle := &types.Header{Number: big.NewInt(9), Coinbase: uncleCoinbase}
+	block := types.NewBlock(header, nil, []*types.Header{uncle}, nil)
+
+	cases := []struct {
+		forks  *chain.Forks
+		reward *big.Int
+	}{
+		{&chain.Forks{}, FrontierBlockReward},
+		{&chain.Forks{Byzantium: chain.NewFork(0)}, ByzantiumBlockReward},
+		{&chain.Forks{Byzantium: chain.NewFork(0), Constantinople: chain.NewFork(0)}, ConstantinopleBlockReward},
+	}
+
+	for _, c := range cases {
+		statedb, err := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e := NewEthHash(&chain.Params{Forks: c.forks}, nil)
+		if err := e.Finalize(statedb, block); err != nil {
+			t.Fatal(err)
+		}
+
+		// the miner gets 1/32 of the reward per uncle
+		expected := new(big.Int).Add(c.reward, new(big.Int).Div(c.reward, big32))
+		if balance := statedb.GetBalance(coinbase); balance.Cmp(expected) != 0 {
+			t.Fatalf("expected coinbase balance %s but found %s", expected, balance)
+		}
+
+		// the uncle one block behind gets 7/8 of the reward
+		expected = new(big.Int).Div(new(big.Int).Mul(c.reward, big.NewInt(7)), big8)
+		if balance := statedb.GetBalance(uncleCoinbase); balance.Cmp(expected) != 0 {
+			t.Fatalf("expected uncle balance %s but found %s", expected, balance)
+		}
+	}
+}
+
+func TestAuthor(t *testing.T) {
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, nil)
+
+	header := mainnetBlock1()
+	author, err := e.Author(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author != header.Coinbase {
+		t.Fatal("the author is the coinbase")
+	}
+}
+
+func TestVerifyUncles(t *testing.T) {
+	db, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := &types.Header{Number: big.NewInt(0)}
+	header1 := &types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash()}
+	for _, h := range []*types.Header{genesis, header1} {
+		if err := db.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, nil)
+
+	newBlock := func(uncles ...*types.Header) *types.Block {
+		header := &types.Header{Number: big.NewInt(2), ParentHash: header1.Hash()}
+		return types.NewBlock(header, nil, uncles, nil)
+	}
+
+	uncle := func(seed int64) *types.Header {
+		return &types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), GasLimit: uint64(seed)}
+	}
+
+	if err := e.VerifyUncles(db, newBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.VerifyUncles(db, newBlock(uncle(1), uncle(2), uncle(3))); err == nil {
+		t.Fatal("it should fail with too many uncles")
+	}
+	if err := e.VerifyUncles(db, newBlock(uncle(1), uncle(1))); err == nil {
+		t.Fatal("it should fail with duplicated uncles")
+	}
+	if err := e.VerifyUncles(db, newBlock(header1)); err == nil {
+		t.Fatal("it should fail if the uncle is an ancestor")
+	}
+
+	// the parent of the uncle must be an ancestor but not the parent of the block
+	if err := e.VerifyUncles(db, newBlock(&types.Header{Number: big.NewInt(2), ParentHash: header1.Hash()})); err == nil {
+		t.Fatal("it should fail if the uncle is a sibling")
+	}
+	if err := e.VerifyUncles(db, newBlock(&types.Header{Number: big.NewInt(1), ParentHash: common.HexToHash("1")})); err == nil {
+		t.Fatal("it should fail if the uncle is dangling")
+	}
+}
@@ -0,0 +1,113 @@
+package ethash
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Based on geth ethash sealer.go
+
+var errSealAborted = fmt.Errorf("sealing aborted")
+
+// Seal searches a nonce for the block that satisfies its difficulty. The search
+// runs on several goroutines and it is aborted if the stop channel is closed,
+// for example, when a new head arrives.
+func (e *EthHash) Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+	if header.Difficulty.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid difficulty")
+	}
+
+	dataset := e.getDataset(header.Number.Uint64() / epochLength)
+
+	threads := e.cfg.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+
+	// each worker starts from a random nonce
+	seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	r := rand.New(rand.NewSource(seed.Int64()))
+
+	abort := make(chan struct{})
+	found := make(chan *types.Header)
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(nonce uint64) {
+			defer wg.Done()
+			e.mine(header, dataset, nonce, abort, found)
+		}(uint64(r.Int63()))
+	}
+
+	var result *types.Header
+	select {
+	case <-stop:
+	case result = <-found:
+	}
+	close(abort)
+	wg.Wait()
+
+	if result == nil {
+		return nil, errSealAborted
+	}
+	return block.WithSeal(result), nil
+}
+
+// mine searches nonces starting from seed until one satisfies the difficulty
+// of the header or the search is aborted
+func (e *EthHash) mine(header *types.Header, dataset *dataset, seed uint64, abort chan struct{}, found chan *types.Header) {
+	var (
+		hash   = e.sealHash(header).Bytes()
+		target = new(big.Int).Div(two256, header.Difficulty)
+		nonce  = seed
+	)
+
+	for {
+		select {
+		case <-abort:
+			return
+		default:
+		}
+
+		digest, result := hashimotoFull(dataset.dataset, hash, nonce)
+		if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+			sealed := types.CopyHeader(header)
+			sealed.Nonce = types.EncodeNonce(nonce)
+			sealed.MixDigest.SetBytes(digest)
+
+			select {
+			case found <- sealed:
+			case <-abort:
+			}
+			return
+		}
+		nonce++
+	}
+}
+
+// getDataset returns the mining dataset of the epoch. Only the dataset
+// of the current epoch is kept in memory.
+func (e *EthHash) getDataset(epoch uint64) *dataset {
+	e.datasetLock.Lock()
+	d := e.dataset
+	if d == nil || d.epoch != epoch {
+		d = &dataset{epoch: epoch}
+		e.dataset = d
+	}
+	e.datasetLock.Unlock()
+
+	d.generate(e.caches.get(epoch), e.cfg.DatasetDir, e.cfg.DatasetsOnDisk, e.cfg.Test, e.caches.logger)
+	return d
+}
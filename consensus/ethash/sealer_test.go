@@ -0,0 +1,61 @@
+package ethash
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/chain"
+)
+
+func newTestEthHash() *EthHash {
+	config := DefaultConfig()
+	config.Test = true
+	config.Threads = 2
+
+	return NewEthHash(&chain.Params{Forks: chain.AllForksEnabled}, config)
+}
+
+func TestSeal(t *testing.T) {
+	e := newTestEthHash()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	block, err := e.Seal(types.NewBlockWithHeader(header), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.VerifySeal(block.Header()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the seal is not valid if the header changes
+	sealed := block.Header()
+	sealed.Extra = []byte{0x1}
+	if err := e.VerifySeal(sealed); err == nil {
+		t.Fatal("it should fail")
+	}
+}
+
+func TestSealAbort(t *testing.T) {
+	e := newTestEthHash()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+
+	stop := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := e.Seal(types.NewBlockWithHeader(header), stop)
+		errCh <- err
+	}()
+
+	close(stop)
+	select {
+	case err := <-errCh:
+		if err != errSealAborted {
+			t.Fatalf("expected seal aborted but found %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sealing not aborted")
+	}
+}
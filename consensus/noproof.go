@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -18,14 +19,24 @@ func (n *NoProof) VerifyHeader(parent *types.Header, header *types.Header, seal
 	return nil
 }
 
+// VerifyUncles verifies the uncles of the block are correct
+func (n *NoProof) VerifyUncles(chain ChainReader, block *types.Block) error {
+	return nil
+}
+
+// Finalize does not modify the state
+func (n *NoProof) Finalize(state *state.StateDB, block *types.Block) error {
+	return nil
+}
+
 // Author checks the author of the header
 func (n *NoProof) Author(header *types.Header) (common.Address, error) {
 	return common.Address{}, err
 }
 
 // Seal seals the block
-func (n *NoProof) Seal(block *types.Block) error {
-	return err
+func (n *NoProof) Seal(block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	return nil, err
 }
 
 // Close closes the connection
@@ -0,0 +1,128 @@
+package consensus
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SealVerifier is implemented by the engines that can verify the seal of a
+// header without the rest of the chain
+type SealVerifier interface {
+	// VerifySeal verifies the seal of the header
+	VerifySeal(header *types.Header) error
+}
+
+// VerifyHeaders verifies a chain of headers where the first one is the child of
+// parent. If the engine is a SealVerifier, the seals are checked concurrently
+// by a pool of workers and the rest of the checks run in order. The results
+// are sent in the order of the headers and the verification stops after the
+// first failure or once the abort channel is closed.
+func VerifyHeaders(engine Consensus, parent *types.Header, headers []*types.Header, workers int) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	parentOf := func(i int) *types.Header {
+		if i == 0 {
+			return parent
+		}
+		return headers[i-1]
+	}
+
+	sealer, ok := engine.(SealVerifier)
+	if !ok {
+		go func() {
+			defer close(results)
+			for i, header := range headers {
+				select {
+				case <-abort:
+					return
+				default:
+				}
+				err := engine.VerifyHeader(parentOf(i), header, true)
+				results <- err
+				if err != nil {
+					return
+				}
+			}
+		}()
+		return abort, results
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		inputs = make(chan int)
+		done   = make(chan int, workers)
+		quit   = make(chan struct{})
+		seals  = make([]error, len(headers))
+	)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				seals[index] = sealer.VerifySeal(headers[index])
+				select {
+				case done <- index:
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		var (
+			in, out int
+			feed    = inputs
+			checked = make([]bool, len(headers))
+		)
+
+		defer close(results)
+		defer close(quit)
+		defer func() {
+			if in < len(headers) {
+				close(inputs)
+			}
+		}()
+
+		if len(headers) == 0 {
+			return
+		}
+
+		for {
+			select {
+			case feed <- in:
+				if in++; in == len(headers) {
+					// all the headers are scheduled, stop sending inputs
+					close(inputs)
+					feed = nil
+				}
+
+			case index := <-done:
+				checked[index] = true
+
+				// send the results in order once the previous seals are checked
+				for out < len(headers) && checked[out] {
+					err := seals[out]
+					if err == nil {
+						err = engine.VerifyHeader(parentOf(out), headers[out], false)
+					}
+					results <- err
+					if err != nil {
+						return
+					}
+					out++
+				}
+				if out == len(headers) {
+					return
+				}
+
+			case <-abort:
+				return
+			}
+		}
+	}()
+
+	return abort, results
+}
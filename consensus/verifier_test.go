@@ -0,0 +1,107 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type testSealEngine struct {
+	NoProof
+	failSeal   uint64
+	failHeader uint64
+	seals      int32
+}
+
+func (e *testSealEngine) VerifyHeader(parent *types.Header, header *types.Header, seal bool) error {
+	if header.Number.Uint64() != parent.Number.Uint64()+1 {
+		return fmt.Errorf("bad number")
+	}
+	if header.Number.Uint64() == e.failHeader {
+		return fmt.Errorf("bad header")
+	}
+	return nil
+}
+
+func (e *testSealEngine) VerifySeal(header *types.Header) error {
+	atomic.AddInt32(&e.seals, 1)
+
+	// later headers finish first to check the results are ordered
+	time.Sleep(time.Duration(100-header.Number.Uint64()) * 100 * time.Microsecond)
+	if header.Number.Uint64() == e.failSeal {
+		return fmt.Errorf("bad seal")
+	}
+	return nil
+}
+
+func testHeaders(n int) (*types.Header, []*types.Header) {
+	parent := &types.Header{Number: big.NewInt(0)}
+	headers := []*types.Header{}
+	for i := 1; i <= n; i++ {
+		headers = append(headers, &types.Header{Number: big.NewInt(int64(i))})
+	}
+	return parent, headers
+}
+
+func collect(results <-chan error) []error {
+	errs := []error{}
+	for err := range results {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func TestVerifyHeaders(t *testing.T) {
+	parent, headers := testHeaders(50)
+
+	cases := []struct {
+		engine Consensus
+		valid  int
+	}{
+		{&testSealEngine{}, 50},
+		{&testSealEngine{failSeal: 20}, 19},
+		{&testSealEngine{failHeader: 30}, 29},
+		{&testSealEngine{failSeal: 40, failHeader: 10}, 9},
+		{&NoProof{}, 50},
+	}
+
+	for _, c := range cases {
+		_, results := VerifyHeaders(c.engine, parent, headers, 4)
+
+		errs := collect(results)
+		for i := 0; i < c.valid; i++ {
+			if errs[i] != nil {
+				t.Fatalf("header %d should be valid: %v", i+1, errs[i])
+			}
+		}
+		if c.valid == len(headers) {
+			if len(errs) != len(headers) {
+				t.Fatalf("expected %d results but found %d", len(headers), len(errs))
+			}
+			continue
+		}
+		// it stops after the first failure
+		if len(errs) != c.valid+1 || errs[c.valid] == nil {
+			t.Fatalf("expected a failure after %d headers", c.valid)
+		}
+	}
+}
+
+func TestVerifyHeadersAbort(t *testing.T) {
+	parent, headers := testHeaders(100)
+
+	engine := &testSealEngine{}
+	abort, results := VerifyHeaders(engine, parent, headers, 2)
+	close(abort)
+
+	if errs := collect(results); len(errs) == len(headers) {
+		t.Fatal("it should have aborted")
+	}
+	if seals := atomic.LoadInt32(&engine.seals); seals == int32(len(headers)) {
+		t.Fatal("it should not check all the seals")
+	}
+}
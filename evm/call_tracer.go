@@ -0,0 +1,146 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CallFrame is a call, a create or a selfdestruct in the call tree
+type CallFrame struct {
+	Type         string         `json:"type"`
+	From         common.Address `json:"from"`
+	To           common.Address `json:"to"`
+	Value        *hexutil.Big   `json:"value,omitempty"`
+	Gas          hexutil.Uint64 `json:"gas"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Input        hexutil.Bytes  `json:"input"`
+	Output       hexutil.Bytes  `json:"output,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	RevertReason string         `json:"revertReason,omitempty"`
+	Calls        []*CallFrame   `json:"calls,omitempty"`
+}
+
+func (f *CallFrame) finish(output []byte, gasUsed uint64, err error) {
+	f.GasUsed = hexutil.Uint64(gasUsed)
+	if err != nil {
+		f.Error = err.Error()
+		if err == ErrExecutionReverted {
+			f.Output = output
+			f.RevertReason = unpackRevert(output)
+		}
+		return
+	}
+	f.Output = output
+}
+
+// CallTracer records the tree of calls and creates of the message
+type CallTracer struct {
+	frames []*CallFrame
+	root   *CallFrame
+}
+
+// NewCallTracer creates a new call tracer
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// Result returns the root frame of the call tree
+func (c *CallTracer) Result() *CallFrame {
+	return c.root
+}
+
+// StepConfig implements the Tracer interface
+func (c *CallTracer) StepConfig() StepConfig {
+	// the frames are captured with CaptureEnter and CaptureExit
+	return StepConfig{}
+}
+
+// CaptureStart implements the Tracer interface
+func (c *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := CALL
+	if create {
+		typ = CREATE
+	}
+	c.root = newCallFrame(typ, from, to, input, gas, value)
+	c.frames = []*CallFrame{c.root}
+}
+
+// CaptureState implements the Tracer interface
+func (c *CallTracer) CaptureState(step *Step) {
+}
+
+// CaptureFault implements the Tracer interface
+func (c *CallTracer) CaptureFault(step *Step, err error) {
+}
+
+// CaptureEnd implements the Tracer interface
+func (c *CallTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	c.root.finish(output, gasUsed, err)
+}
+
+// CaptureEnter implements the FrameTracer interface
+func (c *CallTracer) CaptureEnter(op OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	frame := newCallFrame(op, from, to, input, gas, value)
+
+	parent := c.frames[len(c.frames)-1]
+	parent.Calls = append(parent.Calls, frame)
+	c.frames = append(c.frames, frame)
+}
+
+// CaptureExit implements the FrameTracer interface
+func (c *CallTracer) CaptureExit(output []byte, gasLeft uint64, err error) {
+	frame := c.frames[len(c.frames)-1]
+	c.frames = c.frames[:len(c.frames)-1]
+
+	frame.finish(output, uint64(frame.Gas)-gasLeft, err)
+}
+
+func newCallFrame(op OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) *CallFrame {
+	frame := &CallFrame{
+		Type:  op.String(),
+		From:  from,
+		To:    to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	return frame
+}
+
+// revertSelector is the selector of Error(string) used to encode revert reasons
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// unpackRevert decodes the revert reason encoded as Error(string),
+// it returns an empty string if the data is not a revert reason
+func unpackRevert(data []byte) string {
+	if len(data) < 4+64 || !bytes.Equal(data[:4], revertSelector) {
+		return ""
+	}
+	data = data[4:]
+
+	offset, ok := abiUint(data[:32])
+	if !ok || offset > uint64(len(data))-32 {
+		return ""
+	}
+	size, ok := abiUint(data[offset : offset+32])
+	if !ok || size > uint64(len(data))-32-offset {
+		return ""
+	}
+	return string(data[offset+32 : offset+32+size])
+}
+
+// abiUint decodes a 32 bytes abi word that fits in an uint64
+func abiUint(word []byte) (uint64, bool) {
+	for _, b := range word[:24] {
+		if b != 0 {
+			return 0, false
+		}
+	}
+	return binary.BigEndian.Uint64(word[24:]), true
+}
@@ -0,0 +1,159 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/umbracle/minimal/chain"
+)
+
+var callTracerForks = chain.ForksInTime{
+	Homestead: true,
+	EIP150:    true,
+	EIP155:    true,
+	EIP158:    true,
+	Byzantium: true,
+}
+
+// revertCode reverts with the reason 'no'
+func revertCode() []byte {
+	code := []byte{
+		byte(PUSH1), 0x64,
+		byte(PUSH1), 0x0c,
+		byte(PUSH1), 0x00,
+		byte(CODECOPY),
+		byte(PUSH1), 0x64,
+		byte(PUSH1), 0x00,
+		byte(REVERT),
+	}
+	reason := make([]byte, 100)
+	copy(reason, revertSelector)
+	reason[4+31] = 0x20
+	reason[4+63] = 0x02
+	copy(reason[4+64:], "no")
+
+	return append(code, reason...)
+}
+
+func traceCalls(t *testing.T, to common.Address, code map[common.Address][]byte) *CallFrame {
+	state := newState(t)
+	for addr, c := range code {
+		state.SetCode(addr, c)
+	}
+
+	tracer := NewCallTracer()
+
+	e := NewEVM(state, &Env{}, callTracerForks, *chain.GasTableEIP158, nil)
+	e.Tracer = tracer
+	e.Call(common.HexToAddress("1000"), to, []byte{0x1}, big.NewInt(0), 100000)
+
+	return tracer.Result()
+}
+
+func TestCallTracer(t *testing.T) {
+	a, b, beneficiary := common.HexToAddress("100"), common.HexToAddress("200"), common.HexToAddress("300")
+
+	code := map[common.Address][]byte{
+		a: {
+			byte(PUSH1), 0x00,
+			byte(PUSH1), 0x00,
+			byte(PUSH1), 0x00,
+			byte(PUSH1), 0x00,
+			byte(PUSH1), 0x00,
+			byte(PUSH2), 0x02, 0x00,
+			byte(GAS),
+			byte(CALL),
+			byte(POP),
+			byte(PUSH2), 0x03, 0x00,
+			byte(SELFDESTRUCT),
+		},
+		b: revertCode(),
+	}
+
+	root := traceCalls(t, a, code)
+	if root.Type != "CALL" || root.From != common.HexToAddress("1000") || root.To != a {
+		t.Fatal("bad root frame")
+	}
+	if root.Error != "" {
+		t.Fatalf("the root should not fail: %s", root.Error)
+	}
+	if len(root.Input) != 1 || root.Input[0] != 0x1 {
+		t.Fatal("bad root input")
+	}
+	if len(root.Calls) != 2 {
+		t.Fatalf("expected 2 calls but found %d", len(root.Calls))
+	}
+
+	call := root.Calls[0]
+	if call.Type != "CALL" || call.From != a || call.To != b {
+		t.Fatal("bad call frame")
+	}
+	if call.Error != ErrExecutionReverted.Error() || call.RevertReason != "no" {
+		t.Fatalf("expected the call to revert with a reason but found '%s' '%s'", call.Error, call.RevertReason)
+	}
+	if call.GasUsed == 0 || call.GasUsed >= call.Gas {
+		t.Fatal("the reverted call should return part of the gas")
+	}
+
+	destruct := root.Calls[1]
+	if destruct.Type != "SELFDESTRUCT" || destruct.From != a || destruct.To != beneficiary {
+		t.Fatal("bad selfdestruct frame")
+	}
+	if destruct.Value == nil || destruct.Value.ToInt().Sign() != 0 {
+		t.Fatal("bad selfdestruct value")
+	}
+}
+
+func TestCallTracerRevert(t *testing.T) {
+	b := common.HexToAddress("200")
+
+	root := traceCalls(t, b, map[common.Address][]byte{b: revertCode()})
+	if root.Error != ErrExecutionReverted.Error() || root.RevertReason != "no" {
+		t.Fatalf("expected the message to revert but found '%s'", root.Error)
+	}
+	if len(root.Calls) != 0 {
+		t.Fatal("there should not be any calls")
+	}
+}
+
+func TestCallTracerCreate(t *testing.T) {
+	// deploys the code 0x01
+	code := []byte{
+		byte(PUSH1), 0x01,
+		byte(PUSH1), 0x00,
+		byte(MSTORE8),
+		byte(PUSH1), 0x01,
+		byte(PUSH1), 0x00,
+		byte(RETURN),
+	}
+
+	tracer := NewCallTracer()
+
+	e := NewEVM(newState(t), &Env{}, callTracerForks, *chain.GasTableEIP158, nil)
+	e.Tracer = tracer
+	if _, _, err := e.Create(common.HexToAddress("1000"), code, big.NewInt(0), 100000); err != nil {
+		t.Fatal(err)
+	}
+
+	root := tracer.Result()
+	if root.Type != "CREATE" || root.Error != "" {
+		t.Fatalf("bad create frame '%s' '%s'", root.Type, root.Error)
+	}
+	if len(root.Output) != 1 || root.Output[0] != 0x01 {
+		t.Fatalf("expected the created code as output but found %s", root.Output)
+	}
+}
+
+func TestCallTracerCreateRevert(t *testing.T) {
+	tracer := NewCallTracer()
+
+	e := NewEVM(newState(t), &Env{}, callTracerForks, *chain.GasTableEIP158, nil)
+	e.Tracer = tracer
+	e.Create(common.HexToAddress("1000"), revertCode(), big.NewInt(0), 100000)
+
+	root := tracer.Result()
+	if root.Error != ErrExecutionReverted.Error() || root.RevertReason != "no" {
+		t.Fatalf("expected the create to revert but found '%s'", root.Error)
+	}
+}
@@ -9,15 +9,14 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/evm/uint256"
 )
 
-// IMPORTANT. Memory access needs more overflow protection, right now, only calls and returns are protected
-
 var (
 	ErrGasConsumed              = fmt.Errorf("gas has been consumed")
 	ErrGasOverflow              = fmt.Errorf("gas overflow")
@@ -30,6 +29,7 @@ var (
 	ErrContractAddressCollision = errors.New("contract address collision")
 	ErrDepth                    = errors.New("max call depth exceeded")
 	ErrOpcodeNotFound           = errors.New("opcode not found")
+	ErrExecutionReverted        = errors.New("execution reverted")
 )
 
 // Gas costs
@@ -59,16 +59,19 @@ const (
 	NetSstoreResetRefund      uint64 = 4800  // Once per SSTORE operation for resetting to the original non-big.NewInt(0) value
 	NetSstoreResetClearRefund uint64 = 19800 // Once per SSTORE operation for resetting to the original big.NewInt(0) value
 
+	SstoreSentryGasEIP2200           uint64 = 2300  // Minimum gas required to be present for an SSTORE call, not consumed
+	NetSstoreNoopGasEIP2200          uint64 = 800   // Once per SSTORE operation if the value doesn't change.
+	NetSstoreDirtyGasEIP2200         uint64 = 800   // Once per SSTORE operation from dirty.
+	NetSstoreResetRefundEIP2200      uint64 = 4200  // Once per SSTORE operation for resetting to the original non-big.NewInt(0) value
+	NetSstoreResetClearRefundEIP2200 uint64 = 19200 // Once per SSTORE operation for resetting to the original big.NewInt(0) value
+
 	MemoryGas    uint64 = 3
 	QuadCoeffDiv uint64 = 512
 )
 
 var (
 	errReadOnly = fmt.Errorf("it is a static call and the state cannot be changed")
-)
-
-var (
-	tt255 = math.BigPow(2, 255)
+	errSentry   = fmt.Errorf("not enough gas for reentrancy sentry")
 )
 
 const StackSize = 2048
@@ -87,6 +90,7 @@ type Env struct {
 	Difficulty *big.Int
 	GasLimit   *big.Int
 	GasPrice   *big.Int
+	ChainID    *big.Int
 }
 
 type CanTransferFunc func(*state.StateDB, common.Address, *big.Int) bool
@@ -100,7 +104,7 @@ type Contract struct {
 
 	// memory
 	memory *Memory
-	stack  []*big.Int
+	stack  []uint256.Int
 	sp     int
 
 	codeAddress common.Address
@@ -129,10 +133,10 @@ func (c *Contract) MemoryLen() *big.Int {
 	return big.NewInt(int64(len(c.memory.store)))
 }
 
-func (c *Contract) validJumpdest(dest *big.Int) bool {
+func (c *Contract) validJumpdest(dest *uint256.Int) bool {
 	udest := dest.Uint64()
 
-	if dest.BitLen() >= 63 || udest >= uint64(len(c.code)) {
+	if !dest.IsUint64() || udest >= uint64(len(c.code)) {
 		return false
 	}
 	if OpCode(c.code[udest]) != JUMPDEST {
@@ -146,8 +150,9 @@ func (c *Contract) Instructions() Instructions {
 	return c.code
 }
 
-func (c *Contract) push(val *big.Int) {
-	c.stack[c.sp] = val
+// push copies val on top of the stack
+func (c *Contract) push(val *uint256.Int) {
+	c.stack = append(c.stack[:c.sp], *val)
 	c.sp++
 }
 
@@ -155,21 +160,22 @@ func (c *Contract) stackAtLeast(n int) bool {
 	return c.sp >= n
 }
 
-func (c *Contract) pop() *big.Int {
+// pop removes the top of the stack. The returned value is only
+// valid until the next push.
+func (c *Contract) pop() *uint256.Int {
 	if c.sp == 0 {
 		return nil
 	}
-	o := c.stack[c.sp-1]
 	c.sp--
-	return o
+	return &c.stack[c.sp]
 }
 
-func (c *Contract) peek() *big.Int {
-	return c.stack[c.sp-1]
+func (c *Contract) peek() *uint256.Int {
+	return &c.stack[c.sp-1]
 }
 
-func (c *Contract) peekAt(n int) *big.Int {
-	return c.stack[c.sp-n]
+func (c *Contract) peekAt(n int) *uint256.Int {
+	return &c.stack[c.sp-n]
 }
 
 func (c *Contract) swap(n int) {
@@ -195,21 +201,20 @@ func (c *Contract) showStack() string {
 
 func newContract(origin common.Address, from common.Address, to common.Address, value *big.Int, gas uint64, code []byte) *Contract {
 	f := &Contract{
-		ip:          -1,
 		code:        code,
 		caller:      from,
 		origin:      origin,
 		codeAddress: to,
 		address:     to,
 		value:       value,
-		stack:       make([]*big.Int, StackSize),
+		stack:       make([]uint256.Int, 0, 16),
 		sp:          0,
 		gas:         gas,
 		input:       []byte{},
 		bitvec:      codeBitmap(code),
 		snapshot:    -1,
 	}
-	f.memory = newMemory(f)
+	f.memory = newMemory()
 	return f
 }
 
@@ -235,8 +240,9 @@ type EVM struct {
 	contracts      []*Contract
 	contractsIndex int
 
-	config   *params.ChainConfig
-	gasTable params.GasTable
+	config    chain.ForksInTime
+	gasTable  chain.GasTable
+	jumpTable *jumpTable
 
 	state *state.StateDB
 	env   *Env
@@ -245,17 +251,25 @@ type EVM struct {
 	CanTransfer CanTransferFunc
 	Transfer    TransferFunc
 
+	// Tracer is notified about the execution if set
+	Tracer Tracer
+
 	returnData []byte
 
+	// callGasTemp is the gas of the next call frame, computed
+	// with the dynamic gas of the call opcodes
+	callGasTemp uint64
+
 	snapshot int
 }
 
 // NewEVM creates a new EVM
-func NewEVM(state *state.StateDB, env *Env, config *params.ChainConfig, gasTable params.GasTable, getHash GetHashByNumber) *EVM {
+func NewEVM(state *state.StateDB, env *Env, config chain.ForksInTime, gasTable chain.GasTable, getHash GetHashByNumber) *EVM {
 	return &EVM{
 		contracts:      make([]*Contract, MaxContracts),
 		config:         config,
 		gasTable:       gasTable,
+		jumpTable:      newJumpTable(config, gasTable),
 		contractsIndex: 0,
 		state:          state,
 		env:            env,
@@ -270,18 +284,26 @@ func NewEVM(state *state.StateDB, env *Env, config *params.ChainConfig, gasTable
 func (e *EVM) Call(caller common.Address, to common.Address, input []byte, value *big.Int, gas uint64) ([]byte, uint64, error) {
 	contract := newContractCall(caller, caller, to, value, gas, e.state.GetCode(to), input)
 
+	if e.Tracer != nil {
+		e.Tracer.CaptureStart(caller, to, false, input, gas, value)
+	}
+
 	if err := e.call(contract, CALL); err != nil {
 		if contract.snapshot != -1 {
 			e.state.RevertToSnapshot(contract.snapshot)
 		}
+		e.captureEnd(contract, gas, STOP, err)
 		return nil, 0, err
 	}
 
-	if err := e.Run(); err != nil {
+	op, err := e.run()
+	if err != nil {
+		e.captureEnd(contract, gas, op, err)
 		return nil, 0, err
 	}
 
 	c := e.currentContract()
+	e.captureEnd(c, gas, op, nil)
 	return e.returnData, c.gas, nil
 }
 
@@ -292,423 +314,80 @@ func (e *EVM) Create(caller common.Address, code []byte, value *big.Int, gas uin
 	address := crypto.CreateAddress(caller, e.state.GetNonce(caller))
 	contract := newContractCreation(caller, caller, address, value, gas, code)
 
+	if e.Tracer != nil {
+		e.Tracer.CaptureStart(caller, address, true, code, gas, value)
+	}
+
 	if err := e.create(contract); err != nil {
 		if contract.snapshot != -1 {
 			e.state.RevertToSnapshot(contract.snapshot)
 		}
+		e.captureEnd(contract, gas, STOP, err)
 		return nil, 0, err
 	}
 
-	if err := e.Run(); err != nil {
+	op, err := e.run()
+	if err != nil {
+		e.captureEnd(contract, gas, op, err)
 		return nil, 0, err
 	}
 
 	c := e.currentContract()
+	e.captureEnd(c, gas, op, nil)
 	return e.returnData, c.gas, nil
 }
 
-func (e *EVM) calculateFixedGasUsage(op OpCode) uint64 {
-	if isPush(op) || isSwap(op) || isDup(op) {
-		return GasFastestStep
-	}
-
-	switch op {
-	case ADD, SUB, LT, GT, SLT, SGT, EQ, ISZERO, AND, XOR, OR, NOT, BYTE, CALLDATALOAD, SHL, SHR, SAR:
-		return GasFastestStep
-
-	case MUL, DIV, SDIV, MOD, SMOD, SIGNEXTEND:
-		return GasFastStep
-
-	case ADDMOD, MULMOD, JUMP:
-		return GasMidStep
-
-	case JUMPI:
-		return GasSlowStep
-
-	case PC, GAS, MSIZE, POP, GASLIMIT, DIFFICULTY, NUMBER, TIMESTAMP, COINBASE, GASPRICE, CODESIZE, CALLDATASIZE, CALLVALUE, CALLER, ORIGIN, ADDRESS, RETURNDATASIZE:
-		return GasQuickStep
-
-	case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
-		return GasFastestStep
-
-	case DUP1, DUP2, DUP3, DUP4, DUP5, DUP6, DUP7, DUP8, DUP9, DUP10, DUP11, DUP12, DUP13, DUP14, DUP15, DUP16:
-		return GasFastestStep
-
-	case SWAP1, SWAP2, SWAP3, SWAP4, SWAP5, SWAP6, SWAP7, SWAP8, SWAP9, SWAP10, SWAP11, SWAP12, SWAP13, SWAP14, SWAP15, SWAP16:
-		return GasFastestStep
-
-	case BALANCE:
-		return e.gasTable.Balance
-
-	case EXTCODEHASH:
-		return e.gasTable.ExtcodeHash
-
-	case EXTCODESIZE:
-		return e.gasTable.ExtcodeSize
-
-	case SLOAD:
-		return e.gasTable.SLoad
-
-	case JUMPDEST:
-		return 1
-
-	case STOP:
-		return 0
-
-	default:
-		return 0
-	}
-}
-
-func isPush(op OpCode) bool {
-	return op >= PUSH1 && op <= PUSH32
-}
-
-func isSwap(op OpCode) bool {
-	return op >= SWAP1 && op <= SWAP16
-}
-
-func isDup(op OpCode) bool {
-	return op >= DUP1 && op <= DUP16
-}
-
 // Run executes the virtual machine
 func (e *EVM) Run() error {
+	_, err := e.run()
+	return err
+}
+
+// run executes the virtual machine and returns the last
+// opcode executed by the first contract
+func (e *EVM) run() (OpCode, error) {
 
 	var op OpCode
+	var step *Step
 
 	for {
 		var vmerr error
 
-		for e.currentContract().ip < len(e.currentContract().Instructions())-1 {
-			e.currentContract().ip++
+		// if the contract runs out of code it stops
+		op = STOP
 
-			ip := e.currentContract().ip
-			ins := e.currentContract().Instructions()
-			op = OpCode(ins[ip])
+		for c := e.currentContract(); c.ip < len(c.code); c = e.currentContract() {
+			op = OpCode(c.code[c.ip])
 
-			// consume gas for those opcodes with fixed gas
-			if gasUsed := e.calculateFixedGasUsage(op); gasUsed != 0 {
-				if !e.currentContract().consumeGas(gasUsed) {
-					vmerr = ErrGasConsumed
-					goto END
-				}
+			if e.Tracer != nil {
+				step = e.newStep(op)
 			}
 
-			switch op {
-			case ADD, MUL, SUB, DIV, SDIV, MOD, SMOD, EXP: // add the other operations
-				val, err := e.executeUnsignedArithmeticOperations(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case ADDMOD, MULMOD:
-				val, err := e.executeModularOperations(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case NOT, ISZERO:
-				val, err := e.executeBitWiseOperations1(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case AND, OR, XOR, BYTE:
-				val, err := e.executeBitWiseOperations2(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case EQ, GT, LT, SLT, SGT:
-				val, err := e.executeComparison(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case SHL, SHR, SAR:
-				if !e.config.IsConstantinople(e.env.Number) {
-					vmerr = ErrOpcodeNotFound
-					goto END
-				}
-
-				val, err := e.executeShiftOperations(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			case SIGNEXTEND:
-				val, err := e.executeSignExtension()
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				if val != nil {
-					e.push(val)
-				}
-
-			// --- context ---
-
-			case ADDRESS, BALANCE, ORIGIN, CALLER, CALLVALUE, CALLDATALOAD, CALLDATASIZE, CODESIZE, EXTCODESIZE, GASPRICE, RETURNDATASIZE:
-				val, err := e.executeContextOperations(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			// --- context memory copy ---
-
-			case EXTCODECOPY:
-				vmerr = e.executeExtCodeCopy()
-
-			case CODECOPY, CALLDATACOPY, RETURNDATACOPY:
-				vmerr = e.executeContextCopyOperations(op)
-
-			// --- block information ---
-
-			case BLOCKHASH, COINBASE, TIMESTAMP, NUMBER, DIFFICULTY, GASLIMIT:
-				val, err := e.executeBlockInformation(op)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(val)
-
-			// Push operations
-
-			case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
-				n := int(op - PUSH) // i.e. PUSH6, n = 6
-
-				var data []byte
-				if ip+1+n > len(ins) {
-					data = common.RightPadBytes(ins[ip+1:len(ins)], n)
-				} else {
-					data = ins[ip+1 : ip+1+n]
-				}
-
-				e.push(big.NewInt(0).SetBytes(data))
-				e.currentContract().ip += n
-
-			// Duplicate operations
-
-			case DUP1, DUP2, DUP3, DUP4, DUP5, DUP6, DUP7, DUP8, DUP9, DUP10, DUP11, DUP12, DUP13, DUP14, DUP15, DUP16:
-				n := int(op - DUP)
-				if !e.stackAtLeast(n) {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-				e.push(e.peekAt(n))
-
-			// Swap operations
-
-			case SWAP1, SWAP2, SWAP3, SWAP4, SWAP5, SWAP6, SWAP7, SWAP8, SWAP9, SWAP10, SWAP11, SWAP12, SWAP13, SWAP14, SWAP15, SWAP16:
-				n := int(op - SWAP)
-				if !e.stackAtLeast(n + 1) {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-				e.swap(n)
-
-			// Logging operations
-
-			case LOG0, LOG1, LOG2, LOG3, LOG4:
-				vmerr = e.executeLogsOperation(op)
-
-			// System operations
-
-			case EXTCODEHASH:
-				vmerr = e.executeExtCodeHashOperation()
-
-			case CREATE, CREATE2:
-				vmerr = e.executeCreateOperation(op)
-
-			case CALL, CALLCODE, DELEGATECALL, STATICCALL:
-				vmerr = e.executeCallOperation(op)
-
-			case REVERT, RETURN:
-				err := e.executeHaltOperations(op)
-				vmerr = err
-				goto END
-
-			case SELFDESTRUCT:
-				if e.inStaticCall() {
-					vmerr = errReadOnly
-					goto END
-				}
-				vmerr = e.selfDestruct()
-				goto END
-
-			case STOP:
-				goto END
-
-			// --- sha3 ---
-
-			case SHA3:
-				vmerr = e.sha3()
-
-			// --- stack ---
-
-			case POP:
-				if n := e.pop(); n == nil {
-					vmerr = ErrStackUnderflow
-				}
-
-			// --- memory ---
-
-			case MLOAD:
-				offset := e.pop()
-
-				data, gas, err := e.currentContract().memory.Get(offset, big.NewInt(32))
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				e.push(big.NewInt(1).SetBytes(data))
-
-				gas, overflow := math.SafeAdd(gas, GasFastestStep)
-				if overflow {
-					vmerr = ErrGasOverflow
-					goto END
-				}
-
-				if !e.currentContract().consumeGas(gas) {
-					vmerr = ErrGasConsumed
-					goto END
-				}
-
-			case MSTORE:
-				// TODO, try to mix mstore8, mstore and mload
-				if !e.stackAtLeast(2) {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-				start, val := e.pop(), e.pop()
-
-				gas, err := e.currentContract().memory.Set32(start, val)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-
-				gas, overflow := math.SafeAdd(gas, GasFastestStep)
-				if overflow {
-					vmerr = ErrGasConsumed
-					goto END
-				}
-
-				if !e.currentContract().consumeGas(gas) {
-					vmerr = ErrGasConsumed
-					goto END
-				}
-
-			case MSTORE8:
-				if !e.stackAtLeast(2) {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-
-				offset, val := e.pop(), e.pop().Int64()
-
-				gas, err := e.currentContract().memory.SetByte(offset, val)
-				if err != nil {
-					vmerr = err
-					goto END
-				}
-				gas, overflow := math.SafeAdd(gas, GasFastestStep)
-				if overflow {
-					vmerr = ErrGasOverflow
-					goto END
-				}
-
-				if !e.currentContract().consumeGas(gas) {
-					vmerr = ErrGasConsumed
-					goto END
-				}
-
-			// --- storage ---
-
-			case SLOAD:
-				loc := e.pop()
-				val := e.state.GetState(e.currentContract().address, common.BigToHash(loc))
-				e.push(val.Big())
-
-			case SSTORE:
-				vmerr = e.executeSStoreOperation()
-
-			// --- flow ---
-
-			case JUMP:
-				dest := e.pop()
-				if dest == nil {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-
-				if !e.currentContract().validJumpdest(dest) {
-					vmerr = ErrJumpDestNotValid
-					goto END
-				}
-				e.currentContract().ip = int(dest.Uint64() - 1)
-
-			case JUMPI:
-				if !e.stackAtLeast(2) {
-					vmerr = ErrStackUnderflow
-					goto END
-				}
-				dest, cond := e.pop(), e.pop()
-
-				if cond.Sign() != 0 {
-					if !e.currentContract().validJumpdest(dest) {
-						vmerr = ErrJumpDestNotValid
-						goto END
-					}
-					e.currentContract().ip = int(dest.Uint64() - 1)
-				}
-
-			case JUMPDEST:
-				// Nothing
-
-			case PC:
-				e.push(big.NewInt(int64(e.currentContract().ip)))
-
-			case MSIZE:
-				e.push(e.currentContract().MemoryLen())
-
-			case GAS:
-				e.push(big.NewInt(int64(e.currentContract().gas)))
+			operation := e.jumpTable[op]
+			if operation == nil {
+				vmerr = ErrOpcodeNotFound
+				break
+			}
 
-			default:
-				if strings.Contains(op.String(), "Missing") {
-					vmerr = ErrOpcodeNotFound
-					goto END
-				}
-				return fmt.Errorf("opcode not found: %s", op.String())
+			vmerr = e.executeOperation(c, operation)
+			if !operation.jumps {
+				c.ip++
 			}
 
-			if e.currentContract().sp > 1024 {
-				vmerr = ErrStackOverflow
-				goto END
+			if step != nil {
+				e.captureStep(step, vmerr)
+				step = nil
 			}
 
-			if vmerr != nil {
+			if vmerr != nil || operation.halts {
 				break
 			}
 		}
 
-	END:
+		if step != nil {
+			e.captureStep(step, vmerr)
+			step = nil
+		}
 
 		// need to handle first the error to consume the gas at least
 		c := e.currentContract()
@@ -728,31 +407,32 @@ func (e *EVM) Run() error {
 				e.state.RevertToSnapshot(e.currentContract().snapshot)
 			}
 
-			return vmerr
+			return op, vmerr
 		}
 
 		// Otherwise, pop the last contract and fill the return fields
 		e.popContract()
 
+		if e.Tracer != nil {
+			e.captureExit(c, op, vmerr)
+		}
+
 		// Set return codes
 		if vmerr != nil || op == REVERT {
-			e.push(big.NewInt(0))
+			e.push(uint256.NewInt(0))
 		} else {
 			if c.creation {
-				e.push(c.address.Big())
+				e.push(new(uint256.Int).SetBytes(c.address[:]))
 			} else {
-				e.push(big.NewInt(1))
+				e.push(uint256.NewInt(1))
 			}
 		}
 
-		// Set the state on memory for the contract calls
+		// Set the state on memory for the contract calls, the memory
+		// was already expanded by the call
 		if !c.creation && vmerr == nil && len(e.returnData) != 0 {
 			// return offset values are stored in the child contract
-			retOffset, retSize := c.retOffset, c.retSize
-
-			if _, err := e.currentContract().memory.Set(big.NewInt(int64(retOffset)), big.NewInt(int64(retSize)), e.returnData); err != nil {
-				panic(fmt.Errorf("This memory error should not happen: %v", err))
-			}
+			e.currentContract().memory.Set(c.retOffset, c.retSize, e.returnData)
 		}
 
 		// Remove return data if there is an error
@@ -776,176 +456,54 @@ func (e *EVM) Run() error {
 	}
 }
 
-func (e *EVM) isLastContract() bool {
-	return e.contractsIndex == 1
-}
-
-func (e *EVM) executeSStoreOperation() error {
-	if e.inStaticCall() {
-		return errReadOnly
-	}
-	if !e.stackAtLeast(2) {
+// executeOperation validates the stack, consumes the gas and expands
+// the memory before the opcode is executed
+func (e *EVM) executeOperation(c *Contract, operation *operation) error {
+	if c.sp < operation.minStack {
 		return ErrStackUnderflow
 	}
-
-	address := e.currentContract().address
-
-	loc, val := e.pop(), e.pop()
-
-	var gas uint64
-
-	current := e.state.GetState(address, common.BigToHash(loc))
-
-	// discount gas (constantinople)
-	if !e.config.IsConstantinople(e.env.Number) {
-		switch {
-		case current == (common.Hash{}) && val.Sign() != 0: // 0 => non 0
-			gas = SstoreSetGas
-		case current != (common.Hash{}) && val.Sign() == 0: // non 0 => 0
-			e.state.AddRefund(SstoreRefundGas)
-			gas = SstoreClearGas
-		default: // non 0 => non 0 (or 0 => 0)
-			gas = SstoreResetGas
-		}
-	} else {
-
-		getGas := func() uint64 {
-			// non constantinople gas
-			value := common.BigToHash(val)
-			if current == value { // noop (1)
-				return NetSstoreNoopGas
-			}
-			original := e.state.GetCommittedState(address, common.BigToHash(loc))
-			if original == current {
-				if original == (common.Hash{}) { // create slot (2.1.1)
-					return NetSstoreInitGas
-				}
-				if value == (common.Hash{}) { // delete slot (2.1.2b)
-					e.state.AddRefund(NetSstoreClearRefund)
-				}
-				return NetSstoreCleanGas // write existing slot (2.1.2)
-			}
-			if original != (common.Hash{}) {
-				if current == (common.Hash{}) { // recreate slot (2.2.1.1)
-					e.state.SubRefund(NetSstoreClearRefund)
-				} else if value == (common.Hash{}) { // delete slot (2.2.1.2)
-					e.state.AddRefund(NetSstoreClearRefund)
-				}
-			}
-			if original == value {
-				if original == (common.Hash{}) { // reset to original inexistent slot (2.2.2.1)
-					e.state.AddRefund(NetSstoreResetClearRefund)
-				} else { // reset to original existing slot (2.2.2.2)
-					e.state.AddRefund(NetSstoreResetRefund)
-				}
-			}
-			return NetSstoreDirtyGas
-		}
-
-		gas = getGas()
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasOverflow
+	if c.sp > operation.maxStack {
+		return ErrStackOverflow
 	}
-
-	e.state.SetState(address, common.BigToHash(loc), common.BigToHash(val))
-	return nil
-}
-
-func (e *EVM) executeLogsOperation(op OpCode) error {
-	if e.inStaticCall() {
+	if c.static && operation.writes {
 		return errReadOnly
 	}
 
-	size := int(op - LOG)
-	topics := make([]common.Hash, size)
-
-	if !e.stackAtLeast(2 + size) {
-		return ErrStackUnderflow
-	}
-
-	mStart, mSize := e.pop(), e.pop()
-	for i := 0; i < size; i++ {
-		topics[i] = common.BigToHash(e.pop())
-	}
-
-	data, gas, err := e.currentContract().memory.Get(mStart, mSize)
-	if err != nil {
-		return err
-	}
-	e.state.AddLog(&types.Log{
-		Address:     e.currentContract().address,
-		Topics:      topics,
-		Data:        data,
-		BlockNumber: e.env.Number.Uint64(),
-	})
-
-	requestedSize, overflow := bigUint64(mSize)
-	if overflow {
-		return ErrGasOverflow
-	}
-
-	if gas, overflow = math.SafeAdd(gas, params.LogGas); overflow {
-		return ErrGasOverflow
-	}
-	if gas, overflow = math.SafeAdd(gas, uint64(size)*params.LogTopicGas); overflow {
-		return ErrGasOverflow
-	}
-
-	var memorySizeGas uint64
-	if memorySizeGas, overflow = math.SafeMul(requestedSize, params.LogDataGas); overflow {
-		return ErrGasOverflow
-	}
-	if gas, overflow = math.SafeAdd(gas, memorySizeGas); overflow {
-		return ErrGasOverflow
-	}
-
-	if !e.currentContract().consumeGas(gas) {
+	if !c.consumeGas(operation.constantGas) {
 		return ErrGasConsumed
 	}
-	return nil
-}
-
-func (e *EVM) sha3() error {
-
-	if !e.stackAtLeast(2) {
-		return ErrStackUnderflow
-	}
-
-	offset, size := e.pop(), e.pop()
-
-	data, gas, err := e.currentContract().memory.Get(offset, size)
-	if err != nil {
-
-		return err
-	}
 
-	hash := crypto.Keccak256Hash(data)
-	e.push(hash.Big())
-
-	var overflow bool
-	if gas, overflow = math.SafeAdd(gas, params.Sha3Gas); overflow {
-		return ErrGasOverflow
+	var memorySize uint64
+	if operation.memorySize != nil {
+		size, overflow := operation.memorySize(c)
+		if overflow {
+			return ErrGasOverflow
+		}
+		// memory is expanded in words of 32 bytes
+		if memorySize, overflow = math.SafeMul(numWords(size), 32); overflow {
+			return ErrGasOverflow
+		}
 	}
 
-	wordGas, overflow := bigUint64(size)
-	if overflow {
-		return ErrGasOverflow
+	if operation.dynamicGas != nil {
+		gas, err := operation.dynamicGas(e, memorySize)
+		if err != nil {
+			return err
+		}
+		if !c.consumeGas(gas) {
+			return ErrGasConsumed
+		}
 	}
 
-	if wordGas, overflow = math.SafeMul(numWords(wordGas), params.Sha3WordGas); overflow {
-		return ErrGasOverflow
+	if memorySize > 0 {
+		c.memory.Resize(memorySize)
 	}
 
-	if gas, overflow = math.SafeAdd(gas, wordGas); overflow {
-		return ErrGasOverflow
-	}
+	return operation.execute(e)
+}
 
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasConsumed
-	}
-	return nil
+func (e *EVM) isLastContract() bool {
+	return e.contractsIndex == 1
 }
 
 func (e *EVM) create(contract *Contract) error {
@@ -978,7 +536,7 @@ func (e *EVM) create(contract *Contract) error {
 
 	// Create the new account for the contract
 	e.state.CreateAccount(address)
-	if e.config.IsEIP158(e.env.Number) {
+	if e.config.EIP158 {
 		e.state.SetNonce(address, 1)
 	}
 
@@ -992,530 +550,76 @@ func (e *EVM) create(contract *Contract) error {
 	return nil
 }
 
-func (e *EVM) buildCreateContract(op OpCode) (*Contract, error) {
-	var expected int
-	if op == CREATE {
-		expected = 3
-	} else if op == CREATE2 {
-		expected = 4
-	} else {
-		panic(fmt.Errorf("Only CREATE or CREATE2 expected: Found %s", op.String()))
-	}
+func (e *EVM) call(contract *Contract, op OpCode) error {
+	e.pushContract(contract)
 
-	if !e.stackAtLeast(expected) {
-		return nil, ErrStackUnderflow
+	// Check if its too deep
+	if e.Depth() > int(params.CallCreateDepth)+1 {
+		return ErrDepth
 	}
 
-	e.returnData = nil
+	// Check if there is enough balance
+	if op == CALL || op == CALLCODE {
+		if !e.CanTransfer(e.state, contract.caller, contract.value) {
+			return ErrNotEnoughFunds
+		}
+	}
 
-	// Pop input arguments
-	value := e.pop()
-	offset, size := e.pop(), e.pop()
+	contract.snapshot = e.state.Snapshot()
 
-	var salt *big.Int
-	if op == CREATE2 {
-		salt = e.pop()
+	// check first if its precompiled
+	precompiledContracts := ContractsHomestead
+	if e.config.Istanbul {
+		precompiledContracts = ContractsIstanbul
+	} else if e.config.Byzantium {
+		precompiledContracts = ContractsByzantium
 	}
 
-	// Calculate and consume gas cost
-
-	var overflow bool
-	var gasCost uint64
+	precompiled, isPrecompiled := precompiledContracts[contract.codeAddress]
 
-	// Both CREATE and CREATE2 use memory
-	input, gasCost, err := e.currentContract().memory.Get(offset, size)
-	if err != nil {
-		return nil, err
-	}
+	if op == CALL {
+		if !e.state.Exist(contract.address) {
+			if !isPrecompiled && e.config.EIP158 && contract.value.Sign() == 0 {
+				// calling an unexisting account
+				return nil
+			}
 
-	gasParam := params.CreateGas
-	if op == CREATE2 {
-		// Need to add the sha3 gas cost
-		wordGas, overflow := bigUint64(size)
-		if overflow {
-			return nil, ErrGasOverflow
+			// Not sure why but the address has to be created for the precompiled contracts
+			e.state.CreateAccount(contract.address)
 		}
-		if wordGas, overflow = math.SafeMul(numWords(wordGas), params.Sha3WordGas); overflow {
-			return nil, ErrGasOverflow
+
+		// Try to transfer
+		if err := e.Transfer(e.state, contract.caller, contract.address, contract.value); err != nil {
+			return err
 		}
-		if gasCost, overflow = math.SafeAdd(gasCost, wordGas); overflow {
-			return nil, ErrGasOverflow
+	}
+
+	if isPrecompiled {
+		if !contract.consumeGas(precompiled.Gas(contract.input)) {
+			return ErrGasOverflow
 		}
 
-		gasParam = params.Create2Gas
-	}
-
-	if gasCost, overflow = math.SafeAdd(gasCost, gasParam); overflow {
-		return nil, ErrGasOverflow
-	}
-
-	if !e.currentContract().consumeGas(gasCost) {
-		return nil, ErrGasOverflow
-	}
-
-	// Calculate and consume gas for the call
-	gas := e.currentContract().gas
-
-	// CREATE2 uses by default EIP150
-	if e.config.IsEIP150(e.env.Number) || op == CREATE2 {
-		gas -= gas / 64
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return nil, ErrGasOverflow
-	}
-
-	// Calculate address
-	var address common.Address
-	if op == CREATE {
-		address = crypto.CreateAddress(e.currentContract().address, e.state.GetNonce(e.currentContract().address))
-	} else {
-		address = crypto.CreateAddress2(e.currentContract().address, common.BigToHash(salt), crypto.Keccak256Hash(input).Bytes())
-	}
-
-	contract := newContractCreation(e.currentContract().origin, e.currentContract().address, address, value, gas, input)
-	return contract, nil
-}
-
-func (e *EVM) executeCreateOperation(op OpCode) error {
-	if e.inStaticCall() {
-		return errReadOnly
-	}
-
-	if op == CREATE2 {
-		if !e.config.IsConstantinople(e.env.Number) {
-			return ErrOpcodeNotFound
-		}
-	}
-
-	contract, err := e.buildCreateContract(op)
-	if err != nil {
-		return err
-	}
-
-	return e.create(contract)
-}
-
-func (e *EVM) executeCallOperation(op OpCode) error {
-	if op == CALL && e.inStaticCall() {
-		if val := e.peekAt(3); val != nil && val.BitLen() > 0 {
-			return errReadOnly
-		}
-	}
-
-	if op == DELEGATECALL && !e.config.IsHomestead(e.env.Number) {
-		return ErrOpcodeNotFound
-	}
-	if op == STATICCALL && !e.config.IsByzantium(e.env.Number) {
-		return ErrOpcodeNotFound
-	}
-
-	contract, err := e.buildCallContract(op)
-	if err != nil {
-		return err
-	}
-
-	return e.call(contract, op)
-}
-
-func (e *EVM) call(contract *Contract, op OpCode) error {
-	e.pushContract(contract)
-
-	// Check if its too deep
-	if e.Depth() > int(params.CallCreateDepth)+1 {
-		return ErrDepth
-	}
-
-	// Check if there is enough balance
-	if op == CALL || op == CALLCODE {
-		if !e.CanTransfer(e.state, contract.caller, contract.value) {
-			return ErrNotEnoughFunds
-		}
-	}
-
-	contract.snapshot = e.state.Snapshot()
-
-	// check first if its precompiled
-	precompiledContracts := ContractsHomestead
-	if e.config.IsByzantium(e.env.Number) {
-		precompiledContracts = ContractsByzantium
-	}
-
-	precompiled, isPrecompiled := precompiledContracts[contract.codeAddress]
-
-	if op == CALL {
-		if !e.state.Exist(contract.address) {
-			if !isPrecompiled && e.config.IsEIP158(e.env.Number) && contract.value.Sign() == 0 {
-				// calling an unexisting account
-				return nil
-			}
-
-			// Not sure why but the address has to be created for the precompiled contracts
-			e.state.CreateAccount(contract.address)
-		}
-
-		// Try to transfer
-		if err := e.Transfer(e.state, contract.caller, contract.address, contract.value); err != nil {
-			return err
-		}
-	}
-
-	if isPrecompiled {
-		if !contract.consumeGas(precompiled.Gas(contract.input)) {
-			return ErrGasOverflow
-		}
-
-		output, err := precompiled.Call(contract.input)
-		if err != nil {
-			return err
-		}
-		e.returnData = output
+		output, err := precompiled.Call(contract.input)
+		if err != nil {
+			return err
+		}
+		e.returnData = output
 	}
 
 	return nil
 }
 
-func (e *EVM) buildCallContract(op OpCode) (*Contract, error) {
-	var expected int
-	if op == CALL || op == CALLCODE {
-		expected = 7
-	} else {
-		expected = 6
-	}
-
-	if !e.stackAtLeast(expected) {
-		return nil, ErrStackUnderflow
-	}
-
-	e.returnData = nil
-
-	// Pop input arguments
-	initialGas := e.pop()
-	addr := common.BigToAddress(e.pop())
-
-	var value *big.Int
-	if op == CALL || op == CALLCODE {
-		value = e.pop()
-	}
-
-	inOffset, inSize := e.pop(), e.pop()
-	retOffset, retSize := e.pop(), e.pop()
-
-	// Calculate and consume gas cost
-
-	// Memory cost needs to consider both input and output resizes (HACK)
-	in := calcMemSize(inOffset, inSize)
-	ret := calcMemSize(retOffset, retSize)
-
-	max := math.BigMax(in, ret)
-
-	memSize, overflow := bigUint64(max)
-	if overflow {
-		return nil, ErrGasOverflow
-	}
-
-	if _, overflow := math.SafeMul(numWords(memSize), 32); overflow {
-		return nil, ErrGasOverflow
-	}
-
-	memoryGas, err := e.currentContract().memory.Resize(max.Uint64())
-	if err != nil {
-		return nil, err
-	}
-
-	args, _, err := e.currentContract().memory.Get(inOffset, inSize)
-	if err != nil {
-		return nil, err
-	}
-
-	gasCost := e.gasTable.Calls
-	eip158 := e.config.IsEIP158(e.env.Number)
-	transfersValue := value != nil && value.Sign() != 0
-
-	if op == CALL {
-		if eip158 {
-			if transfersValue && e.state.Empty(addr) {
-				gasCost += params.CallNewAccountGas
-			}
-		} else if !e.state.Exist(addr) {
-			gasCost += params.CallNewAccountGas
-		}
-	}
-	if op == CALL || op == CALLCODE {
-		if transfersValue {
-			gasCost += params.CallValueTransferGas
-		}
-	}
-
-	if gasCost, overflow = math.SafeAdd(gasCost, memoryGas); overflow {
-		return nil, ErrGasOverflow
-	}
-
-	gas, err := callGas(e.gasTable, e.currentContract().gas, gasCost, initialGas)
-	if err != nil {
-		return nil, err
-	}
-
-	if gasCost, overflow = math.SafeAdd(gasCost, gas); overflow {
-		return nil, ErrGasOverflow
-	}
-
-	// Consume gas cost
-	if !e.currentContract().consumeGas(gasCost) {
-		return nil, ErrGasConsumed
-	}
-
-	if op == CALL || op == CALLCODE {
-		if transfersValue {
-			gas += params.CallStipend
-		}
-	}
-
-	parent := e.currentContract()
-
-	contract := newContractCall(parent.origin, parent.address, addr, value, gas, e.state.GetCode(addr), args)
-	contract.retOffset = retOffset.Uint64()
-	contract.retSize = retSize.Uint64()
-
-	if op == STATICCALL || parent.static {
-		contract.static = true
-	}
-	if op == CALLCODE || op == DELEGATECALL {
-		contract.address = parent.address
-		if op == DELEGATECALL {
-			contract.value = parent.value
-			contract.caller = parent.caller
-		}
-	}
-
-	return contract, nil
-}
-
 func (e *EVM) Depth() int {
 	return e.contractsIndex
 }
 
-func (e *EVM) executeExtCodeHashOperation() error {
-	if !e.config.IsConstantinople(e.env.Number) {
-		return ErrOpcodeNotFound
-	}
-
-	addr := e.pop()
-	if addr == nil {
-		return ErrStackUnderflow
-	}
-
-	address := common.BigToAddress(addr)
-	if e.state.Empty(address) {
-		e.push(big.NewInt(0))
-	} else {
-		e.push(big.NewInt(0).SetBytes(e.state.GetCodeHash(address).Bytes()))
-	}
-
-	return nil
+func toUint64(v *uint256.Int) (uint64, bool) {
+	return v.Uint64(), !v.IsUint64()
 }
 
-func (e *EVM) executeHaltOperations(op OpCode) error {
-	if op == REVERT && !e.config.IsByzantium(e.env.Number) {
-		return ErrOpcodeNotFound
-	}
-
-	e.returnData = nil
-
-	if !e.stackAtLeast(2) {
-		return ErrStackUnderflow
-	}
-
-	offset, size := e.pop(), e.pop()
-
-	if _, overflow := bigUint64(size); overflow {
-		return ErrGasOverflow
-	}
-
-	ret, gas, err := e.currentContract().memory.Get(offset, size)
-	if err != nil {
-		return err
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasConsumed
-	}
-
-	// Return only allowed in calls
-	if !e.currentContract().creation {
-		e.returnData = ret
-	}
-
-	// Return if reverted inside a contract creation
-	if e.currentContract().creation && op == REVERT {
-		e.returnData = ret
-	}
-
-	if op == RETURN {
-		if e.currentContract().creation {
-			maxCodeSizeExceeded := e.config.IsEIP158(e.env.Number) && len(ret) > params.MaxCodeSize
-			if maxCodeSizeExceeded {
-				return ErrMaxCodeSizeExceeded
-			}
-
-			createDataGas := uint64(len(ret)) * params.CreateDataGas
-			if !e.currentContract().consumeGas(createDataGas) {
-				return ErrGasConsumed
-			}
-			e.state.SetCode(e.currentContract().address, ret)
-		}
-	}
-
-	return nil
-}
-
-func (e *EVM) selfDestruct() error {
-
-	addr := e.pop()
-	if addr == nil {
-		return ErrStackUnderflow
-	}
-
-	address := common.BigToAddress(addr)
-
-	// try to remove the gas first
-	var gas uint64
-
-	// EIP150 homestead gas reprice fork:
-	if e.config.IsEIP150(e.env.Number) {
-		gas = e.gasTable.Suicide
-
-		eip158 := e.config.IsEIP158(e.env.Number)
-
-		if eip158 {
-			// if empty and transfers value
-			if e.state.Empty(address) && e.state.GetBalance(e.currentContract().address).Sign() != 0 {
-				gas += e.gasTable.CreateBySuicide
-			}
-		} else if !e.state.Exist(address) {
-			gas += e.gasTable.CreateBySuicide
-		}
-	}
-
-	if !e.state.HasSuicided(e.currentContract().address) {
-		e.state.AddRefund(params.SuicideRefundGas)
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasConsumed
-	}
-
-	balance := e.state.GetBalance(e.currentContract().address)
-	e.state.AddBalance(address, balance)
-	e.state.Suicide(e.currentContract().address)
-
-	return nil
-}
-
-func bigUint64(v *big.Int) (uint64, bool) {
-	return v.Uint64(), v.BitLen() > 64
-}
-
-func (e *EVM) executeExtCodeCopy() error {
-	if !e.stackAtLeast(4) {
-		return ErrStackUnderflow
-	}
-
-	address, memOffset, codeOffset, length := e.pop(), e.pop(), e.pop(), e.pop()
-
-	codeCopy := getSlice(e.state.GetCode(common.BigToAddress(address)), codeOffset, length)
-
-	gas, err := e.currentContract().memory.Set(memOffset, length, codeCopy)
-	if err != nil {
-		return err
-	}
-
-	var overflow bool
-	if gas, overflow = math.SafeAdd(gas, e.gasTable.ExtcodeCopy); overflow {
-		return ErrGasOverflow
-	}
-
-	words, overflow := bigUint64(length)
-	if overflow {
-		return ErrGasOverflow
-	}
-
-	if words, overflow = math.SafeMul(numWords(words), params.CopyGas); overflow {
-		return ErrGasOverflow
-	}
-
-	if gas, overflow = math.SafeAdd(gas, words); overflow {
-		return ErrGasOverflow
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasConsumed
-	}
-	return nil
-}
-
-// copy values to memory
-func (e *EVM) executeContextCopyOperations(op OpCode) error {
-	if !e.stackAtLeast(3) {
-		return ErrStackUnderflow
-	}
-
-	memOffset, dataOffset, length := e.pop(), e.pop(), e.pop()
-
-	var gas uint64
-	var err error
-
-	switch op {
-	case CALLDATACOPY:
-		gas, err = e.currentContract().memory.Set(memOffset, length, getSlice(e.currentContract().input, dataOffset, length))
-
-	case RETURNDATACOPY:
-		if !e.config.IsByzantium(e.env.Number) {
-			return ErrOpcodeNotFound
-		}
-
-		end := big.NewInt(1).Add(dataOffset, length)
-		if end.BitLen() > 64 || uint64(len(e.returnData)) < end.Uint64() {
-			return fmt.Errorf("out of bounds")
-		}
-
-		gas, err = e.currentContract().memory.Set(memOffset, length, e.returnData[dataOffset.Uint64():end.Uint64()])
-
-	case CODECOPY:
-		gas, err = e.currentContract().memory.Set(memOffset, length, getSlice(e.currentContract().code, dataOffset, length))
-
-	default:
-		return fmt.Errorf("copy bad opcode found: %s", op.String())
-	}
-
-	if err != nil {
-		return err
-	}
-
-	// calculate gas
-
-	var overflow bool
-	if gas, overflow = math.SafeAdd(gas, GasFastestStep); overflow {
-		return ErrGasOverflow
-	}
-
-	words, overflow := bigUint64(length)
-	if overflow {
-		return ErrGasOverflow
-	}
-
-	if words, overflow = math.SafeMul(numWords(words), params.CopyGas); overflow {
-		return ErrGasOverflow
-	}
-
-	if gas, overflow = math.SafeAdd(gas, words); overflow {
-		return ErrGasOverflow
-	}
-
-	if !e.currentContract().consumeGas(gas) {
-		return ErrGasOverflow
-	}
-	return nil
+func toAddress(v *uint256.Int) common.Address {
+	b := v.Bytes32()
+	return common.BytesToAddress(b[12:])
 }
 
 func getData(data []byte, start uint64, size uint64) []byte {
@@ -1530,419 +634,18 @@ func getData(data []byte, start uint64, size uint64) []byte {
 	return common.RightPadBytes(data[start:end], int(size))
 }
 
-func getSlice(data []byte, start *big.Int, size *big.Int) []byte {
-	dlen := big.NewInt(int64(len(data)))
-
-	s := math.BigMin(start, dlen)
-	e := math.BigMin(new(big.Int).Add(s, size), dlen)
-	return common.RightPadBytes(data[s.Uint64():e.Uint64()], int(size.Uint64()))
-}
-
-func (e *EVM) executeContextOperations(op OpCode) (*big.Int, error) {
-	switch op {
-	case ADDRESS:
-		return e.currentContract().address.Big(), nil
-
-	case BALANCE:
-		addr := e.pop()
-		if addr == nil {
-			return nil, ErrStackUnderflow
-		}
-
-		return e.state.GetBalance(common.BigToAddress(addr)), nil
-
-	case ORIGIN:
-		return e.currentContract().origin.Big(), nil
-
-	case CALLER:
-		return e.currentContract().caller.Big(), nil
-
-	case CALLVALUE:
-		value := e.currentContract().value
-		if value == nil {
-			return big.NewInt(0), nil
-		} else {
-			return value, nil
-		}
-
-	case CALLDATALOAD:
-		offset := e.pop()
-		if offset == nil {
-			return nil, ErrStackUnderflow
-		}
-
-		return big.NewInt(1).SetBytes(getSlice(e.currentContract().input, offset, big.NewInt(32))), nil
-
-	case CALLDATASIZE:
-		return big.NewInt(int64(len(e.currentContract().input))), nil
-
-	case CODESIZE:
-		return big.NewInt(int64(len(e.currentContract().code))), nil
-
-	case EXTCODESIZE:
-		addr := e.pop()
-		if addr == nil {
-			return nil, ErrStackUnderflow
-		}
-
-		return big.NewInt(int64(e.state.GetCodeSize(common.BigToAddress(addr)))), nil
-
-	case GASPRICE:
-		return e.env.GasPrice, nil
-
-	case RETURNDATASIZE:
-		if !e.config.IsByzantium(e.env.Number) {
-			return nil, ErrOpcodeNotFound
-		}
-
-		return big.NewInt(int64(len(e.returnData))), nil
-
-	default:
-		return nil, fmt.Errorf("context bad opcode found: %s", op.String())
-	}
-}
-
-func (e *EVM) executeBlockInformation(op OpCode) (*big.Int, error) {
-	switch op {
-	case BLOCKHASH:
-		n := e.pop()
-		if n == nil {
-			return nil, ErrStackUnderflow
-		}
-		return e.getHash(n.Uint64()).Big(), nil
-
-	case COINBASE:
-		return e.env.Coinbase.Big(), nil
-
-	case TIMESTAMP:
-		return math.U256(e.env.Timestamp), nil
-
-	case NUMBER:
-		return math.U256(e.env.Number), nil
-
-	case DIFFICULTY:
-		return math.U256(e.env.Difficulty), nil
-
-	case GASLIMIT:
-		return math.U256(e.env.GasLimit), nil
-
-	default:
-		return nil, fmt.Errorf("arithmetic bad opcode found: %s", op.String())
-	}
-}
-
-// do it there but add the helper functions
-func (e *EVM) executeUnsignedArithmeticOperations(op OpCode) (*big.Int, error) {
-	if !e.stackAtLeast(2) {
-		return nil, ErrStackUnderflow
-	}
-
-	x, y := e.pop(), e.pop()
-
-	switch op {
-	case ADD:
-		return math.U256(big.NewInt(0).Add(x, y)), nil
-
-	case MUL:
-		return math.U256(big.NewInt(0).Mul(x, y)), nil
-
-	case SUB:
-		return math.U256(big.NewInt(0).Sub(x, y)), nil
-
-	case DIV:
-		if y.Sign() == 0 {
-			return big.NewInt(0), nil
-		}
-		return math.U256(big.NewInt(0).Div(x, y)), nil
-
-	case SDIV:
-		x, y = math.S256(x), math.S256(y)
-		res := big.NewInt(0)
-
-		if y.Sign() == 0 || x.Sign() == 0 {
-			return big.NewInt(0), nil
-		} else if x.Sign() != y.Sign() {
-			res.Div(x.Abs(x), y.Abs(y))
-			res.Neg(res)
-		} else {
-			res.Div(x.Abs(x), y.Abs(y))
-		}
-		return math.U256(res), nil
-
-	case MOD:
-		if y.Sign() == 0 {
-			return big.NewInt(0), nil
-		}
-		return math.U256(big.NewInt(0).Mod(x, y)), nil
-
-	case SMOD:
-		x, y = math.S256(x), math.S256(y)
-		res := big.NewInt(0)
-
-		if y.Sign() == 0 {
-			return res, nil
-		}
-		if x.Sign() < 0 {
-			res.Mod(x.Abs(x), y.Abs(y))
-			res.Neg(res)
-		} else {
-			res.Mod(x.Abs(x), y.Abs(y))
-		}
-
-		return math.U256(res), nil
-
-	case EXP:
-		base, exponent := x, y
-
-		expByteLen := uint64((exponent.BitLen() + 7) / 8)
-		cmpToOne := exponent.Cmp(big.NewInt(1))
-
-		var res *big.Int
-		if cmpToOne < 0 {
-			res = big.NewInt(1)
-		} else if base.Sign() == 0 {
-			res = big.NewInt(0)
-		} else if cmpToOne == 0 {
-			res = base
-		} else {
-			res = math.Exp(base, exponent)
-		}
-
-		gas := expByteLen * e.gasTable.ExpByte
-		overflow := false
-
-		if gas, overflow = math.SafeAdd(gas, GasSlowStep); overflow {
-			return nil, ErrGasOverflow
-		}
-		if !e.currentContract().consumeGas(gas) {
-			return nil, ErrGasConsumed
-		}
-
-		return res, nil
-
-	default:
-		return nil, fmt.Errorf("arithmetic bad opcode found: %s", op.String())
-	}
-}
-
-func (e *EVM) executeSignExtension() (*big.Int, error) {
-	back := e.pop()
-	if back == nil {
-		return nil, ErrStackUnderflow
-	}
-
-	if back.Cmp(big.NewInt(31)) < 0 {
-		bit := uint(back.Uint64()*8 + 7)
-		num := e.pop()
-		if num == nil {
-			return nil, ErrStackUnderflow
-		}
-
-		mask := big.NewInt(1).Lsh(common.Big1, bit)
-		mask = big.NewInt(1).Sub(mask, common.Big1)
-
-		res := big.NewInt(1)
-		if num.Bit(int(bit)) > 0 {
-			res.Or(num, big.NewInt(1).Not(mask))
-		} else {
-			res.And(num, mask)
-		}
-		return math.U256(res), nil
-	}
-
-	return nil, nil
-}
-
-func (e *EVM) executeModularOperations(op OpCode) (*big.Int, error) {
-	if !e.stackAtLeast(3) {
-		return nil, ErrStackUnderflow
-	}
-
-	x, y, z := e.pop(), e.pop(), e.pop()
-
-	res := big.NewInt(0)
-	switch op {
-	case ADDMOD:
-		if z.Cmp(big.NewInt(0)) > 0 {
-			res.Add(x, y)
-			res.Mod(res, z)
-			return math.U256(res), nil
-		}
-		return big.NewInt(0), nil
-
-	case MULMOD:
-		if z.Cmp(big.NewInt(0)) > 0 {
-			res.Mul(x, y)
-			res.Mod(res, z)
-			return math.U256(res), nil
-		}
-		return big.NewInt(0), nil
-
-	default:
-		return nil, fmt.Errorf("modular bad opcode found: %s", op.String())
-	}
-}
+func getSlice(data []byte, start *uint256.Int, size *uint256.Int) []byte {
+	dlen := uint64(len(data))
 
-func (e *EVM) executeBitWiseOperations1(op OpCode) (*big.Int, error) {
-	x := e.pop()
-	if x == nil {
-		return nil, ErrStackUnderflow
+	s := dlen
+	if start.IsUint64() && start.Uint64() < dlen {
+		s = start.Uint64()
 	}
-
-	switch op {
-	case ISZERO:
-		if x.Sign() > 0 {
-			return big.NewInt(0), nil
-		}
-		return big.NewInt(1), nil
-
-	case NOT:
-		return math.U256(big.NewInt(1).Not(x)), nil
-
-	default:
-		return nil, fmt.Errorf("bitwise1 bad opcode found: %s", op.String())
+	e := dlen
+	if size.IsUint64() && size.Uint64() < dlen-s {
+		e = s + size.Uint64()
 	}
-}
-
-func (e *EVM) executeBitWiseOperations2(op OpCode) (*big.Int, error) {
-	if !e.stackAtLeast(2) {
-		return nil, ErrStackUnderflow
-	}
-
-	x, y := e.pop(), e.pop()
-
-	switch op {
-	case AND:
-		return big.NewInt(0).And(x, y), nil
-
-	case OR:
-		return big.NewInt(0).Or(x, y), nil
-
-	case XOR:
-		return big.NewInt(0).Xor(x, y), nil
-
-	case BYTE:
-		if x.Cmp(common.Big32) < 0 {
-			return big.NewInt(int64(math.Byte(y, 32, int(x.Int64())))), nil
-		}
-		return big.NewInt(0), nil
-
-	default:
-		return nil, fmt.Errorf("bitwise2 bad opcode found: %s", op.String())
-	}
-}
-
-func (e *EVM) executeShiftOperations(op OpCode) (*big.Int, error) {
-	if !e.config.IsConstantinople(e.env.Number) {
-		return nil, ErrOpcodeNotFound
-	}
-
-	if !e.stackAtLeast(2) {
-		return nil, ErrStackUnderflow
-	}
-
-	x, y := e.pop(), e.pop()
-
-	shift := math.U256(x)
-
-	switch op {
-	case SHL:
-		value := math.U256(y)
-		if shift.Cmp(common.Big256) >= 0 {
-			return big.NewInt(0), nil
-		}
-		return math.U256(value.Lsh(value, uint(shift.Uint64()))), nil
-
-	case SHR:
-		value := math.U256(y)
-		if shift.Cmp(common.Big256) >= 0 {
-			return big.NewInt(0), nil
-		}
-		return math.U256(value.Rsh(value, uint(shift.Uint64()))), nil
-
-	case SAR:
-		value := math.S256(y)
-		if shift.Cmp(common.Big256) >= 0 {
-			if value.Sign() >= 0 {
-				return math.U256(big.NewInt(0)), nil
-			}
-			return math.U256(big.NewInt(-1)), nil
-		}
-		return math.U256(value.Rsh(value, uint(shift.Uint64()))), nil
-
-	default:
-		return nil, fmt.Errorf("shift bad opcode found: %s", op.String())
-	}
-}
-
-func (e *EVM) executeComparison(op OpCode) (*big.Int, error) {
-	if !e.stackAtLeast(2) {
-		return nil, ErrStackUnderflow
-	}
-
-	x, y := e.pop(), e.pop()
-
-	var res bool
-	switch op {
-	case EQ:
-		res = x.Cmp(y) == 0
-
-	case LT:
-		res = x.Cmp(y) < 0
-
-	case GT:
-		res = x.Cmp(y) > 0
-
-	case SLT:
-		return sltComparison(x, y), nil
-
-	case SGT:
-		return sgtComparison(x, y), nil
-
-	default:
-		return nil, fmt.Errorf("comparison bad opcode found: %s", op.String())
-	}
-
-	if res {
-		return big.NewInt(1), nil
-	}
-	return big.NewInt(0), nil
-}
-
-func sltComparison(x, y *big.Int) *big.Int {
-	xSign := x.Cmp(tt255)
-	ySign := y.Cmp(tt255)
-
-	if xSign >= 0 && ySign < 0 {
-		return big.NewInt(1)
-	} else if xSign < 0 && ySign >= 0 {
-		return big.NewInt(0)
-	}
-
-	if x.Cmp(y) < 0 {
-		return big.NewInt(1)
-	}
-	return big.NewInt(0)
-}
-
-func sgtComparison(x, y *big.Int) *big.Int {
-	xSign := x.Cmp(tt255)
-	ySign := y.Cmp(tt255)
-
-	if xSign >= 0 && ySign < 0 {
-		return big.NewInt(0)
-	} else if xSign < 0 && ySign >= 0 {
-		return big.NewInt(1)
-	}
-
-	if x.Cmp(y) > 0 {
-		return big.NewInt(1)
-	}
-	return big.NewInt(0)
-}
-
-func (e *EVM) inStaticCall() bool {
-	return e.currentContract().static
+	return common.RightPadBytes(data[s:e], int(size.Uint64()))
 }
 
 // -- evm ---
@@ -1951,19 +654,19 @@ func (e *EVM) stackAtLeast(n int) bool {
 	return e.currentContract().stackAtLeast(n)
 }
 
-func (e *EVM) push(val *big.Int) {
+func (e *EVM) push(val *uint256.Int) {
 	e.currentContract().push(val)
 }
 
-func (e *EVM) pop() *big.Int {
+func (e *EVM) pop() *uint256.Int {
 	return e.currentContract().pop()
 }
 
-func (e *EVM) peek() *big.Int {
+func (e *EVM) peek() *uint256.Int {
 	return e.currentContract().peek()
 }
 
-func (e *EVM) peekAt(n int) *big.Int {
+func (e *EVM) peekAt(n int) *uint256.Int {
 	return e.currentContract().peekAt(n)
 }
 
@@ -2003,14 +706,15 @@ func Transfer(state *state.StateDB, from common.Address, to common.Address, amou
 
 // Memory (based on geth)
 
+// Memory is the memory of a contract. The gas to expand it is charged
+// before the opcodes are executed, the methods below only grow it.
 type Memory struct {
-	c           *Contract
 	store       []byte
 	lastGasCost uint64
 }
 
-func newMemory(c *Contract) *Memory {
-	return &Memory{c: c, store: []byte{}, lastGasCost: 0}
+func newMemory() *Memory {
+	return &Memory{store: []byte{}, lastGasCost: 0}
 }
 
 func (m *Memory) Len() int {
@@ -2029,126 +733,71 @@ func numWords(n uint64) uint64 {
 	return (n + 31) / 32
 }
 
-func (m *Memory) Resize(size uint64) (uint64, error) {
-	fee := uint64(0)
-
+// Resize expands the memory in slots of 32 bytes to fit size bytes
+func (m *Memory) Resize(size uint64) {
 	if uint64(len(m.store)) < size {
-
-		// expand in slots of 32 bytes
-		words := numWords(size)
-		size = roundUpToWord(size)
-
-		// measure gas
-		square := words * words
-		linCoef := words * MemoryGas
-		quadCoef := square / QuadCoeffDiv
-		newTotalFee := linCoef + quadCoef
-
-		fee = newTotalFee - m.lastGasCost
-
-		if fee > m.c.gas {
-			return 0, ErrGasConsumed
-		}
-
-		m.lastGasCost = newTotalFee
-
-		m.store = append(m.store, make([]byte, size-uint64(len(m.store)))...)
+		m.store = append(m.store, make([]byte, roundUpToWord(size)-uint64(len(m.store)))...)
 	}
-
-	return fee, nil
 }
 
 // calculates the memory size required for a step
-func calcMemSize(off, l *big.Int) *big.Int {
-	if l.Sign() == 0 {
-		return common.Big0
+func calcMemSize(off, l *uint256.Int) (uint64, bool) {
+	if l.IsZero() {
+		return 0, false
 	}
-
-	return new(big.Int).Add(off, l)
+	return memoryEnd(off, l)
 }
 
-func (m *Memory) SetByte(o *big.Int, val int64) (uint64, error) {
-	offset := o.Uint64()
-
-	size, overflow := bigUint64(big.NewInt(1).Add(o, big.NewInt(1)))
+// memoryEnd returns the end of the memory range of size l at offset o
+// and whether it does not fit in 64 bits
+func memoryEnd(o, l *uint256.Int) (uint64, bool) {
+	end, overflow := new(uint256.Int).AddOverflow(o, l)
 	if overflow {
-		return 0, ErrMemoryOverflow
-	}
-
-	gas, err := m.Resize(size)
-	if err != nil {
-		return 0, err
+		return 0, true
 	}
-	m.store[offset] = byte(val & 0xff)
-	return gas, nil
+	return toUint64(end)
 }
 
-func (m *Memory) Set32(o *big.Int, val *big.Int) (uint64, error) {
-	offset := o.Uint64()
-
-	size, overflow := bigUint64(big.NewInt(1).Add(o, big.NewInt(32)))
-	if overflow {
-		return 0, ErrMemoryOverflow
-	}
-
-	gas, err := m.Resize(size)
-	if err != nil {
-		return 0, err
-	}
-
-	copy(m.store[offset:offset+32], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
-	math.ReadBits(val, m.store[offset:offset+32])
-	return gas, nil
+func (m *Memory) SetByte(offset uint64, val byte) {
+	m.Resize(offset + 1)
+	m.store[offset] = val
 }
 
-func (m *Memory) Set(o *big.Int, l *big.Int, data []byte) (uint64, error) {
-	offset := o.Uint64()
-	length := l.Uint64()
+func (m *Memory) Set32(offset uint64, val *uint256.Int) {
+	m.Resize(offset + 32)
 
-	size, overflow := bigUint64(big.NewInt(1).Add(o, l))
-	if overflow {
-		return 0, ErrMemoryOverflow
-	}
+	b := val.Bytes32()
+	copy(m.store[offset:offset+32], b[:])
+}
 
-	gas, err := m.Resize(size)
-	if err != nil {
-		return 0, err
+func (m *Memory) Set(offset, length uint64, data []byte) {
+	if length == 0 {
+		return
 	}
 
+	m.Resize(offset + length)
 	copy(m.store[offset:offset+length], data)
-	return gas, nil
 }
 
-func (m *Memory) Get(o *big.Int, l *big.Int) ([]byte, uint64, error) {
-	offset := o.Uint64()
-	length := l.Uint64()
-
+// Get returns a copy of the memory range
+func (m *Memory) Get(offset, length uint64) []byte {
 	if length == 0 {
-		return []byte{}, 0, nil
-	}
-
-	size, overflow := bigUint64(big.NewInt(1).Add(o, l))
-	if overflow {
-		return nil, 0, ErrMemoryOverflow
-	}
-
-	gas, err := m.Resize(size)
-	if err != nil {
-		return nil, 0, err
+		return []byte{}
 	}
 
 	cpy := make([]byte, length)
-	copy(cpy, m.store[offset:offset+length])
-	return cpy, gas, nil
+	copy(cpy, m.GetPtr(offset, length))
+	return cpy
 }
 
-func (m *Memory) Resize2(o *big.Int, l *big.Int) (uint64, error) {
-	size, overflow := bigUint64(big.NewInt(1).Add(o, l))
-	if overflow {
-		return 0, ErrMemoryOverflow
+// GetPtr returns the memory range without copying it
+func (m *Memory) GetPtr(offset, length uint64) []byte {
+	if length == 0 {
+		return nil
 	}
 
-	return m.Resize(size)
+	m.Resize(offset + length)
+	return m.store[offset : offset+length]
 }
 
 func (m *Memory) Show() string {
@@ -2208,18 +857,18 @@ func codeBitmap(code []byte) bitvec {
 	return bits
 }
 
-func callGas(gasTable params.GasTable, availableGas, base uint64, callCost *big.Int) (uint64, error) {
+func callGas(gasTable chain.GasTable, availableGas, base uint64, callCost *uint256.Int) (uint64, error) {
 	if gasTable.CreateBySuicide > 0 {
 		availableGas = availableGas - base
 		gas := availableGas - availableGas/64
 		// If the bit length exceeds 64 bit we know that the newly calculated "gas" for EIP150
 		// is smaller than the requested amount. Therefor we return the new gas instead
 		// of returning an error.
-		if callCost.BitLen() > 64 || gas < callCost.Uint64() {
+		if !callCost.IsUint64() || gas < callCost.Uint64() {
 			return gas, nil
 		}
 	}
-	if callCost.BitLen() > 64 {
+	if !callCost.IsUint64() {
 		return 0, ErrGasOverflow
 	}
 
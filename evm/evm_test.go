@@ -7,7 +7,8 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/evm/uint256"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -33,27 +34,26 @@ func newState(t *testing.T) *state.StateDB {
 
 func newTestContract(code []byte) *Contract {
 	f := &Contract{
-		ip:    -1,
 		code:  code,
 		gas:   1000000,
-		stack: make([]*big.Int, StackSize),
+		stack: make([]uint256.Int, StackSize),
 		sp:    0,
 	}
-	f.memory = newMemory(f)
+	f.memory = newMemory()
 	return f
 }
 
 func testEVM(code []byte) *EVM {
-	evm := NewEVM(nil, nil, nil, params.GasTableHomestead, nil)
+	evm := NewEVM(nil, nil, chain.ForksInTime{}, *chain.GasTableHomestead, nil)
 	evm.pushContract(newTestContract(code))
 	return evm
 }
 
 func testEVMWithStack(stack []byte, op OpCode) *EVM {
-	evm := NewEVM(nil, nil, nil, params.GasTableHomestead, nil)
+	evm := NewEVM(nil, nil, chain.ForksInTime{}, *chain.GasTableHomestead, nil)
 	evm.pushContract(newTestContract([]byte{byte(op)}))
 	for _, i := range stack {
-		evm.push(big.NewInt(0).SetBytes([]byte{i}))
+		evm.push(new(uint256.Int).SetBytes([]byte{i}))
 	}
 	return evm
 }
@@ -61,8 +61,8 @@ func testEVMWithStack(stack []byte, op OpCode) *EVM {
 func testStack(t *testing.T, evm *EVM, items []int32) {
 	for indx, i := range items {
 		val := evm.peekAt(indx + 1)
-		if val.Int64() != int64(i) {
-			t.Fatalf("at index %d expected %d but found %d", indx, i, val.Int64())
+		if int64(val.Uint64()) != int64(i) {
+			t.Fatalf("at index %d expected %d but found %d", indx, i, int64(val.Uint64()))
 		}
 	}
 }
@@ -290,16 +290,16 @@ func testIntTestCases(t *testing.T, instruction OpCode, cases []intTestCase) {
 	for _, cc := range cases {
 		t.Run(instruction.String(), func(t *testing.T) {
 			evm := testEVM(Instructions{byte(instruction)})
-			evm.push(big.NewInt(cc.x))
-			evm.push(big.NewInt(cc.y))
+			evm.push(new(uint256.Int).SetFromBig(big.NewInt(cc.x)))
+			evm.push(new(uint256.Int).SetFromBig(big.NewInt(cc.y)))
 
 			if err := evm.Run(); err != nil {
 				t.Fatal(err)
 			}
 
-			peek := evm.peek()
-			if peek.Int64() != cc.expected {
-				t.Fatalf("expected %d but found %d", peek.Int64(), cc.expected)
+			peek := int64(evm.peek().Uint64())
+			if peek != cc.expected {
+				t.Fatalf("expected %d but found %d", peek, cc.expected)
 			}
 		})
 	}
@@ -313,11 +313,12 @@ func testStringTestCases(t *testing.T, instruction OpCode, cases []stringTestCas
 	for _, cc := range cases {
 		t.Run(instruction.String(), func(t *testing.T) {
 			evm := testEVM(Instructions{byte(instruction)})
-			evm.config = &params.ChainConfig{ConstantinopleBlock: big.NewInt(0)}
+			evm.config = chain.AllForksEnabled.At(0)
+			evm.jumpTable = newJumpTable(evm.config, evm.gasTable)
 			evm.env = &Env{Number: big.NewInt(0)}
 
-			evm.push(big.NewInt(1).SetBytes(mustDecode("0x" + cc.x)))
-			evm.push(big.NewInt(1).SetBytes(mustDecode("0x" + cc.y)))
+			evm.push(new(uint256.Int).SetBytes(mustDecode("0x" + cc.x)))
+			evm.push(new(uint256.Int).SetBytes(mustDecode("0x" + cc.y)))
 
 			if err := evm.Run(); err != nil {
 				t.Fatal(err)
@@ -352,62 +353,54 @@ func equalInt(t *testing.T, i, j uint32) {
 	}
 }
 
-func c(i int64) *big.Int {
-	return big.NewInt(i)
-}
-
 func TestMemorySetResize(t *testing.T) {
-	m := newMemory(newTestContract([]byte{}))
+	m := newMemory()
 	data := mustDecode("0x123456")
 
-	m.Set(c(0), c(3), data)
+	m.Set(0, 3, data)
 	expectLength(t, m, 32)
 
 	equalBytes(t, m.store, common.RightPadBytes(data, 32))
-	found, _, err := m.Get(c(0), c(3))
-	if err != nil {
-		t.Fatal(err)
-	}
-	equalBytes(t, found, data)
+	equalBytes(t, m.Get(0, 3), data)
 
 	// resize not necessary
-	m.Set(c(10), c(3), data)
+	m.Set(10, 3, data)
 	expectLength(t, m, 32)
 
-	m.Set(c(65), c(10), data)
+	m.Set(65, 10, data)
 	expectLength(t, m, 96)
 
 	// take two more slots
-	m.Set(c(129), c(65), data)
+	m.Set(129, 65, data)
 	expectLength(t, m, 224)
 }
 
 func TestMemorySetByte(t *testing.T) {
-	m := newMemory(newTestContract([]byte{}))
+	m := newMemory()
 
-	m.SetByte(c(10), 10)
+	m.SetByte(10, 10)
 	expectLength(t, m, 32)
 
-	m.SetByte(c(31), 10)
+	m.SetByte(31, 10)
 	expectLength(t, m, 32)
 
-	m.SetByte(c(32), 10)
+	m.SetByte(32, 10)
 	expectLength(t, m, 64)
 }
 
 func TestMemorySet32(t *testing.T) {
-	m := newMemory(newTestContract([]byte{}))
+	m := newMemory()
 
-	m.Set32(c(0), big.NewInt(32))
+	m.Set32(0, uint256.NewInt(32))
 	expectLength(t, m, 32)
 
-	m.Set32(c(1), big.NewInt(32))
+	m.Set32(1, uint256.NewInt(32))
 	expectLength(t, m, 64)
 
-	m = newMemory(newTestContract([]byte{}))
-	m.Set32(c(0), big.NewInt(32))
+	m = newMemory()
+	m.Set32(0, uint256.NewInt(32))
 	expectLength(t, m, 32)
 
-	m.Set32(c(32), big.NewInt(32))
+	m.Set32(32, uint256.NewInt(32))
 	expectLength(t, m, 64)
 }
@@ -0,0 +1,321 @@
+package evm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/evm/uint256"
+)
+
+// Memory sizes. The arguments are read from the stack before the opcode
+// is executed, peekAt(1) being the top of the stack.
+
+func makeMemorySize(offset, size int) memorySizeFunc {
+	return func(c *Contract) (uint64, bool) {
+		return calcMemSize(c.peekAt(offset), c.peekAt(size))
+	}
+}
+
+func makeMemoryFixedSize(size uint64) memorySizeFunc {
+	return func(c *Contract) (uint64, bool) {
+		return calcMemSize(c.peekAt(1), uint256.NewInt(size))
+	}
+}
+
+var (
+	memorySha3         = makeMemorySize(1, 2)
+	memoryCopy         = makeMemorySize(1, 3)
+	memoryExtCodeCopy  = makeMemorySize(2, 4)
+	memoryMLoad        = makeMemoryFixedSize(32)
+	memoryMStore       = makeMemoryFixedSize(32)
+	memoryMStore8      = makeMemoryFixedSize(1)
+	memoryCreate       = makeMemorySize(2, 3)
+	memoryReturn       = makeMemorySize(1, 2)
+	memoryLog          = makeMemorySize(1, 2)
+	memoryCall         = makeMemoryCall(4, 6) // CALL and CALLCODE
+	memoryDelegateCall = makeMemoryCall(3, 5) // DELEGATECALL and STATICCALL
+)
+
+// makeMemoryCall returns the memory size of a call, which is the
+// largest of the input and the output ranges
+func makeMemoryCall(in, ret int) memorySizeFunc {
+	return func(c *Contract) (uint64, bool) {
+		inSize, overflow := calcMemSize(c.peekAt(in), c.peekAt(in+1))
+		if overflow {
+			return 0, true
+		}
+		retSize, overflow := calcMemSize(c.peekAt(ret), c.peekAt(ret+1))
+		if overflow {
+			return 0, true
+		}
+		if retSize > inSize {
+			return retSize, false
+		}
+		return inSize, false
+	}
+}
+
+// Dynamic gas. The constant gas of the opcode is already consumed when
+// these functions are called, and the memory is not yet expanded.
+
+// memoryGasCost returns the gas to expand the memory to newMemSize
+func memoryGasCost(m *Memory, newMemSize uint64) (uint64, error) {
+	if newMemSize == 0 {
+		return 0, nil
+	}
+	// the maximum that will fit in a uint64 is max_word_count - 1,
+	// anything above will overflow
+	if newMemSize > 0x1FFFFFFFE0 {
+		return 0, ErrGasOverflow
+	}
+
+	words := numWords(newMemSize)
+	newMemSize = words * 32
+
+	if newMemSize <= uint64(m.Len()) {
+		return 0, nil
+	}
+
+	square := words * words
+	linCoef := words * MemoryGas
+	quadCoef := square / QuadCoeffDiv
+	newTotalFee := linCoef + quadCoef
+
+	fee := newTotalFee - m.lastGasCost
+	m.lastGasCost = newTotalFee
+
+	return fee, nil
+}
+
+// wordGas returns the gas of size charged per word
+func wordGas(size *uint256.Int, perWord uint64) (uint64, bool) {
+	words, overflow := toUint64(size)
+	if overflow {
+		return 0, true
+	}
+	return math.SafeMul(numWords(words), perWord)
+}
+
+func gasMemory(e *EVM, memorySize uint64) (uint64, error) {
+	return memoryGasCost(e.currentContract().memory, memorySize)
+}
+
+// makeGasMemoryWords returns the gas of the memory expansion plus the
+// gas per word of the size at position n of the stack
+func makeGasMemoryWords(n int, perWord uint64) gasFunc {
+	return func(e *EVM, memorySize uint64) (uint64, error) {
+		gas, err := memoryGasCost(e.currentContract().memory, memorySize)
+		if err != nil {
+			return 0, err
+		}
+		words, overflow := wordGas(e.peekAt(n), perWord)
+		if overflow {
+			return 0, ErrGasOverflow
+		}
+		if gas, overflow = math.SafeAdd(gas, words); overflow {
+			return 0, ErrGasOverflow
+		}
+		return gas, nil
+	}
+}
+
+var (
+	gasSha3        = makeGasMemoryWords(2, params.Sha3WordGas)
+	gasCopy        = makeGasMemoryWords(3, params.CopyGas)
+	gasExtCodeCopy = makeGasMemoryWords(4, params.CopyGas)
+	gasCreate2     = makeGasMemoryWords(3, params.Sha3WordGas)
+)
+
+func gasExp(e *EVM, memorySize uint64) (uint64, error) {
+	exponent := e.peekAt(2)
+	return uint64(exponent.ByteLen()) * e.gasTable.ExpByte, nil
+}
+
+func makeGasLog(n uint64) gasFunc {
+	return func(e *EVM, memorySize uint64) (uint64, error) {
+		gas, err := memoryGasCost(e.currentContract().memory, memorySize)
+		if err != nil {
+			return 0, err
+		}
+
+		requestedSize, overflow := toUint64(e.peekAt(2))
+		if overflow {
+			return 0, ErrGasOverflow
+		}
+
+		if gas, overflow = math.SafeAdd(gas, params.LogGas); overflow {
+			return 0, ErrGasOverflow
+		}
+		if gas, overflow = math.SafeAdd(gas, n*params.LogTopicGas); overflow {
+			return 0, ErrGasOverflow
+		}
+
+		var memorySizeGas uint64
+		if memorySizeGas, overflow = math.SafeMul(requestedSize, params.LogDataGas); overflow {
+			return 0, ErrGasOverflow
+		}
+		if gas, overflow = math.SafeAdd(gas, memorySizeGas); overflow {
+			return 0, ErrGasOverflow
+		}
+		return gas, nil
+	}
+}
+
+func gasSStore(e *EVM, memorySize uint64) (uint64, error) {
+	// net gas metering (istanbul)
+	if e.config.Istanbul {
+		// the gas left must be enough to prevent reentrancy with the
+		// stipend of a call, it is not consumed
+		if e.currentContract().gas <= SstoreSentryGasEIP2200 {
+			return 0, errSentry
+		}
+		return netSstoreGas(e, netSstoreCostsEIP2200), nil
+	}
+
+	// net gas metering (constantinople)
+	if e.config.EIP1283() {
+		return netSstoreGas(e, netSstoreCostsEIP1283), nil
+	}
+
+	loc, val := e.peekAt(1), e.peekAt(2)
+	current := e.state.GetState(e.currentContract().address, common.Hash(loc.Bytes32()))
+
+	switch {
+	case current == (common.Hash{}) && !val.IsZero(): // 0 => non 0
+		return SstoreSetGas, nil
+	case current != (common.Hash{}) && val.IsZero(): // non 0 => 0
+		e.state.AddRefund(SstoreRefundGas)
+		return SstoreClearGas, nil
+	default: // non 0 => non 0 (or 0 => 0)
+		return SstoreResetGas, nil
+	}
+}
+
+// netSstoreCosts are the costs of the net gas metering for SSTORE
+// that change between EIP-1283 and EIP-2200
+type netSstoreCosts struct {
+	noop             uint64
+	dirty            uint64
+	resetRefund      uint64
+	resetClearRefund uint64
+}
+
+var (
+	netSstoreCostsEIP1283 = netSstoreCosts{
+		noop:             NetSstoreNoopGas,
+		dirty:            NetSstoreDirtyGas,
+		resetRefund:      NetSstoreResetRefund,
+		resetClearRefund: NetSstoreResetClearRefund,
+	}
+	netSstoreCostsEIP2200 = netSstoreCosts{
+		noop:             NetSstoreNoopGasEIP2200,
+		dirty:            NetSstoreDirtyGasEIP2200,
+		resetRefund:      NetSstoreResetRefundEIP2200,
+		resetClearRefund: NetSstoreResetClearRefundEIP2200,
+	}
+)
+
+func netSstoreGas(e *EVM, costs netSstoreCosts) uint64 {
+	address := e.currentContract().address
+
+	loc, val := e.peekAt(1), e.peekAt(2)
+
+	key := common.Hash(loc.Bytes32())
+	value := common.Hash(val.Bytes32())
+
+	current := e.state.GetState(address, key)
+	if current == value { // noop (1)
+		return costs.noop
+	}
+	original := e.state.GetCommittedState(address, key)
+	if original == current {
+		if original == (common.Hash{}) { // create slot (2.1.1)
+			return NetSstoreInitGas
+		}
+		if value == (common.Hash{}) { // delete slot (2.1.2b)
+			e.state.AddRefund(NetSstoreClearRefund)
+		}
+		return NetSstoreCleanGas // write existing slot (2.1.2)
+	}
+	if original != (common.Hash{}) {
+		if current == (common.Hash{}) { // recreate slot (2.2.1.1)
+			e.state.SubRefund(NetSstoreClearRefund)
+		} else if value == (common.Hash{}) { // delete slot (2.2.1.2)
+			e.state.AddRefund(NetSstoreClearRefund)
+		}
+	}
+	if original == value {
+		if original == (common.Hash{}) { // reset to original inexistent slot (2.2.2.1)
+			e.state.AddRefund(costs.resetClearRefund)
+		} else { // reset to original existing slot (2.2.2.2)
+			e.state.AddRefund(costs.resetRefund)
+		}
+	}
+	return costs.dirty
+}
+
+// makeGasCall returns the gas of the call opcodes without the constant
+// gas of the gas table. The gas sent to the new frame is stored in
+// callGasTemp so that it is not computed twice.
+func makeGasCall(op OpCode) gasFunc {
+	return func(e *EVM, memorySize uint64) (uint64, error) {
+		c := e.currentContract()
+
+		gas, err := memoryGasCost(c.memory, memorySize)
+		if err != nil {
+			return 0, err
+		}
+
+		if op == CALL || op == CALLCODE {
+			transfersValue := !e.peekAt(3).IsZero()
+
+			if op == CALL {
+				address := toAddress(e.peekAt(2))
+				if e.config.EIP158 {
+					if transfersValue && e.state.Empty(address) {
+						gas += params.CallNewAccountGas
+					}
+				} else if !e.state.Exist(address) {
+					gas += params.CallNewAccountGas
+				}
+			}
+			if transfersValue {
+				gas += params.CallValueTransferGas
+			}
+		}
+
+		if e.callGasTemp, err = callGas(e.gasTable, c.gas, gas, e.peekAt(1)); err != nil {
+			return 0, err
+		}
+
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, e.callGasTemp); overflow {
+			return 0, ErrGasOverflow
+		}
+		return gas, nil
+	}
+}
+
+func gasSelfDestruct(e *EVM, memorySize uint64) (uint64, error) {
+	var gas uint64
+
+	// EIP150 homestead gas reprice fork:
+	if e.config.EIP150 {
+		gas = e.gasTable.Suicide
+
+		address := toAddress(e.peekAt(1))
+		if e.config.EIP158 {
+			// if empty and transfers value
+			if e.state.Empty(address) && e.state.GetBalance(e.currentContract().address).Sign() != 0 {
+				gas += e.gasTable.CreateBySuicide
+			}
+		} else if !e.state.Exist(address) {
+			gas += e.gasTable.CreateBySuicide
+		}
+	}
+
+	if !e.state.HasSuicided(e.currentContract().address) {
+		e.state.AddRefund(params.SuicideRefundGas)
+	}
+	return gas, nil
+}
@@ -0,0 +1,68 @@
+package evm
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/umbracle/minimal/chain"
+)
+
+// Test cases of EIP-2200
+var eip2200Tests = []struct {
+	original byte
+	gas      uint64
+	code     string
+	used     uint64
+	refund   uint64
+	failure  bool
+}{
+	{0, math.MaxUint64, "0x60006000556000600055", 1612, 0, false},                // 0 -> 0 -> 0
+	{0, math.MaxUint64, "0x60006000556001600055", 20812, 0, false},               // 0 -> 0 -> 1
+	{0, math.MaxUint64, "0x60016000556000600055", 20812, 19200, false},           // 0 -> 1 -> 0
+	{0, math.MaxUint64, "0x60016000556002600055", 20812, 0, false},               // 0 -> 1 -> 2
+	{0, math.MaxUint64, "0x60016000556001600055", 20812, 0, false},               // 0 -> 1 -> 1
+	{1, math.MaxUint64, "0x60006000556000600055", 5812, 15000, false},            // 1 -> 0 -> 0
+	{1, math.MaxUint64, "0x60006000556001600055", 5812, 4200, false},             // 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x60006000556002600055", 5812, 0, false},                // 1 -> 0 -> 2
+	{1, math.MaxUint64, "0x60026000556000600055", 5812, 15000, false},            // 1 -> 2 -> 0
+	{1, math.MaxUint64, "0x60026000556003600055", 5812, 0, false},                // 1 -> 2 -> 3
+	{1, math.MaxUint64, "0x60026000556001600055", 5812, 4200, false},             // 1 -> 2 -> 1
+	{1, math.MaxUint64, "0x60026000556002600055", 5812, 0, false},                // 1 -> 2 -> 2
+	{1, math.MaxUint64, "0x60016000556000600055", 5812, 15000, false},            // 1 -> 1 -> 0
+	{1, math.MaxUint64, "0x60016000556002600055", 5812, 0, false},                // 1 -> 1 -> 2
+	{1, math.MaxUint64, "0x60016000556001600055", 1612, 0, false},                // 1 -> 1 -> 1
+	{0, math.MaxUint64, "0x600160005560006000556001600055", 40818, 19200, false}, // 0 -> 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x600060005560016000556000600055", 10818, 19200, false}, // 1 -> 0 -> 1 -> 0
+	{1, 2306, "0x6001600055", 2306, 0, true},                                     // 1 -> 1 (2300 sentry + 2xPUSH)
+	{1, 2307, "0x6001600055", 806, 0, false},                                     // 1 -> 1 (2301 sentry + 2xPUSH)
+}
+
+func TestSStoreEIP2200(t *testing.T) {
+	address := common.HexToAddress("0xaaaa")
+
+	for i, tt := range eip2200Tests {
+		state := newState(t)
+		state.CreateAccount(address)
+		state.SetCode(address, mustDecode(tt.code))
+		state.SetState(address, common.Hash{}, common.BytesToHash([]byte{tt.original}))
+
+		// commit the state so that the value is the original one
+		state.Finalise(true)
+
+		config := chain.AllForksEnabled.At(0)
+		evm := NewEVM(state, &Env{Number: big.NewInt(0)}, config, *config.GasTable(), nil)
+
+		_, gas, err := evm.Call(common.Address{}, address, nil, big.NewInt(0), tt.gas)
+		if failure := err != nil; failure != tt.failure {
+			t.Fatalf("test %d: expected failure %v but found %v", i, tt.failure, err)
+		}
+		if used := tt.gas - gas; used != tt.used {
+			t.Fatalf("test %d: expected %d gas used but found %d", i, tt.used, used)
+		}
+		if refund := state.GetRefund(); refund != tt.refund {
+			t.Fatalf("test %d: expected %d refund but found %d", i, tt.refund, refund)
+		}
+	}
+}
@@ -0,0 +1,684 @@
+package evm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/evm/uint256"
+)
+
+// The stack is validated with the bounds of the jump table before the
+// opcodes are executed. Most of them pop their arguments and write the
+// result in place of the last argument, so the stack does not need to grow.
+
+func opStop(e *EVM) error {
+	return nil
+}
+
+// --- arithmetic ---
+
+func opAdd(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Add(x, y)
+	return nil
+}
+
+func opMul(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Mul(x, y)
+	return nil
+}
+
+func opSub(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Sub(x, y)
+	return nil
+}
+
+func opDiv(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Div(x, y)
+	return nil
+}
+
+func opSDiv(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.SDiv(x, y)
+	return nil
+}
+
+func opMod(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Mod(x, y)
+	return nil
+}
+
+func opSMod(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.SMod(x, y)
+	return nil
+}
+
+func opAddMod(e *EVM) error {
+	x, y, z := e.pop(), e.pop(), e.peek()
+	z.AddMod(x, y, z)
+	return nil
+}
+
+func opMulMod(e *EVM) error {
+	x, y, z := e.pop(), e.pop(), e.peek()
+	z.MulMod(x, y, z)
+	return nil
+}
+
+func opExp(e *EVM) error {
+	base, exponent := e.pop(), e.peek()
+	exponent.Exp(base, exponent)
+	return nil
+}
+
+func opSignExtend(e *EVM) error {
+	back, num := e.pop(), e.peek()
+	num.SignExtend(num, back)
+	return nil
+}
+
+// --- comparison and bitwise ---
+
+func setBool(v *uint256.Int, b bool) {
+	if b {
+		v.SetOne()
+	} else {
+		v.Clear()
+	}
+}
+
+func opLt(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	setBool(y, x.Lt(y))
+	return nil
+}
+
+func opGt(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	setBool(y, x.Gt(y))
+	return nil
+}
+
+func opSlt(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	setBool(y, x.Slt(y))
+	return nil
+}
+
+func opSgt(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	setBool(y, x.Sgt(y))
+	return nil
+}
+
+func opEq(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	setBool(y, x.Eq(y))
+	return nil
+}
+
+func opIsZero(e *EVM) error {
+	x := e.peek()
+	setBool(x, x.IsZero())
+	return nil
+}
+
+func opAnd(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.And(x, y)
+	return nil
+}
+
+func opOr(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Or(x, y)
+	return nil
+}
+
+func opXor(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Xor(x, y)
+	return nil
+}
+
+func opNot(e *EVM) error {
+	x := e.peek()
+	x.Not(x)
+	return nil
+}
+
+func opByte(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Byte(y, x)
+	return nil
+}
+
+// shiftAmount returns the shift of x, shifts of 256 bits or more are
+// handled by the uint256 helpers
+func shiftAmount(x *uint256.Int) uint {
+	if x.IsUint64() && x.Uint64() < 256 {
+		return uint(x.Uint64())
+	}
+	return 256
+}
+
+func opShl(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Lsh(y, shiftAmount(x))
+	return nil
+}
+
+func opShr(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.Rsh(y, shiftAmount(x))
+	return nil
+}
+
+func opSar(e *EVM) error {
+	x, y := e.pop(), e.peek()
+	y.SRsh(y, shiftAmount(x))
+	return nil
+}
+
+// --- sha3 ---
+
+func opSha3(e *EVM) error {
+	offset, size := e.pop(), e.peek()
+
+	data := e.currentContract().memory.Get(offset.Uint64(), size.Uint64())
+	hash := crypto.Keccak256Hash(data)
+	size.SetBytes(hash[:])
+	return nil
+}
+
+// --- context ---
+
+func opAddress(e *EVM) error {
+	e.push(new(uint256.Int).SetBytes(e.currentContract().address[:]))
+	return nil
+}
+
+func opBalance(e *EVM) error {
+	addr := e.peek()
+	addr.SetFromBig(e.state.GetBalance(toAddress(addr)))
+	return nil
+}
+
+func opOrigin(e *EVM) error {
+	e.push(new(uint256.Int).SetBytes(e.currentContract().origin[:]))
+	return nil
+}
+
+func opCaller(e *EVM) error {
+	e.push(new(uint256.Int).SetBytes(e.currentContract().caller[:]))
+	return nil
+}
+
+func opCallValue(e *EVM) error {
+	value := e.currentContract().value
+	if value == nil {
+		e.push(uint256.NewInt(0))
+	} else {
+		e.push(new(uint256.Int).SetFromBig(value))
+	}
+	return nil
+}
+
+func opCallDataLoad(e *EVM) error {
+	offset := e.peek()
+	offset.SetBytes(getSlice(e.currentContract().input, offset, uint256.NewInt(32)))
+	return nil
+}
+
+func opCallDataSize(e *EVM) error {
+	e.push(uint256.NewInt(uint64(len(e.currentContract().input))))
+	return nil
+}
+
+func opCallDataCopy(e *EVM) error {
+	memOffset, dataOffset, length := e.pop(), e.pop(), e.pop()
+
+	c := e.currentContract()
+	c.memory.Set(memOffset.Uint64(), length.Uint64(), getSlice(c.input, dataOffset, length))
+	return nil
+}
+
+func opCodeSize(e *EVM) error {
+	e.push(uint256.NewInt(uint64(len(e.currentContract().code))))
+	return nil
+}
+
+func opCodeCopy(e *EVM) error {
+	memOffset, codeOffset, length := e.pop(), e.pop(), e.pop()
+
+	c := e.currentContract()
+	c.memory.Set(memOffset.Uint64(), length.Uint64(), getSlice(c.code, codeOffset, length))
+	return nil
+}
+
+func opGasPrice(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.env.GasPrice))
+	return nil
+}
+
+func opExtCodeSize(e *EVM) error {
+	addr := e.peek()
+	addr.SetUint64(uint64(e.state.GetCodeSize(toAddress(addr))))
+	return nil
+}
+
+func opExtCodeCopy(e *EVM) error {
+	address, memOffset, codeOffset, length := e.pop(), e.pop(), e.pop(), e.pop()
+
+	codeCopy := getSlice(e.state.GetCode(toAddress(address)), codeOffset, length)
+	e.currentContract().memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
+	return nil
+}
+
+func opReturnDataSize(e *EVM) error {
+	e.push(uint256.NewInt(uint64(len(e.returnData))))
+	return nil
+}
+
+func opReturnDataCopy(e *EVM) error {
+	memOffset, dataOffset, length := e.pop(), e.pop(), e.pop()
+
+	end, overflow := new(uint256.Int).AddOverflow(dataOffset, length)
+	if overflow || !end.IsUint64() || uint64(len(e.returnData)) < end.Uint64() {
+		return fmt.Errorf("out of bounds")
+	}
+
+	e.currentContract().memory.Set(memOffset.Uint64(), length.Uint64(), e.returnData[dataOffset.Uint64():end.Uint64()])
+	return nil
+}
+
+func opExtCodeHash(e *EVM) error {
+	addr := e.peek()
+
+	address := toAddress(addr)
+	if e.state.Empty(address) {
+		addr.Clear()
+	} else {
+		hash := e.state.GetCodeHash(address)
+		addr.SetBytes(hash[:])
+	}
+	return nil
+}
+
+// --- block information ---
+
+func opBlockHash(e *EVM) error {
+	n := e.peek()
+
+	// only the last 256 blocks are available
+	upper := e.env.Number.Uint64()
+	lower := uint64(0)
+	if upper > 256 {
+		lower = upper - 256
+	}
+	if !n.IsUint64() || n.Uint64() < lower || n.Uint64() >= upper {
+		n.Clear()
+	} else {
+		hash := e.getHash(n.Uint64())
+		n.SetBytes(hash[:])
+	}
+	return nil
+}
+
+func opCoinbase(e *EVM) error {
+	e.push(new(uint256.Int).SetBytes(e.env.Coinbase[:]))
+	return nil
+}
+
+func opTimestamp(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.env.Timestamp))
+	return nil
+}
+
+func opNumber(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.env.Number))
+	return nil
+}
+
+func opDifficulty(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.env.Difficulty))
+	return nil
+}
+
+func opGasLimit(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.env.GasLimit))
+	return nil
+}
+
+func opChainID(e *EVM) error {
+	chainID := e.env.ChainID
+	if chainID == nil {
+		e.push(uint256.NewInt(0))
+	} else {
+		e.push(new(uint256.Int).SetFromBig(chainID))
+	}
+	return nil
+}
+
+func opSelfBalance(e *EVM) error {
+	e.push(new(uint256.Int).SetFromBig(e.state.GetBalance(e.currentContract().address)))
+	return nil
+}
+
+// --- stack, memory and storage ---
+
+func opPop(e *EVM) error {
+	e.pop()
+	return nil
+}
+
+func opMload(e *EVM) error {
+	offset := e.peek()
+	offset.SetBytes(e.currentContract().memory.GetPtr(offset.Uint64(), 32))
+	return nil
+}
+
+func opMstore(e *EVM) error {
+	start, val := e.pop(), e.pop()
+	e.currentContract().memory.Set32(start.Uint64(), val)
+	return nil
+}
+
+func opMstore8(e *EVM) error {
+	offset, val := e.pop(), e.pop()
+	e.currentContract().memory.SetByte(offset.Uint64(), byte(val.Uint64()))
+	return nil
+}
+
+func opSload(e *EVM) error {
+	loc := e.peek()
+	val := e.state.GetState(e.currentContract().address, common.Hash(loc.Bytes32()))
+	loc.SetBytes(val[:])
+	return nil
+}
+
+func opSstore(e *EVM) error {
+	loc, val := e.pop(), e.pop()
+	e.state.SetState(e.currentContract().address, common.Hash(loc.Bytes32()), common.Hash(val.Bytes32()))
+	return nil
+}
+
+// --- flow ---
+
+func opJump(e *EVM) error {
+	dest := e.pop()
+
+	c := e.currentContract()
+	if !c.validJumpdest(dest) {
+		return ErrJumpDestNotValid
+	}
+	c.ip = int(dest.Uint64())
+	return nil
+}
+
+func opJumpi(e *EVM) error {
+	dest, cond := e.pop(), e.pop()
+
+	c := e.currentContract()
+	if cond.IsZero() {
+		c.ip++
+		return nil
+	}
+	if !c.validJumpdest(dest) {
+		return ErrJumpDestNotValid
+	}
+	c.ip = int(dest.Uint64())
+	return nil
+}
+
+func opJumpDest(e *EVM) error {
+	return nil
+}
+
+func opPc(e *EVM) error {
+	e.push(uint256.NewInt(uint64(e.currentContract().ip)))
+	return nil
+}
+
+func opMsize(e *EVM) error {
+	e.push(uint256.NewInt(uint64(e.currentContract().memory.Len())))
+	return nil
+}
+
+func opGas(e *EVM) error {
+	e.push(uint256.NewInt(e.currentContract().gas))
+	return nil
+}
+
+// --- push, dup and swap ---
+
+func makePush(n int) executionFunc {
+	return func(e *EVM) error {
+		c := e.currentContract()
+		ins := c.code
+
+		var data []byte
+		if c.ip+1+n > len(ins) {
+			data = common.RightPadBytes(ins[c.ip+1:], n)
+		} else {
+			data = ins[c.ip+1 : c.ip+1+n]
+		}
+
+		e.push(new(uint256.Int).SetBytes(data))
+		c.ip += n
+		return nil
+	}
+}
+
+func makeDup(n int) executionFunc {
+	return func(e *EVM) error {
+		e.push(e.peekAt(n))
+		return nil
+	}
+}
+
+func makeSwap(n int) executionFunc {
+	return func(e *EVM) error {
+		e.swap(n)
+		return nil
+	}
+}
+
+// --- logging ---
+
+func makeLog(size int) executionFunc {
+	return func(e *EVM) error {
+		topics := make([]common.Hash, size)
+
+		mStart, mSize := e.pop(), e.pop()
+		for i := 0; i < size; i++ {
+			topics[i] = common.Hash(e.pop().Bytes32())
+		}
+
+		c := e.currentContract()
+		e.state.AddLog(&types.Log{
+			Address:     c.address,
+			Topics:      topics,
+			Data:        c.memory.Get(mStart.Uint64(), mSize.Uint64()),
+			BlockNumber: e.env.Number.Uint64(),
+		})
+		return nil
+	}
+}
+
+// --- system ---
+
+func opCreate(e *EVM) error {
+	return e.executeCreateOperation(CREATE)
+}
+
+func opCreate2(e *EVM) error {
+	return e.executeCreateOperation(CREATE2)
+}
+
+func (e *EVM) executeCreateOperation(op OpCode) error {
+	parent := e.currentContract()
+
+	e.returnData = nil
+
+	// Pop input arguments
+	value := e.pop().ToBig()
+	offset, size := e.pop(), e.pop()
+
+	var salt *uint256.Int
+	if op == CREATE2 {
+		salt = e.pop()
+	}
+
+	input := parent.memory.Get(offset.Uint64(), size.Uint64())
+
+	// Calculate and consume gas for the call
+	gas := parent.gas
+
+	// CREATE2 uses by default EIP150
+	if e.config.EIP150 || op == CREATE2 {
+		gas -= gas / 64
+	}
+	parent.consumeGas(gas)
+
+	// Calculate address
+	var address common.Address
+	if op == CREATE {
+		address = crypto.CreateAddress(parent.address, e.state.GetNonce(parent.address))
+	} else {
+		address = crypto.CreateAddress2(parent.address, common.Hash(salt.Bytes32()), crypto.Keccak256Hash(input).Bytes())
+	}
+
+	contract := newContractCreation(parent.origin, parent.address, address, value, gas, input)
+
+	if e.Tracer != nil {
+		e.captureEnter(op, contract)
+	}
+	return e.create(contract)
+}
+
+func opCall(e *EVM) error {
+	return e.executeCallOperation(CALL)
+}
+
+func opCallCode(e *EVM) error {
+	return e.executeCallOperation(CALLCODE)
+}
+
+func opDelegateCall(e *EVM) error {
+	return e.executeCallOperation(DELEGATECALL)
+}
+
+func opStaticCall(e *EVM) error {
+	return e.executeCallOperation(STATICCALL)
+}
+
+func (e *EVM) executeCallOperation(op OpCode) error {
+	parent := e.currentContract()
+
+	if op == CALL && parent.static && !e.peekAt(3).IsZero() {
+		return errReadOnly
+	}
+
+	e.returnData = nil
+
+	// Pop input arguments, the gas of the call is already computed
+	e.pop()
+	addr := toAddress(e.pop())
+
+	var value *big.Int
+	if op == CALL || op == CALLCODE {
+		value = e.pop().ToBig()
+	}
+
+	inOffset, inSize := e.pop(), e.pop()
+	retOffset, retSize := e.pop(), e.pop()
+
+	gas := e.callGasTemp
+	if value != nil && value.Sign() != 0 {
+		gas += params.CallStipend
+	}
+
+	args := parent.memory.Get(inOffset.Uint64(), inSize.Uint64())
+
+	contract := newContractCall(parent.origin, parent.address, addr, value, gas, e.state.GetCode(addr), args)
+	contract.retOffset = retOffset.Uint64()
+	contract.retSize = retSize.Uint64()
+
+	if op == STATICCALL || parent.static {
+		contract.static = true
+	}
+	if op == CALLCODE || op == DELEGATECALL {
+		contract.address = parent.address
+		if op == DELEGATECALL {
+			contract.value = parent.value
+			contract.caller = parent.caller
+		}
+	}
+
+	if e.Tracer != nil {
+		e.captureEnter(op, contract)
+	}
+	return e.call(contract, op)
+}
+
+func opReturn(e *EVM) error {
+	return e.executeHaltOperations(RETURN)
+}
+
+func opRevert(e *EVM) error {
+	return e.executeHaltOperations(REVERT)
+}
+
+func (e *EVM) executeHaltOperations(op OpCode) error {
+	c := e.currentContract()
+
+	offset, size := e.pop(), e.pop()
+	ret := c.memory.Get(offset.Uint64(), size.Uint64())
+
+	// Return only allowed in calls or if reverted inside a contract creation
+	e.returnData = nil
+	if !c.creation || op == REVERT {
+		e.returnData = ret
+	}
+
+	if op == RETURN && c.creation {
+		maxCodeSizeExceeded := e.config.EIP158 && len(ret) > params.MaxCodeSize
+		if maxCodeSizeExceeded {
+			return ErrMaxCodeSizeExceeded
+		}
+
+		createDataGas := uint64(len(ret)) * params.CreateDataGas
+		if !c.consumeGas(createDataGas) {
+			return ErrGasConsumed
+		}
+		e.state.SetCode(c.address, ret)
+	}
+
+	return nil
+}
+
+func opSelfDestruct(e *EVM) error {
+	address := toAddress(e.pop())
+
+	c := e.currentContract()
+
+	balance := e.state.GetBalance(c.address)
+	if e.Tracer != nil {
+		e.captureSelfDestruct(address, balance)
+	}
+	e.state.AddBalance(address, balance)
+	e.state.Suicide(c.address)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package evm
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// JSONTracer writes the execution trace in the EIP-3155 format,
+// one json object per line for each opcode and a summary at the end
+type JSONTracer struct {
+	enc   *json.Encoder
+	start time.Time
+
+	// Memory includes the memory of each step if set
+	Memory bool
+}
+
+// NewJSONTracer creates a tracer that writes the trace to w
+func NewJSONTracer(w io.Writer) *JSONTracer {
+	return &JSONTracer{enc: json.NewEncoder(w)}
+}
+
+type jsonStep struct {
+	PC         uint64         `json:"pc"`
+	Op         byte           `json:"op"`
+	Gas        hexutil.Uint64 `json:"gas"`
+	GasCost    hexutil.Uint64 `json:"gasCost"`
+	Memory     *hexutil.Bytes `json:"memory,omitempty"`
+	MemSize    int            `json:"memSize"`
+	Stack      []*hexutil.Big `json:"stack"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Depth      int            `json:"depth"`
+	Refund     uint64         `json:"refund"`
+	OpName     string         `json:"opName"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type jsonSummary struct {
+	Output  hexutil.Bytes  `json:"output"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Time    int64          `json:"time"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// StepConfig implements the Tracer interface
+func (j *JSONTracer) StepConfig() StepConfig {
+	return StepConfig{
+		EnableMemory:     j.Memory,
+		EnableStack:      true,
+		EnableReturnData: true,
+	}
+}
+
+// CaptureStart implements the Tracer interface
+func (j *JSONTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	j.start = time.Now()
+}
+
+// CaptureState implements the Tracer interface
+func (j *JSONTracer) CaptureState(step *Step) {
+	j.write(step, nil)
+}
+
+// CaptureFault implements the Tracer interface
+func (j *JSONTracer) CaptureFault(step *Step, err error) {
+	j.write(step, err)
+}
+
+// CaptureEnd implements the Tracer interface
+func (j *JSONTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	summary := &jsonSummary{
+		Output:  output,
+		GasUsed: hexutil.Uint64(gasUsed),
+		Time:    time.Since(j.start).Nanoseconds(),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	j.enc.Encode(summary)
+}
+
+func (j *JSONTracer) write(step *Step, err error) {
+	stack := make([]*hexutil.Big, len(step.Stack))
+	for i, val := range step.Stack {
+		stack[i] = (*hexutil.Big)(val)
+	}
+
+	obj := &jsonStep{
+		PC:         step.PC,
+		Op:         byte(step.Op),
+		Gas:        hexutil.Uint64(step.Gas),
+		GasCost:    hexutil.Uint64(step.Cost),
+		MemSize:    step.MemorySize,
+		Stack:      stack,
+		ReturnData: step.ReturnData,
+		Depth:      step.Depth,
+		Refund:     step.Refund,
+		OpName:     step.Op.String(),
+	}
+	if j.Memory {
+		memory := hexutil.Bytes(step.Memory)
+		obj.Memory = &memory
+	}
+	if err != nil {
+		obj.Error = err.Error()
+	}
+	j.enc.Encode(obj)
+}
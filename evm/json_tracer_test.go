@@ -0,0 +1,121 @@
+package evm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/umbracle/minimal/chain"
+)
+
+func TestJSONTracer(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x1,
+		byte(PUSH1), 0x0,
+		byte(MSTORE),
+		byte(PUSH1), 0x20,
+		byte(PUSH1), 0x0,
+		byte(RETURN),
+	}
+
+	state := newState(t)
+	addr := common.HexToAddress("100")
+	state.SetCode(addr, code)
+
+	var buf bytes.Buffer
+	e := NewEVM(state, &Env{}, chain.ForksInTime{}, *chain.GasTableHomestead, nil)
+	e.Tracer = NewJSONTracer(&buf)
+	if _, _, err := e.Call(common.HexToAddress("2"), addr, nil, big.NewInt(0), 100000); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := []map[string]interface{}{}
+
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &obj); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, obj)
+	}
+	if len(lines) != 7 {
+		t.Fatalf("expected 6 steps and the summary but found %d lines", len(lines))
+	}
+
+	mstore := lines[2]
+	expected := map[string]interface{}{
+		"pc":         float64(4),
+		"op":         float64(MSTORE),
+		"gas":        "0x1869a",
+		"gasCost":    "0x6",
+		"memSize":    float64(0),
+		"returnData": "0x",
+		"depth":      float64(1),
+		"refund":     float64(0),
+		"opName":     "MSTORE",
+	}
+	for k, v := range expected {
+		if mstore[k] != v {
+			t.Fatalf("%s: expected %v but found %v", k, v, mstore[k])
+		}
+	}
+	if stack := mstore["stack"].([]interface{}); len(stack) != 2 || stack[0] != "0x1" || stack[1] != "0x0" {
+		t.Fatalf("bad stack %v", stack)
+	}
+	if _, ok := mstore["memory"]; ok {
+		t.Fatal("memory is not traced by default")
+	}
+	if lines[5]["memSize"] != float64(32) {
+		t.Fatal("bad memory size on return")
+	}
+
+	summary := lines[6]
+	if summary["output"] != "0x0000000000000000000000000000000000000000000000000000000000000001" {
+		t.Fatalf("bad output %v", summary["output"])
+	}
+	if summary["gasUsed"] != "0x12" {
+		t.Fatalf("bad gas used %v", summary["gasUsed"])
+	}
+}
+
+func TestJSONTracerMemory(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x1,
+		byte(PUSH1), 0x0,
+		byte(MSTORE),
+		byte(STOP),
+	}
+
+	state := newState(t)
+	addr := common.HexToAddress("100")
+	state.SetCode(addr, code)
+
+	var buf bytes.Buffer
+	tracer := NewJSONTracer(&buf)
+	tracer.Memory = true
+
+	e := NewEVM(state, &Env{}, chain.ForksInTime{}, *chain.GasTableHomestead, nil)
+	e.Tracer = tracer
+	if _, _, err := e.Call(common.HexToAddress("2"), addr, nil, big.NewInt(0), 100000); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	for i := 0; i < 4; i++ {
+		if !scanner.Scan() {
+			t.Fatal("expected 4 steps")
+		}
+	}
+
+	var stop map[string]interface{}
+	if err := json.Unmarshal(scanner.Bytes(), &stop); err != nil {
+		t.Fatal(err)
+	}
+	if stop["memory"] != "0x0000000000000000000000000000000000000000000000000000000000000001" {
+		t.Fatalf("bad memory %v", stop["memory"])
+	}
+}
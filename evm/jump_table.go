@@ -0,0 +1,624 @@
+package evm
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/chain"
+)
+
+type (
+	executionFunc  func(e *EVM) error
+	gasFunc        func(e *EVM, memorySize uint64) (uint64, error)
+	memorySizeFunc func(c *Contract) (uint64, bool)
+)
+
+// operation is the entry of an opcode in the jump table
+type operation struct {
+	// execute runs the opcode once the gas is consumed
+	execute executionFunc
+
+	// constantGas is consumed before anything else
+	constantGas uint64
+
+	// dynamicGas is the gas that depends on the arguments, including
+	// the expansion of the memory
+	dynamicGas gasFunc
+
+	// memorySize is the size of the memory used by the opcode
+	memorySize memorySizeFunc
+
+	// minStack and maxStack are the bounds of the stack before
+	// the opcode is executed
+	minStack int
+	maxStack int
+
+	halts  bool // stops the execution of the contract
+	jumps  bool // sets the instruction pointer
+	writes bool // modifies the state, not allowed in static calls
+}
+
+// jumpTable has the operations of the opcodes enabled in a fork,
+// unused opcodes are nil
+type jumpTable [256]*operation
+
+const stackLimit = 1024
+
+func minStack(pops, push int) int {
+	return pops
+}
+
+func maxStack(pops, push int) int {
+	return stackLimit + pops - push
+}
+
+type jumpTableKey struct {
+	config   chain.ForksInTime
+	gasTable chain.GasTable
+}
+
+var (
+	jumpTables     = map[jumpTableKey]*jumpTable{}
+	jumpTablesLock sync.Mutex
+)
+
+// newJumpTable returns the jump table for the forks and the gas costs,
+// tables are built once and shared between the EVMs
+func newJumpTable(config chain.ForksInTime, gasTable chain.GasTable) *jumpTable {
+	jumpTablesLock.Lock()
+	defer jumpTablesLock.Unlock()
+
+	key := jumpTableKey{config, gasTable}
+	if t, ok := jumpTables[key]; ok {
+		return t
+	}
+
+	t := newFrontierInstructionSet(gasTable)
+	if config.Homestead {
+		enableHomestead(t)
+	}
+	if config.Byzantium {
+		enableByzantium(t)
+	}
+	if config.Constantinople {
+		enableConstantinople(t, gasTable)
+	}
+	if config.Istanbul {
+		enableIstanbul(t)
+	}
+
+	jumpTables[key] = t
+	return t
+}
+
+// enableHomestead adds DELEGATECALL (EIP-7)
+func enableHomestead(t *jumpTable) {
+	t[DELEGATECALL] = &operation{
+		execute:     opDelegateCall,
+		constantGas: t[CALL].constantGas,
+		dynamicGas:  makeGasCall(DELEGATECALL),
+		memorySize:  memoryDelegateCall,
+		minStack:    minStack(6, 1),
+		maxStack:    maxStack(6, 1),
+	}
+}
+
+// enableByzantium adds STATICCALL (EIP-214), RETURNDATASIZE and
+// RETURNDATACOPY (EIP-211) and REVERT (EIP-140)
+func enableByzantium(t *jumpTable) {
+	t[STATICCALL] = &operation{
+		execute:     opStaticCall,
+		constantGas: t[CALL].constantGas,
+		dynamicGas:  makeGasCall(STATICCALL),
+		memorySize:  memoryDelegateCall,
+		minStack:    minStack(6, 1),
+		maxStack:    maxStack(6, 1),
+	}
+	t[RETURNDATASIZE] = &operation{
+		execute:     opReturnDataSize,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	t[RETURNDATACOPY] = &operation{
+		execute:     opReturnDataCopy,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasCopy,
+		memorySize:  memoryCopy,
+		minStack:    minStack(3, 0),
+		maxStack:    maxStack(3, 0),
+	}
+	t[REVERT] = &operation{
+		execute:    opRevert,
+		dynamicGas: gasMemory,
+		memorySize: memoryReturn,
+		minStack:   minStack(2, 0),
+		maxStack:   maxStack(2, 0),
+		halts:      true,
+	}
+}
+
+// enableConstantinople adds SHL, SHR and SAR (EIP-145), EXTCODEHASH
+// (EIP-1052) and CREATE2 (EIP-1014)
+func enableConstantinople(t *jumpTable, gasTable chain.GasTable) {
+	t[SHL] = &operation{
+		execute:     opShl,
+		constantGas: GasFastestStep,
+		minStack:    minStack(2, 1),
+		maxStack:    maxStack(2, 1),
+	}
+	t[SHR] = &operation{
+		execute:     opShr,
+		constantGas: GasFastestStep,
+		minStack:    minStack(2, 1),
+		maxStack:    maxStack(2, 1),
+	}
+	t[SAR] = &operation{
+		execute:     opSar,
+		constantGas: GasFastestStep,
+		minStack:    minStack(2, 1),
+		maxStack:    maxStack(2, 1),
+	}
+	t[EXTCODEHASH] = &operation{
+		execute:     opExtCodeHash,
+		constantGas: gasTable.ExtcodeHash,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+	t[CREATE2] = &operation{
+		execute:     opCreate2,
+		constantGas: params.Create2Gas,
+		dynamicGas:  gasCreate2,
+		memorySize:  memoryCreate,
+		minStack:    minStack(4, 1),
+		maxStack:    maxStack(4, 1),
+		writes:      true,
+	}
+}
+
+// enableIstanbul adds CHAINID (EIP-1344) and SELFBALANCE (EIP-1884).
+// The repricing of EIP-1884 comes from the gas table.
+func enableIstanbul(t *jumpTable) {
+	t[CHAINID] = &operation{
+		execute:     opChainID,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	t[SELFBALANCE] = &operation{
+		execute:     opSelfBalance,
+		constantGas: GasFastStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+}
+
+// newFrontierInstructionSet returns the opcodes of the first release
+func newFrontierInstructionSet(gasTable chain.GasTable) *jumpTable {
+	t := &jumpTable{
+		STOP: {
+			execute:  opStop,
+			minStack: minStack(0, 0),
+			maxStack: maxStack(0, 0),
+			halts:    true,
+		},
+		ADD: {
+			execute:     opAdd,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		MUL: {
+			execute:     opMul,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SUB: {
+			execute:     opSub,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		DIV: {
+			execute:     opDiv,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SDIV: {
+			execute:     opSDiv,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		MOD: {
+			execute:     opMod,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SMOD: {
+			execute:     opSMod,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		ADDMOD: {
+			execute:     opAddMod,
+			constantGas: GasMidStep,
+			minStack:    minStack(3, 1),
+			maxStack:    maxStack(3, 1),
+		},
+		MULMOD: {
+			execute:     opMulMod,
+			constantGas: GasMidStep,
+			minStack:    minStack(3, 1),
+			maxStack:    maxStack(3, 1),
+		},
+		EXP: {
+			execute:     opExp,
+			constantGas: GasSlowStep,
+			dynamicGas:  gasExp,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SIGNEXTEND: {
+			execute:     opSignExtend,
+			constantGas: GasFastStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		LT: {
+			execute:     opLt,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		GT: {
+			execute:     opGt,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SLT: {
+			execute:     opSlt,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SGT: {
+			execute:     opSgt,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		EQ: {
+			execute:     opEq,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		ISZERO: {
+			execute:     opIsZero,
+			constantGas: GasFastestStep,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		AND: {
+			execute:     opAnd,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		OR: {
+			execute:     opOr,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		XOR: {
+			execute:     opXor,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		NOT: {
+			execute:     opNot,
+			constantGas: GasFastestStep,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		BYTE: {
+			execute:     opByte,
+			constantGas: GasFastestStep,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		SHA3: {
+			execute:     opSha3,
+			constantGas: params.Sha3Gas,
+			dynamicGas:  gasSha3,
+			memorySize:  memorySha3,
+			minStack:    minStack(2, 1),
+			maxStack:    maxStack(2, 1),
+		},
+		ADDRESS: {
+			execute:     opAddress,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		BALANCE: {
+			execute:     opBalance,
+			constantGas: gasTable.Balance,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		ORIGIN: {
+			execute:     opOrigin,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		CALLER: {
+			execute:     opCaller,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		CALLVALUE: {
+			execute:     opCallValue,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		CALLDATALOAD: {
+			execute:     opCallDataLoad,
+			constantGas: GasFastestStep,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		CALLDATASIZE: {
+			execute:     opCallDataSize,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		CALLDATACOPY: {
+			execute:     opCallDataCopy,
+			constantGas: GasFastestStep,
+			dynamicGas:  gasCopy,
+			memorySize:  memoryCopy,
+			minStack:    minStack(3, 0),
+			maxStack:    maxStack(3, 0),
+		},
+		CODESIZE: {
+			execute:     opCodeSize,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		CODECOPY: {
+			execute:     opCodeCopy,
+			constantGas: GasFastestStep,
+			dynamicGas:  gasCopy,
+			memorySize:  memoryCopy,
+			minStack:    minStack(3, 0),
+			maxStack:    maxStack(3, 0),
+		},
+		GASPRICE: {
+			execute:     opGasPrice,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		EXTCODESIZE: {
+			execute:     opExtCodeSize,
+			constantGas: gasTable.ExtcodeSize,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		EXTCODECOPY: {
+			execute:     opExtCodeCopy,
+			constantGas: gasTable.ExtcodeCopy,
+			dynamicGas:  gasExtCodeCopy,
+			memorySize:  memoryExtCodeCopy,
+			minStack:    minStack(4, 0),
+			maxStack:    maxStack(4, 0),
+		},
+		BLOCKHASH: {
+			execute:     opBlockHash,
+			constantGas: GasExtStep,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		COINBASE: {
+			execute:     opCoinbase,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		TIMESTAMP: {
+			execute:     opTimestamp,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		NUMBER: {
+			execute:     opNumber,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		DIFFICULTY: {
+			execute:     opDifficulty,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		GASLIMIT: {
+			execute:     opGasLimit,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		POP: {
+			execute:     opPop,
+			constantGas: GasQuickStep,
+			minStack:    minStack(1, 0),
+			maxStack:    maxStack(1, 0),
+		},
+		MLOAD: {
+			execute:     opMload,
+			constantGas: GasFastestStep,
+			dynamicGas:  gasMemory,
+			memorySize:  memoryMLoad,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		MSTORE: {
+			execute:     opMstore,
+			constantGas: GasFastestStep,
+			dynamicGas:  gasMemory,
+			memorySize:  memoryMStore,
+			minStack:    minStack(2, 0),
+			maxStack:    maxStack(2, 0),
+		},
+		MSTORE8: {
+			execute:     opMstore8,
+			constantGas: GasFastestStep,
+			dynamicGas:  gasMemory,
+			memorySize:  memoryMStore8,
+			minStack:    minStack(2, 0),
+			maxStack:    maxStack(2, 0),
+		},
+		SLOAD: {
+			execute:     opSload,
+			constantGas: gasTable.SLoad,
+			minStack:    minStack(1, 1),
+			maxStack:    maxStack(1, 1),
+		},
+		SSTORE: {
+			execute:    opSstore,
+			dynamicGas: gasSStore,
+			minStack:   minStack(2, 0),
+			maxStack:   maxStack(2, 0),
+			writes:     true,
+		},
+		JUMP: {
+			execute:     opJump,
+			constantGas: GasMidStep,
+			minStack:    minStack(1, 0),
+			maxStack:    maxStack(1, 0),
+			jumps:       true,
+		},
+		JUMPI: {
+			execute:     opJumpi,
+			constantGas: GasSlowStep,
+			minStack:    minStack(2, 0),
+			maxStack:    maxStack(2, 0),
+			jumps:       true,
+		},
+		PC: {
+			execute:     opPc,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		MSIZE: {
+			execute:     opMsize,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		GAS: {
+			execute:     opGas,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		},
+		JUMPDEST: {
+			execute:     opJumpDest,
+			constantGas: params.JumpdestGas,
+			minStack:    minStack(0, 0),
+			maxStack:    maxStack(0, 0),
+		},
+		CREATE: {
+			execute:     opCreate,
+			constantGas: params.CreateGas,
+			dynamicGas:  gasMemory,
+			memorySize:  memoryCreate,
+			minStack:    minStack(3, 1),
+			maxStack:    maxStack(3, 1),
+			writes:      true,
+		},
+		CALL: {
+			execute:     opCall,
+			constantGas: gasTable.Calls,
+			dynamicGas:  makeGasCall(CALL),
+			memorySize:  memoryCall,
+			minStack:    minStack(7, 1),
+			maxStack:    maxStack(7, 1),
+		},
+		CALLCODE: {
+			execute:     opCallCode,
+			constantGas: gasTable.Calls,
+			dynamicGas:  makeGasCall(CALLCODE),
+			memorySize:  memoryCall,
+			minStack:    minStack(7, 1),
+			maxStack:    maxStack(7, 1),
+		},
+		RETURN: {
+			execute:    opReturn,
+			dynamicGas: gasMemory,
+			memorySize: memoryReturn,
+			minStack:   minStack(2, 0),
+			maxStack:   maxStack(2, 0),
+			halts:      true,
+		},
+		SELFDESTRUCT: {
+			execute:    opSelfDestruct,
+			dynamicGas: gasSelfDestruct,
+			minStack:   minStack(1, 0),
+			maxStack:   maxStack(1, 0),
+			halts:      true,
+			writes:     true,
+		},
+	}
+
+	for i := 0; i < 32; i++ {
+		t[PUSH1+OpCode(i)] = &operation{
+			execute:     makePush(i + 1),
+			constantGas: GasFastestStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+		}
+	}
+	for i := 1; i <= 16; i++ {
+		t[DUP+OpCode(i)] = &operation{
+			execute:     makeDup(i),
+			constantGas: GasFastestStep,
+			minStack:    minStack(i, i+1),
+			maxStack:    maxStack(i, i+1),
+		}
+		t[SWAP+OpCode(i)] = &operation{
+			execute:     makeSwap(i),
+			constantGas: GasFastestStep,
+			minStack:    minStack(i+1, i+1),
+			maxStack:    maxStack(i+1, i+1),
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		t[LOG0+OpCode(i)] = &operation{
+			execute:    makeLog(i),
+			dynamicGas: makeGasLog(uint64(i)),
+			memorySize: memoryLog,
+			minStack:   minStack(i+2, 0),
+			maxStack:   maxStack(i+2, 0),
+			writes:     true,
+		}
+	}
+
+	return t
+}
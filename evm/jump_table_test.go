@@ -0,0 +1,70 @@
+package evm
+
+import (
+	"testing"
+
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/evm/uint256"
+)
+
+func TestJumpTableForks(t *testing.T) {
+	cases := []struct {
+		config chain.ForksInTime
+		op     OpCode
+		found  bool
+	}{
+		{chain.ForksInTime{}, DELEGATECALL, false},
+		{chain.ForksInTime{Homestead: true}, DELEGATECALL, true},
+		{chain.ForksInTime{Homestead: true}, REVERT, false},
+		{chain.ForksInTime{Byzantium: true}, REVERT, true},
+		{chain.ForksInTime{Byzantium: true}, STATICCALL, true},
+		{chain.ForksInTime{Byzantium: true}, SHL, false},
+		{chain.ForksInTime{Constantinople: true}, SHL, true},
+		{chain.ForksInTime{Constantinople: true}, CREATE2, true},
+		{chain.ForksInTime{Constantinople: true}, CHAINID, false},
+		{chain.ForksInTime{Istanbul: true}, CHAINID, true},
+		{chain.ForksInTime{Istanbul: true}, SELFBALANCE, true},
+		{chain.AllForksEnabled.At(0), EXTCODEHASH, true},
+	}
+
+	for _, c := range cases {
+		table := newJumpTable(c.config, *chain.GasTableHomestead)
+		if found := table[c.op] != nil; found != c.found {
+			t.Fatalf("%s: expected %v but found %v", c.op.String(), c.found, found)
+		}
+	}
+
+	// the gas costs are taken from the gas table
+	table := newJumpTable(chain.ForksInTime{}, *chain.GasTableEIP150)
+	if table[SLOAD].constantGas != chain.GasTableEIP150.SLoad {
+		t.Fatal("bad sload gas")
+	}
+
+	// tables are only built once
+	if table != newJumpTable(chain.ForksInTime{}, *chain.GasTableEIP150) {
+		t.Fatal("expected the same table")
+	}
+}
+
+func TestJumpTableStackValidation(t *testing.T) {
+	evm := testEVM([]byte{})
+	c := evm.currentContract()
+
+	c.push(uint256.NewInt(1))
+	if err := evm.executeOperation(c, evm.jumpTable[ADD]); err != ErrStackUnderflow {
+		t.Fatalf("expected stack underflow but found %v", err)
+	}
+
+	for c.sp < stackLimit {
+		c.push(uint256.NewInt(1))
+	}
+	if err := evm.executeOperation(c, evm.jumpTable[PUSH1]); err != ErrStackOverflow {
+		t.Fatalf("expected stack overflow but found %v", err)
+	}
+	if err := evm.executeOperation(c, evm.jumpTable[ADD]); err != nil {
+		t.Fatal(err)
+	}
+	if c.sp != stackLimit-1 {
+		t.Fatalf("expected %d items but found %d", stackLimit-1, c.sp)
+	}
+}
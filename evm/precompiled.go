@@ -34,9 +34,29 @@ var ContractsByzantium = map[common.Address]Precompiled{
 	common.BytesToAddress([]byte{3}): &ripemd160hash{},
 	common.BytesToAddress([]byte{4}): &dataCopy{},
 	common.BytesToAddress([]byte{5}): &bigModExp{},
-	common.BytesToAddress([]byte{6}): &bn256Add{},
-	common.BytesToAddress([]byte{7}): &bn256ScalarMul{},
-	common.BytesToAddress([]byte{8}): &bn256Pairing{},
+	common.BytesToAddress([]byte{6}): &bn256Add{gas: params.Bn256AddGas},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMul{gas: params.Bn256ScalarMulGas},
+	common.BytesToAddress([]byte{8}): &bn256Pairing{baseGas: params.Bn256PairingBaseGas, perPointGas: params.Bn256PairingPerPointGas},
+}
+
+// Gas costs of the bn256 contracts after EIP-1108
+const (
+	Bn256AddGasIstanbul             uint64 = 150
+	Bn256ScalarMulGasIstanbul       uint64 = 6000
+	Bn256PairingBaseGasIstanbul     uint64 = 45000
+	Bn256PairingPerPointGasIstanbul uint64 = 34000
+)
+
+var ContractsIstanbul = map[common.Address]Precompiled{
+	common.BytesToAddress([]byte{1}): &ecrecover{},
+	common.BytesToAddress([]byte{2}): &sha256hash{},
+	common.BytesToAddress([]byte{3}): &ripemd160hash{},
+	common.BytesToAddress([]byte{4}): &dataCopy{},
+	common.BytesToAddress([]byte{5}): &bigModExp{},
+	common.BytesToAddress([]byte{6}): &bn256Add{gas: Bn256AddGasIstanbul},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMul{gas: Bn256ScalarMulGasIstanbul},
+	common.BytesToAddress([]byte{8}): &bn256Pairing{baseGas: Bn256PairingBaseGasIstanbul, perPointGas: Bn256PairingPerPointGasIstanbul},
+	common.BytesToAddress([]byte{9}): &blake2F{},
 }
 
 type ecrecover struct{}
@@ -256,11 +276,13 @@ func newTwistPoint(blob []byte) (*bn256.G2, error) {
 }
 
 // bn256Add implements a native elliptic curve point addition.
-type bn256Add struct{}
+type bn256Add struct {
+	gas uint64
+}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
 func (c *bn256Add) Gas(input []byte) uint64 {
-	return params.Bn256AddGas
+	return c.gas
 }
 
 func (c *bn256Add) Call(input []byte) ([]byte, error) {
@@ -278,11 +300,13 @@ func (c *bn256Add) Call(input []byte) ([]byte, error) {
 }
 
 // bn256ScalarMul implements a native elliptic curve scalar multiplication.
-type bn256ScalarMul struct{}
+type bn256ScalarMul struct {
+	gas uint64
+}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
 func (c *bn256ScalarMul) Gas(input []byte) uint64 {
-	return params.Bn256ScalarMulGas
+	return c.gas
 }
 
 func (c *bn256ScalarMul) Call(input []byte) ([]byte, error) {
@@ -305,11 +329,14 @@ var false32Byte = make([]byte, 32)
 var errBadPairingInput = errors.New("bad elliptic curve pairing size")
 
 // bn256Pairing implements a pairing pre-compile for the bn256 curve
-type bn256Pairing struct{}
+type bn256Pairing struct {
+	baseGas     uint64
+	perPointGas uint64
+}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
 func (c *bn256Pairing) Gas(input []byte) uint64 {
-	return params.Bn256PairingBaseGas + uint64(len(input)/192)*params.Bn256PairingPerPointGas
+	return c.baseGas + uint64(len(input)/192)*c.perPointGas
 }
 
 func (c *bn256Pairing) Call(input []byte) ([]byte, error) {
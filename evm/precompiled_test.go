@@ -0,0 +1,49 @@
+package evm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const blake2FInput = "48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b616263000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000003000000000000000000000000000000"
+
+// Test vectors of EIP-152
+var blake2FTests = []struct {
+	input  string
+	output string
+	err    error
+}{
+	{"0x", "", errBlake2FInvalidInputLength},
+	{"0x00000c" + blake2FInput + "01", "", errBlake2FInvalidInputLength},
+	{"0x000000000c" + blake2FInput + "01", "", errBlake2FInvalidInputLength},
+	{"0x0000000c" + blake2FInput + "02", "", errBlake2FInvalidFinalFlag},
+	{"0x00000000" + blake2FInput + "01", "0x08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b", nil},
+	{"0x0000000c" + blake2FInput + "01", "0xba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923", nil},
+	{"0x0000000c" + blake2FInput + "00", "0x75ab69d3190a562c51aef8d88f1c2775876944407270c42c9844252c26d2875298743e7f6d5ea2f2d3e8d226039cd31b4e426ac4f2d3d666a610c2116fde4735", nil},
+	{"0x00000001" + blake2FInput + "01", "0xb63a380cb2897d521994a85234ee2c181b5f844d2c624c002677e9703449d2fba551b3a8333bcdf5f2f7e08993d53923de3d64fcc68c034e717b9293fed7a421", nil},
+}
+
+func TestPrecompiledBlake2F(t *testing.T) {
+	c := ContractsIstanbul[common.BytesToAddress([]byte{9})]
+
+	for _, tt := range blake2FTests {
+		input := hexutil.MustDecode(tt.input)
+
+		output, err := c.Call(input)
+		if err != tt.err {
+			t.Fatalf("expected error %v but found %v", tt.err, err)
+		}
+		if tt.err != nil {
+			continue
+		}
+		if !bytes.Equal(output, hexutil.MustDecode(tt.output)) {
+			t.Fatalf("expected %s but found %s", tt.output, hexutil.Encode(output))
+		}
+		if gas := c.Gas(input); gas != uint64(input[3]) {
+			t.Fatalf("expected gas %d but found %d", input[3], gas)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tracer is notified about the execution of the EVM. A nil tracer
+// does not add any overhead to the execution.
+type Tracer interface {
+	// StepConfig returns the state of the contract copied in the steps
+	StepConfig() StepConfig
+
+	// CaptureStart is called before the message is executed
+	CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int)
+
+	// CaptureState is called after each opcode is executed successfully
+	CaptureState(step *Step)
+
+	// CaptureFault is called when an opcode fails
+	CaptureFault(step *Step, err error)
+
+	// CaptureEnd is called after the message is executed. A reverted message
+	// fails with ErrExecutionReverted and the output of a create is the code.
+	CaptureEnd(output []byte, gasUsed uint64, err error)
+}
+
+// FrameTracer is a Tracer that is also notified when a call or a create
+// opens a new frame in the EVM and when the frame finishes
+type FrameTracer interface {
+	Tracer
+
+	// CaptureEnter is called when a frame is created by an opcode. For
+	// SELFDESTRUCT, to is the beneficiary and the frame is closed right away.
+	CaptureEnter(op OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int)
+
+	// CaptureExit is called when the last created frame finishes
+	CaptureExit(output []byte, gasLeft uint64, err error)
+}
+
+// StepConfig selects the state copied in each step. Copying the
+// memory and the stack on every opcode is expensive, only the
+// tracers that use them should enable them.
+type StepConfig struct {
+	EnableMemory     bool
+	EnableStack      bool
+	EnableReturnData bool
+}
+
+// Step is the state of the EVM before an opcode is executed,
+// the cost of the opcode is only known once it is executed.
+// Memory, Stack and ReturnData are only set if they are enabled
+// in the StepConfig of the tracer.
+type Step struct {
+	PC         uint64
+	Op         OpCode
+	Gas        uint64
+	Cost       uint64
+	MemorySize int
+	Memory     []byte
+	Stack      []*big.Int
+	ReturnData []byte
+	Refund     uint64
+	Depth      int
+
+	contract *Contract
+}
+
+// newStep takes a copy of the state of the current contract
+// before the opcode is executed
+func (e *EVM) newStep(op OpCode) *Step {
+	c := e.currentContract()
+	config := e.Tracer.StepConfig()
+
+	step := &Step{
+		PC:         uint64(c.ip),
+		Op:         op,
+		Gas:        c.gas,
+		MemorySize: len(c.memory.store),
+		Depth:      e.Depth(),
+		contract:   c,
+	}
+	if config.EnableMemory {
+		step.Memory = append([]byte{}, c.memory.store...)
+	}
+	if config.EnableStack {
+		step.Stack = make([]*big.Int, c.sp)
+		for i := 0; i < c.sp; i++ {
+			step.Stack[i] = c.stack[i].ToBig()
+		}
+	}
+	if config.EnableReturnData {
+		step.ReturnData = append([]byte{}, e.returnData...)
+	}
+	if e.state != nil {
+		step.Refund = e.state.GetRefund()
+	}
+	return step
+}
+
+// captureStep sends the step to the tracer once the opcode is executed
+func (e *EVM) captureStep(step *Step, err error) {
+	step.Cost = step.Gas - step.contract.gas
+
+	// errors of the frame created by the opcode (i.e. max call depth)
+	// are not errors of the opcode
+	if e.currentContract() != step.contract {
+		err = nil
+	}
+	if err != nil {
+		e.Tracer.CaptureFault(step, err)
+	} else {
+		e.Tracer.CaptureState(step)
+	}
+}
+
+// captureEnd sends the result of the message to the tracer, op is
+// the last opcode executed by the message
+func (e *EVM) captureEnd(c *Contract, gas uint64, op OpCode, err error) {
+	if e.Tracer == nil {
+		return
+	}
+
+	var output []byte
+	if err == nil {
+		output = e.returnData
+		if op == REVERT {
+			err = ErrExecutionReverted
+		} else if c.creation {
+			output = e.state.GetCode(c.address)
+		}
+	}
+	e.Tracer.CaptureEnd(output, gas-c.gas, err)
+}
+
+func (e *EVM) captureEnter(op OpCode, c *Contract) {
+	t, ok := e.Tracer.(FrameTracer)
+	if !ok {
+		return
+	}
+
+	var value *big.Int
+	if op != DELEGATECALL && op != STATICCALL {
+		value = c.value
+	}
+	input := c.input
+	if c.creation {
+		input = c.code
+	}
+	t.CaptureEnter(op, e.currentContract().address, c.codeAddress, input, c.gas, value)
+}
+
+func (e *EVM) captureExit(c *Contract, op OpCode, err error) {
+	t, ok := e.Tracer.(FrameTracer)
+	if !ok {
+		return
+	}
+
+	var output []byte
+	if err == nil {
+		output = e.returnData
+		if op == REVERT {
+			err = ErrExecutionReverted
+		} else if c.creation {
+			output = e.state.GetCode(c.address)
+		}
+	}
+	t.CaptureExit(output, c.gas, err)
+}
+
+func (e *EVM) captureSelfDestruct(beneficiary common.Address, balance *big.Int) {
+	t, ok := e.Tracer.(FrameTracer)
+	if !ok {
+		return
+	}
+
+	t.CaptureEnter(SELFDESTRUCT, e.currentContract().address, beneficiary, nil, 0, balance)
+	t.CaptureExit(nil, 0, nil)
+}
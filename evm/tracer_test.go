@@ -0,0 +1,175 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/umbracle/minimal/chain"
+)
+
+type testTracer struct {
+	config  StepConfig
+	started bool
+	steps   []*Step
+	fault   error
+	output  []byte
+	gasUsed uint64
+	err     error
+}
+
+func (t *testTracer) StepConfig() StepConfig {
+	return t.config
+}
+
+func (t *testTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.started = true
+}
+
+func (t *testTracer) CaptureState(step *Step) {
+	t.steps = append(t.steps, step)
+}
+
+func (t *testTracer) CaptureFault(step *Step, err error) {
+	t.steps = append(t.steps, step)
+	t.fault = err
+}
+
+func (t *testTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.output, t.gasUsed, t.err = output, gasUsed, err
+}
+
+func traceCode(t *testing.T, code []byte, config StepConfig) *testTracer {
+	state := newState(t)
+
+	addr := common.HexToAddress("100")
+	state.SetCode(addr, code)
+
+	tracer := &testTracer{config: config}
+
+	e := NewEVM(state, &Env{}, chain.ForksInTime{}, *chain.GasTableHomestead, nil)
+	e.Tracer = tracer
+	e.Call(common.HexToAddress("2"), addr, nil, big.NewInt(0), 100000)
+
+	return tracer
+}
+
+func TestTracer(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x1,
+		byte(PUSH1), 0x2,
+		byte(ADD),
+		byte(PUSH1), 0x0,
+		byte(MSTORE),
+		byte(PUSH1), 0x20,
+		byte(PUSH1), 0x0,
+		byte(RETURN),
+	}
+
+	tracer := traceCode(t, code, StepConfig{EnableMemory: true, EnableStack: true})
+	if !tracer.started {
+		t.Fatal("start not captured")
+	}
+
+	expected := []struct {
+		pc   uint64
+		op   OpCode
+		cost uint64
+	}{
+		{0, PUSH1, 3},
+		{2, PUSH1, 3},
+		{4, ADD, 3},
+		{5, PUSH1, 3},
+		{7, MSTORE, 6},
+		{8, PUSH1, 3},
+		{10, PUSH1, 3},
+		{12, RETURN, 0},
+	}
+	if len(tracer.steps) != len(expected) {
+		t.Fatalf("expected %d steps but found %d", len(expected), len(tracer.steps))
+	}
+
+	gas := uint64(100000)
+	for i, c := range expected {
+		step := tracer.steps[i]
+		if step.PC != c.pc || step.Op != c.op || step.Cost != c.cost {
+			t.Fatalf("step %d: expected %d %s %d but found %d %s %d", i, c.pc, c.op, c.cost, step.PC, step.Op, step.Cost)
+		}
+		if step.Gas != gas {
+			t.Fatalf("step %d: expected gas %d but found %d", i, gas, step.Gas)
+		}
+		if step.Depth != 1 {
+			t.Fatalf("step %d: bad depth %d", i, step.Depth)
+		}
+		gas -= c.cost
+	}
+
+	// the stack and the memory are taken before the opcode
+	if add := tracer.steps[2]; len(add.Stack) != 2 || add.Stack[0].Uint64() != 1 || add.Stack[1].Uint64() != 2 {
+		t.Fatal("bad stack before add")
+	}
+	if mstore := tracer.steps[4]; len(mstore.Memory) != 0 {
+		t.Fatal("memory should be empty before mstore")
+	}
+	if ret := tracer.steps[7]; len(ret.Memory) != 32 || ret.Memory[31] != 3 || ret.MemorySize != 32 {
+		t.Fatal("bad memory before return")
+	}
+
+	if tracer.err != nil || tracer.fault != nil {
+		t.Fatal("it should not fail")
+	}
+	if len(tracer.output) != 32 || tracer.output[31] != 3 {
+		t.Fatal("bad output")
+	}
+	if tracer.gasUsed != 24 {
+		t.Fatalf("expected 24 gas used but found %d", tracer.gasUsed)
+	}
+}
+
+func TestTracerFault(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x5,
+		byte(JUMP),
+	}
+
+	tracer := traceCode(t, code, StepConfig{})
+	if tracer.fault != ErrJumpDestNotValid {
+		t.Fatalf("expected invalid jump but found %v", tracer.fault)
+	}
+	if last := tracer.steps[len(tracer.steps)-1]; last.Op != JUMP {
+		t.Fatalf("expected the fault at jump but found %s", last.Op)
+	}
+	if tracer.err != ErrJumpDestNotValid {
+		t.Fatal("the message should fail")
+	}
+	if tracer.gasUsed != 100000 {
+		t.Fatal("all the gas should be consumed")
+	}
+}
+
+func TestTracerStepConfig(t *testing.T) {
+	code := []byte{
+		byte(PUSH1), 0x1,
+		byte(PUSH1), 0x0,
+		byte(MSTORE),
+		byte(PUSH1), 0x20,
+		byte(PUSH1), 0x0,
+		byte(RETURN),
+	}
+
+	// the state of the contract is not copied unless it is enabled
+	tracer := traceCode(t, code, StepConfig{})
+	for i, step := range tracer.steps {
+		if step.Memory != nil || step.Stack != nil || step.ReturnData != nil {
+			t.Fatalf("step %d: the state should not be copied", i)
+		}
+	}
+	if ret := tracer.steps[5]; ret.MemorySize != 32 {
+		t.Fatalf("expected memory size 32 but found %d", ret.MemorySize)
+	}
+
+	tracer = traceCode(t, code, StepConfig{EnableStack: true})
+	if mstore := tracer.steps[2]; len(mstore.Stack) != 2 || mstore.Memory != nil {
+		t.Fatal("only the stack should be copied")
+	}
+}
@@ -0,0 +1,639 @@
+// Package uint256 implements the fixed size 256-bit unsigned integer used
+// by the stack of the EVM. All the operations wrap modulo 2^256 and the
+// signed operations use the two's complement representation.
+package uint256
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+// Int is an unsigned 256-bit integer stored as four 64-bit words
+// with the least significant word first
+type Int [4]uint64
+
+// NewInt returns a new Int set to v
+func NewInt(v uint64) *Int {
+	return &Int{v, 0, 0, 0}
+}
+
+// Clear sets z to 0
+func (z *Int) Clear() *Int {
+	*z = Int{}
+	return z
+}
+
+// SetOne sets z to 1
+func (z *Int) SetOne() *Int {
+	*z = Int{1, 0, 0, 0}
+	return z
+}
+
+// SetAllOne sets all the bits of z to 1 (-1 in two's complement)
+func (z *Int) SetAllOne() *Int {
+	*z = Int{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+	return z
+}
+
+// Set sets z to x
+func (z *Int) Set(x *Int) *Int {
+	*z = *x
+	return z
+}
+
+// SetUint64 sets z to v
+func (z *Int) SetUint64(v uint64) *Int {
+	*z = Int{v, 0, 0, 0}
+	return z
+}
+
+// SetBytes interprets buf as a big-endian unsigned integer and sets z to it.
+// Only the last 32 bytes are used if buf is larger.
+func (z *Int) SetBytes(buf []byte) *Int {
+	if len(buf) > 32 {
+		buf = buf[len(buf)-32:]
+	}
+	var b [32]byte
+	copy(b[32-len(buf):], buf)
+
+	z[3] = binary.BigEndian.Uint64(b[0:8])
+	z[2] = binary.BigEndian.Uint64(b[8:16])
+	z[1] = binary.BigEndian.Uint64(b[16:24])
+	z[0] = binary.BigEndian.Uint64(b[24:32])
+	return z
+}
+
+// SetFromBig sets z to b modulo 2^256. Negative values are
+// stored in two's complement.
+func (z *Int) SetFromBig(b *big.Int) *Int {
+	z.SetBytes(b.Bytes())
+	if b.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+// Bytes32 returns the 32 bytes big-endian representation of z
+func (z *Int) Bytes32() [32]byte {
+	var b [32]byte
+	binary.BigEndian.PutUint64(b[0:8], z[3])
+	binary.BigEndian.PutUint64(b[8:16], z[2])
+	binary.BigEndian.PutUint64(b[16:24], z[1])
+	binary.BigEndian.PutUint64(b[24:32], z[0])
+	return b
+}
+
+// Bytes returns the big-endian representation of z without leading zeros
+func (z *Int) Bytes() []byte {
+	b := z.Bytes32()
+	return b[32-z.ByteLen():]
+}
+
+// ToBig returns z as a big.Int
+func (z *Int) ToBig() *big.Int {
+	b := z.Bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// String returns the decimal representation of z
+func (z *Int) String() string {
+	return z.ToBig().String()
+}
+
+// Uint64 returns the lower 64 bits of z
+func (z *Int) Uint64() uint64 {
+	return z[0]
+}
+
+// IsUint64 reports whether z can be represented as a uint64
+func (z *Int) IsUint64() bool {
+	return z[1]|z[2]|z[3] == 0
+}
+
+// IsZero reports whether z is 0
+func (z *Int) IsZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// BitLen returns the number of bits required to represent z
+func (z *Int) BitLen() int {
+	switch {
+	case z[3] != 0:
+		return 192 + bits.Len64(z[3])
+	case z[2] != 0:
+		return 128 + bits.Len64(z[2])
+	case z[1] != 0:
+		return 64 + bits.Len64(z[1])
+	default:
+		return bits.Len64(z[0])
+	}
+}
+
+// ByteLen returns the number of bytes required to represent z
+func (z *Int) ByteLen() int {
+	return (z.BitLen() + 7) / 8
+}
+
+// Sign returns the sign of z interpreted as a two's complement number:
+// -1 if negative, 0 if zero and +1 if positive
+func (z *Int) Sign() int {
+	if z.IsZero() {
+		return 0
+	}
+	if z[3] < 0x8000000000000000 {
+		return 1
+	}
+	return -1
+}
+
+// Eq reports whether z == x
+func (z *Int) Eq(x *Int) bool {
+	return *z == *x
+}
+
+// Lt reports whether z < x
+func (z *Int) Lt(x *Int) bool {
+	_, borrow := bits.Sub64(z[0], x[0], 0)
+	_, borrow = bits.Sub64(z[1], x[1], borrow)
+	_, borrow = bits.Sub64(z[2], x[2], borrow)
+	_, borrow = bits.Sub64(z[3], x[3], borrow)
+	return borrow != 0
+}
+
+// Gt reports whether z > x
+func (z *Int) Gt(x *Int) bool {
+	return x.Lt(z)
+}
+
+// Cmp compares z and x and returns -1, 0 or +1
+func (z *Int) Cmp(x *Int) int {
+	switch {
+	case z.Lt(x):
+		return -1
+	case z.Eq(x):
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Slt reports whether z < x, both interpreted as signed numbers
+func (z *Int) Slt(x *Int) bool {
+	zSign, xSign := z.Sign(), x.Sign()
+
+	switch {
+	case zSign >= 0 && xSign < 0:
+		return false
+	case zSign < 0 && xSign >= 0:
+		return true
+	default:
+		return z.Lt(x)
+	}
+}
+
+// Sgt reports whether z > x, both interpreted as signed numbers
+func (z *Int) Sgt(x *Int) bool {
+	return x.Slt(z)
+}
+
+// Add sets z to x + y
+func (z *Int) Add(x, y *Int) *Int {
+	z.AddOverflow(x, y)
+	return z
+}
+
+// AddOverflow sets z to x + y and reports whether the sum overflowed
+func (z *Int) AddOverflow(x, y *Int) (*Int, bool) {
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	return z, carry != 0
+}
+
+// Sub sets z to x - y
+func (z *Int) Sub(x, y *Int) *Int {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], _ = bits.Sub64(x[3], y[3], borrow)
+	return z
+}
+
+// Neg sets z to -x
+func (z *Int) Neg(x *Int) *Int {
+	return z.Sub(&Int{}, x)
+}
+
+// Abs sets z to the absolute value of x interpreted as a signed number
+func (z *Int) Abs(x *Int) *Int {
+	if x.Sign() < 0 {
+		return z.Neg(x)
+	}
+	return z.Set(x)
+}
+
+// Mul sets z to x * y
+func (z *Int) Mul(x, y *Int) *Int {
+	var res Int
+	for j := 0; j < 4; j++ {
+		var carry uint64
+		for i := 0; i+j < 4; i++ {
+			carry, res[i+j] = umulStep(res[i+j], x[i], y[j], carry)
+		}
+	}
+	*z = res
+	return z
+}
+
+// Div sets z to x / y. If y is zero z is set to zero.
+func (z *Int) Div(x, y *Int) *Int {
+	if y.IsZero() || y.Gt(x) {
+		return z.Clear()
+	}
+	if x.Eq(y) {
+		return z.SetOne()
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x.Uint64() / y.Uint64())
+	}
+
+	var quot Int
+	udivrem(quot[:], x[:], y)
+	return z.Set(&quot)
+}
+
+// Mod sets z to x % y. If y is zero z is set to zero.
+func (z *Int) Mod(x, y *Int) *Int {
+	if x.IsZero() || y.IsZero() {
+		return z.Clear()
+	}
+	switch x.Cmp(y) {
+	case -1:
+		return z.Set(x)
+	case 0:
+		return z.Clear()
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x.Uint64() % y.Uint64())
+	}
+
+	var quot Int
+	*z = udivrem(quot[:], x[:], y)
+	return z
+}
+
+// SDiv sets z to x / y, both interpreted as signed numbers.
+// The result is truncated towards zero.
+func (z *Int) SDiv(x, y *Int) *Int {
+	var a, b Int
+	a.Abs(x)
+	b.Abs(y)
+
+	neg := x.Sign()*y.Sign() < 0
+	z.Div(&a, &b)
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// SMod sets z to x % y, both interpreted as signed numbers.
+// The result takes the sign of x.
+func (z *Int) SMod(x, y *Int) *Int {
+	var a, b Int
+	a.Abs(x)
+	b.Abs(y)
+
+	neg := x.Sign() < 0
+	z.Mod(&a, &b)
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// AddMod sets z to (x + y) % m computed without overflow.
+// If m is zero z is set to zero.
+func (z *Int) AddMod(x, y, m *Int) *Int {
+	if m.IsZero() {
+		return z.Clear()
+	}
+
+	var sum Int
+	if _, overflow := sum.AddOverflow(x, y); !overflow {
+		return z.Mod(&sum, m)
+	}
+
+	var quot [8]uint64
+	u := [5]uint64{sum[0], sum[1], sum[2], sum[3], 1}
+	*z = udivrem(quot[:], u[:], m)
+	return z
+}
+
+// MulMod sets z to (x * y) % m computed without overflow.
+// If m is zero z is set to zero.
+func (z *Int) MulMod(x, y, m *Int) *Int {
+	if x.IsZero() || y.IsZero() || m.IsZero() {
+		return z.Clear()
+	}
+
+	p := umul(x, y)
+	if p[4]|p[5]|p[6]|p[7] == 0 {
+		low := Int{p[0], p[1], p[2], p[3]}
+		return z.Mod(&low, m)
+	}
+
+	var quot [8]uint64
+	*z = udivrem(quot[:], p[:], m)
+	return z
+}
+
+// Exp sets z to base ** exponent modulo 2^256
+func (z *Int) Exp(base, exponent *Int) *Int {
+	res, b, e := Int{1, 0, 0, 0}, *base, *exponent
+
+	n := e.BitLen()
+	for i := 0; i < n; i++ {
+		if (e[i/64]>>uint(i%64))&1 == 1 {
+			res.Mul(&res, &b)
+		}
+		b.Mul(&b, &b)
+	}
+	*z = res
+	return z
+}
+
+// SignExtend sets z to x with the sign bit of the byte at position
+// byteNum (counting from the least significant byte) extended to the
+// higher bits. If byteNum is larger than 30 z is set to x.
+func (z *Int) SignExtend(x, byteNum *Int) *Int {
+	if !byteNum.IsUint64() || byteNum.Uint64() > 30 {
+		return z.Set(x)
+	}
+	bit := uint(byteNum.Uint64()*8 + 7)
+
+	var mask Int
+	mask.Lsh(NewInt(1), bit)
+	mask.Sub(&mask, NewInt(1))
+
+	if (x[bit/64]>>(bit%64))&1 == 1 {
+		return z.Or(x, mask.Not(&mask))
+	}
+	return z.And(x, &mask)
+}
+
+// Not sets z to ^x
+func (z *Int) Not(x *Int) *Int {
+	z[0], z[1], z[2], z[3] = ^x[0], ^x[1], ^x[2], ^x[3]
+	return z
+}
+
+// And sets z to x & y
+func (z *Int) And(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]&y[0], x[1]&y[1], x[2]&y[2], x[3]&y[3]
+	return z
+}
+
+// Or sets z to x | y
+func (z *Int) Or(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]|y[0], x[1]|y[1], x[2]|y[2], x[3]|y[3]
+	return z
+}
+
+// Xor sets z to x ^ y
+func (z *Int) Xor(x, y *Int) *Int {
+	z[0], z[1], z[2], z[3] = x[0]^y[0], x[1]^y[1], x[2]^y[2], x[3]^y[3]
+	return z
+}
+
+// Byte sets z to the byte of x at position n, where 0 is the most
+// significant byte. Positions larger than 31 set z to zero.
+func (z *Int) Byte(x, n *Int) *Int {
+	if !n.IsUint64() || n.Uint64() > 31 {
+		return z.Clear()
+	}
+	index := n.Uint64()
+	word := x[3-index/8]
+	shift := (7 - index%8) * 8
+	return z.SetUint64((word >> shift) & 0xff)
+}
+
+// Lsh sets z to x << n
+func (z *Int) Lsh(x *Int, n uint) *Int {
+	if n >= 256 {
+		return z.Clear()
+	}
+	words, shift := int(n/64), n%64
+
+	var res Int
+	for i := 3; i >= words; i-- {
+		res[i] = x[i-words] << shift
+		if i-words > 0 {
+			res[i] |= x[i-words-1] >> (64 - shift)
+		}
+	}
+	*z = res
+	return z
+}
+
+// Rsh sets z to x >> n filling the higher bits with zeros
+func (z *Int) Rsh(x *Int, n uint) *Int {
+	if n >= 256 {
+		return z.Clear()
+	}
+	words, shift := int(n/64), n%64
+
+	var res Int
+	for i := 0; i < 4-words; i++ {
+		res[i] = x[i+words] >> shift
+		if i+words < 3 {
+			res[i] |= x[i+words+1] << (64 - shift)
+		}
+	}
+	*z = res
+	return z
+}
+
+// SRsh sets z to x >> n filling the higher bits with the sign bit of x
+func (z *Int) SRsh(x *Int, n uint) *Int {
+	if x.Sign() >= 0 {
+		return z.Rsh(x, n)
+	}
+	if n >= 255 {
+		return z.SetAllOne()
+	}
+
+	var mask Int
+	mask.SetAllOne()
+	mask.Lsh(&mask, 256-n)
+
+	z.Rsh(x, n)
+	return z.Or(z, &mask)
+}
+
+// umulStep computes (hi * 2^64 + lo) = z + (x * y) + carry
+func umulStep(z, x, y, carry uint64) (hi, lo uint64) {
+	hi, lo = bits.Mul64(x, y)
+	lo, c := bits.Add64(lo, carry, 0)
+	hi += c
+	lo, c = bits.Add64(lo, z, 0)
+	hi += c
+	return hi, lo
+}
+
+// umul computes the full 512-bit product of x and y
+func umul(x, y *Int) [8]uint64 {
+	var res [8]uint64
+	for j := 0; j < 4; j++ {
+		var carry uint64
+		for i := 0; i < 4; i++ {
+			carry, res[i+j] = umulStep(res[i+j], x[i], y[j], carry)
+		}
+		res[j+4] = carry
+	}
+	return res
+}
+
+// udivrem divides u by d, stores the quotient in quot and returns the
+// remainder. quot must be zeroed and hold at least len(u) words.
+// It uses the Knuth's algorithm D (The Art of Computer Programming,
+// Vol. 2, 4.3.1).
+func udivrem(quot, u []uint64, d *Int) Int {
+	var dLen int
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i] != 0 {
+			dLen = i + 1
+			break
+		}
+	}
+
+	var uLen int
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] != 0 {
+			uLen = i + 1
+			break
+		}
+	}
+
+	var rem Int
+	if uLen < dLen {
+		copy(rem[:], u)
+		return rem
+	}
+
+	// normalize the divisor so that its most significant bit is set
+	shift := uint(bits.LeadingZeros64(d[dLen-1]))
+
+	var dnStorage Int
+	dn := dnStorage[:dLen]
+	for i := dLen - 1; i > 0; i-- {
+		dn[i] = (d[i] << shift) | (d[i-1] >> (64 - shift))
+	}
+	dn[0] = d[0] << shift
+
+	var unStorage [9]uint64
+	un := unStorage[:uLen+1]
+	un[uLen] = u[uLen-1] >> (64 - shift)
+	for i := uLen - 1; i > 0; i-- {
+		un[i] = (u[i] << shift) | (u[i-1] >> (64 - shift))
+	}
+	un[0] = u[0] << shift
+
+	if dLen == 1 {
+		r := udivremBy1(quot, un, dn[0])
+		return *rem.SetUint64(r >> shift)
+	}
+
+	udivremKnuth(quot, un, dn)
+
+	// denormalize the remainder
+	for i := 0; i < dLen-1; i++ {
+		rem[i] = (un[i] >> shift) | (un[i+1] << (64 - shift))
+	}
+	rem[dLen-1] = un[dLen-1] >> shift
+	return rem
+}
+
+// udivremBy1 divides u by the normalized single word d and returns the remainder
+func udivremBy1(quot, u []uint64, d uint64) uint64 {
+	rem := u[len(u)-1]
+	for j := len(u) - 2; j >= 0; j-- {
+		quot[j], rem = bits.Div64(rem, u[j], d)
+	}
+	return rem
+}
+
+// udivremKnuth divides u by the normalized divisor d of at least two words.
+// The remainder is left in the lower words of u.
+func udivremKnuth(quot, u, d []uint64) {
+	dh := d[len(d)-1]
+	dl := d[len(d)-2]
+
+	for j := len(u) - len(d) - 1; j >= 0; j-- {
+		u2 := u[j+len(d)]
+		u1 := u[j+len(d)-1]
+		u0 := u[j+len(d)-2]
+
+		// estimate the quotient digit from the top words. If u2 == dh
+		// the estimate does not fit in a word and starts from b-1.
+		var qhat, rhat uint64
+		var rhatOverflow bool
+		if u2 >= dh {
+			var carry uint64
+			qhat = ^uint64(0)
+			rhat, carry = bits.Add64(u1, dh, 0)
+			rhatOverflow = carry != 0
+		} else {
+			qhat, rhat = bits.Div64(u2, u1, dh)
+		}
+
+		// refine the estimate, it is at most two units too large
+		for !rhatOverflow {
+			ph, pl := bits.Mul64(qhat, dl)
+			if ph < rhat || (ph == rhat && pl <= u0) {
+				break
+			}
+			qhat--
+
+			var carry uint64
+			rhat, carry = bits.Add64(rhat, dh, 0)
+			rhatOverflow = carry != 0
+		}
+
+		// multiply and subtract, add back if too much was subtracted
+		borrow := subMulTo(u[j:], d, qhat)
+		u[j+len(d)] = u2 - borrow
+		if u2 < borrow {
+			qhat--
+			u[j+len(d)] += addTo(u[j:], d)
+		}
+
+		quot[j] = qhat
+	}
+}
+
+// subMulTo computes x -= y * multiplier and returns the borrow
+func subMulTo(x, y []uint64, multiplier uint64) uint64 {
+	var borrow uint64
+	for i := 0; i < len(y); i++ {
+		s, carry1 := bits.Sub64(x[i], borrow, 0)
+		ph, pl := bits.Mul64(y[i], multiplier)
+		t, carry2 := bits.Sub64(s, pl, 0)
+		x[i] = t
+		borrow = ph + carry1 + carry2
+	}
+	return borrow
+}
+
+// addTo computes x += y and returns the carry
+func addTo(x, y []uint64) uint64 {
+	var carry uint64
+	for i := 0; i < len(y); i++ {
+		x[i], carry = bits.Add64(x[i], y[i], carry)
+	}
+	return carry
+}
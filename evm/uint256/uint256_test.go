@@ -0,0 +1,264 @@
+package uint256
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+var (
+	tt256   = new(big.Int).Lsh(big.NewInt(1), 256)
+	tt255   = new(big.Int).Lsh(big.NewInt(1), 255)
+	tt256m1 = new(big.Int).Sub(tt256, big.NewInt(1))
+)
+
+// u256 truncates x to 256 bits
+func u256(x *big.Int) *big.Int {
+	return x.And(x, tt256m1)
+}
+
+// s256 interprets x as a two's complement number
+func s256(x *big.Int) *big.Int {
+	if x.Cmp(tt255) < 0 {
+		return x
+	}
+	return new(big.Int).Sub(x, tt256)
+}
+
+// randInt returns random numbers biased towards the word boundaries
+func randInt(r *rand.Rand) *big.Int {
+	b := make([]byte, 32)
+	switch r.Intn(6) {
+	case 0:
+		return big.NewInt(int64(r.Intn(3)))
+	case 1:
+		return new(big.Int).Sub(tt256, big.NewInt(int64(r.Intn(3)+1)))
+	case 2:
+		r.Read(b[32-8*(r.Intn(4)+1):])
+	case 3:
+		r.Read(b)
+		b[r.Intn(32)] = 0xff
+	default:
+		r.Read(b)
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func fromBig(b *big.Int) *Int {
+	return new(Int).SetFromBig(b)
+}
+
+func checkResult(t *testing.T, name string, args []*big.Int, expected *big.Int, found *Int) {
+	t.Helper()
+
+	if found.ToBig().Cmp(expected) != 0 {
+		t.Fatalf("%s%v: expected %s but found %s", name, args, expected, found)
+	}
+}
+
+func TestBinaryOperations(t *testing.T) {
+	cases := map[string]struct {
+		op  func(z, x, y *Int) *Int
+		ref func(x, y *big.Int) *big.Int
+	}{
+		"add": {
+			(*Int).Add,
+			func(x, y *big.Int) *big.Int { return u256(new(big.Int).Add(x, y)) },
+		},
+		"sub": {
+			(*Int).Sub,
+			func(x, y *big.Int) *big.Int { return u256(new(big.Int).Sub(x, y)) },
+		},
+		"mul": {
+			(*Int).Mul,
+			func(x, y *big.Int) *big.Int { return u256(new(big.Int).Mul(x, y)) },
+		},
+		"div": {
+			(*Int).Div,
+			func(x, y *big.Int) *big.Int {
+				if y.Sign() == 0 {
+					return new(big.Int)
+				}
+				return new(big.Int).Div(x, y)
+			},
+		},
+		"mod": {
+			(*Int).Mod,
+			func(x, y *big.Int) *big.Int {
+				if y.Sign() == 0 {
+					return new(big.Int)
+				}
+				return new(big.Int).Mod(x, y)
+			},
+		},
+		"sdiv": {
+			(*Int).SDiv,
+			func(x, y *big.Int) *big.Int {
+				if y.Sign() == 0 {
+					return new(big.Int)
+				}
+				return u256(new(big.Int).Quo(s256(x), s256(y)))
+			},
+		},
+		"smod": {
+			(*Int).SMod,
+			func(x, y *big.Int) *big.Int {
+				if y.Sign() == 0 {
+					return new(big.Int)
+				}
+				return u256(new(big.Int).Rem(s256(x), s256(y)))
+			},
+		},
+		"exp": {
+			(*Int).Exp,
+			func(x, y *big.Int) *big.Int { return new(big.Int).Exp(x, y, tt256) },
+		},
+		"signextend": {
+			func(z, x, y *Int) *Int { return z.SignExtend(x, y) },
+			func(x, y *big.Int) *big.Int {
+				if y.Cmp(big.NewInt(31)) >= 0 {
+					return x
+				}
+				bit := uint(y.Uint64()*8 + 7)
+				mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bit), big.NewInt(1))
+				if x.Bit(int(bit)) == 1 {
+					return u256(new(big.Int).Or(x, new(big.Int).Not(mask)))
+				}
+				return new(big.Int).And(x, mask)
+			},
+		},
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for name, c := range cases {
+		for i := 0; i < 5000; i++ {
+			x, y := randInt(r), randInt(r)
+			if name == "signextend" {
+				y = big.NewInt(int64(r.Intn(34)))
+			}
+			expected := c.ref(x, y)
+
+			checkResult(t, name, []*big.Int{x, y}, expected, c.op(new(Int), fromBig(x), fromBig(y)))
+
+			// the result can be stored in any of the arguments
+			a, b := fromBig(x), fromBig(y)
+			checkResult(t, name, []*big.Int{x, y}, expected, c.op(a, a, b))
+			a, b = fromBig(x), fromBig(y)
+			checkResult(t, name, []*big.Int{x, y}, expected, c.op(b, a, b))
+		}
+	}
+}
+
+func TestModularOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		x, y, m := randInt(r), randInt(r), randInt(r)
+
+		var addmod, mulmod *big.Int
+		if m.Sign() == 0 {
+			addmod, mulmod = new(big.Int), new(big.Int)
+		} else {
+			addmod = new(big.Int).Mod(new(big.Int).Add(x, y), m)
+			mulmod = new(big.Int).Mod(new(big.Int).Mul(x, y), m)
+		}
+
+		checkResult(t, "addmod", []*big.Int{x, y, m}, addmod, new(Int).AddMod(fromBig(x), fromBig(y), fromBig(m)))
+		checkResult(t, "mulmod", []*big.Int{x, y, m}, mulmod, new(Int).MulMod(fromBig(x), fromBig(y), fromBig(m)))
+
+		z := fromBig(m)
+		checkResult(t, "addmod", []*big.Int{x, y, m}, addmod, z.AddMod(fromBig(x), fromBig(y), z))
+		z = fromBig(m)
+		checkResult(t, "mulmod", []*big.Int{x, y, m}, mulmod, z.MulMod(fromBig(x), fromBig(y), z))
+	}
+}
+
+func TestShiftOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		x, n := randInt(r), uint(r.Intn(260))
+
+		lsh := u256(new(big.Int).Lsh(x, n))
+		rsh := new(big.Int).Rsh(x, n)
+		srsh := u256(new(big.Int).Rsh(s256(x), n))
+
+		args := []*big.Int{x, big.NewInt(int64(n))}
+		checkResult(t, "lsh", args, lsh, new(Int).Lsh(fromBig(x), n))
+		checkResult(t, "rsh", args, rsh, new(Int).Rsh(fromBig(x), n))
+		checkResult(t, "srsh", args, srsh, new(Int).SRsh(fromBig(x), n))
+	}
+}
+
+func TestComparison(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		x, y := randInt(r), randInt(r)
+		if i%10 == 0 {
+			y = x
+		}
+		a, b := fromBig(x), fromBig(y)
+
+		if a.Lt(b) != (x.Cmp(y) < 0) || a.Gt(b) != (x.Cmp(y) > 0) || a.Eq(b) != (x.Cmp(y) == 0) {
+			t.Fatalf("unsigned comparison failed for %s and %s", x, y)
+		}
+		if a.Cmp(b) != x.Cmp(y) {
+			t.Fatalf("cmp failed for %s and %s", x, y)
+		}
+		sx, sy := s256(x), s256(y)
+		if a.Slt(b) != (sx.Cmp(sy) < 0) || a.Sgt(b) != (sx.Cmp(sy) > 0) {
+			t.Fatalf("signed comparison failed for %s and %s", x, y)
+		}
+		if a.Sign() != sx.Sign() {
+			t.Fatalf("sign failed for %s", x)
+		}
+	}
+}
+
+func TestByte(t *testing.T) {
+	x := new(Int).SetBytes([]byte{0xab, 0xcd})
+	for n, expected := range map[uint64]uint64{0: 0, 30: 0xab, 31: 0xcd, 32: 0} {
+		if found := new(Int).Byte(x, NewInt(n)); found.Uint64() != expected || !found.IsUint64() {
+			t.Fatalf("byte %d: expected %x but found %s", n, expected, found)
+		}
+	}
+
+	var y Int
+	y.SetAllOne()
+	if found := new(Int).Byte(&y, &y); !found.IsZero() {
+		t.Fatal("expected zero for large positions")
+	}
+}
+
+func TestBytesConversion(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		x := randInt(r)
+		z := fromBig(x)
+
+		if !bytes.Equal(z.Bytes(), x.Bytes()) {
+			t.Fatalf("bytes: expected %x but found %x", x.Bytes(), z.Bytes())
+		}
+		b := z.Bytes32()
+		if new(big.Int).SetBytes(b[:]).Cmp(x) != 0 {
+			t.Fatalf("bytes32: expected %x but found %x", x.Bytes(), b)
+		}
+		if z.BitLen() != x.BitLen() {
+			t.Fatalf("bitlen: expected %d but found %d", x.BitLen(), z.BitLen())
+		}
+		if z.IsUint64() != x.IsUint64() {
+			t.Fatalf("isuint64: failed for %s", x)
+		}
+	}
+
+	// only the last 32 bytes are used
+	buf := append([]byte{0x1}, make([]byte, 32)...)
+	buf[32] = 0x2
+	if z := new(Int).SetBytes(buf); z.Uint64() != 2 || !z.IsUint64() {
+		t.Fatalf("expected 2 but found %s", z)
+	}
+
+	// negative numbers are stored in two's complement
+	if z := new(Int).SetFromBig(big.NewInt(-1)); z.ToBig().Cmp(tt256m1) != 0 {
+		t.Fatalf("expected 2^256-1 but found %s", z)
+	}
+}
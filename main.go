@@ -1,16 +1,16 @@
 package main
 
 import (
+	"crypto/ecdsa"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/pprof"
-	"strings"
 	"syscall"
 	"time"
 
@@ -20,14 +20,18 @@ import (
 	"github.com/armon/go-metrics/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/umbracle/minimal/consensus"
+	"github.com/umbracle/minimal/consensus/clique"
+	"github.com/umbracle/minimal/consensus/dev"
 	"github.com/umbracle/minimal/consensus/ethash"
 
-	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/umbracle/minimal/blockchain"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/miner"
 	"github.com/umbracle/minimal/network"
 	"github.com/umbracle/minimal/protocol"
 	"github.com/umbracle/minimal/protocol/ethereum"
@@ -76,8 +80,9 @@ func startTelemetry() {
 // mainnet nodes
 var peers = []string{}
 
-var mainnetGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
-
+var chainName = flag.String("chain", "mainnet", "name of the built-in chain or path to a chain file")
+var dataDir = flag.String("datadir", "./minimal-data", "directory where the chain data is stored")
+var signerKey = flag.String("signerkey", "", "hex encoded private key to seal blocks in proof-of-authority chains")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var memprofile = flag.String("memprofile", "", "write memory profile to this file")
 
@@ -111,16 +116,26 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	// -- genesis
+	// -- chain
+
+	c, err := importChain(*chainName)
+	if err != nil {
+		panic(err)
+	}
 
-	mainnetGenesis := core.DefaultGenesisBlock().ToBlock(nil).Header()
-	if mainnetGenesis.Hash() != mainnetGenesisHash {
-		panic("mainnet block not correct")
+	// state storage
+	stateDB, err := ethdb.NewLDBDatabase(filepath.Join(*dataDir, "state"), 0, 0)
+	if err != nil {
+		panic(err)
 	}
+	st := state.NewDatabase(stateDB)
 
-	// -- chain config (block forks)
+	// -- genesis
 
-	chainConfig := consensus.NewChainConfig(1150000, 4370000, 0)
+	genesis, err := c.Genesis.Commit(st)
+	if err != nil {
+		panic(err)
+	}
 
 	logger := log.New(os.Stderr, "", log.LstdFlags)
 
@@ -134,7 +149,7 @@ func main() {
 	// Start network protocol
 
 	config := network.DefaultConfig()
-	config.Bootnodes = readFile("./foundation.txt")
+	config.Bootnodes = c.Bootnodes
 
 	server, err := network.NewServer("minimal", key, config, logger)
 	if err != nil {
@@ -142,31 +157,58 @@ func main() {
 	}
 
 	// blockchain storage
-	storage, err := storage.NewLevelDBStorage("/home/thor/Desktop/ethereum/minimal-test", nil)
+	storage, err := storage.NewLevelDBStorage(filepath.Join(*dataDir, "blockchain"), nil)
 	if err != nil {
 		panic(err)
 	}
 
-	// consensus
-	consensus := ethash.NewEthHash(chainConfig)
+	// consensus, the signer key is independent of the network key
+	var signer *ecdsa.PrivateKey
+	if *signerKey != "" {
+		if signer, err = crypto.HexToECDSA(*signerKey); err != nil {
+			panic(err)
+		}
+	}
+	engine, err := newConsensus(c.Params, storage, signer, *dataDir)
+	if err != nil {
+		panic(err)
+	}
 
 	// blockchain object
-	blockchain := blockchain.NewBlockchain(storage, consensus)
-	if err := blockchain.WriteGenesis(mainnetGenesis); err != nil {
+	blockchain := blockchain.NewBlockchain(storage, st, engine, c.Params)
+	if err := blockchain.WriteGenesis(genesis); err != nil {
 		panic(err)
 	}
 
+	// the dev chains and the clique signers seal their own blocks
+	var m *miner.Miner
+	if sealer, ok := engine.(consensus.Sealer); ok && (isDevChain(c.Params) || signer != nil) {
+		mc := miner.DefaultConfig()
+		mc.Logger = logger
+		if signer != nil {
+			mc.Coinbase = crypto.PubkeyToAddress(signer.PublicKey)
+		}
+
+		m = miner.NewMiner(mc, blockchain, sealer)
+		go m.Run()
+	}
+
 	cc := syncer.DefaultConfig()
 	cc.NumWorkers = 4
+	cc.Logger = logger
 
-	syncer, err := syncer.NewSyncer(1, blockchain, cc)
+	syncer, err := syncer.NewSyncer(c.Params.NetworkID, blockchain, cc)
 	if err != nil {
 		panic(err)
 	}
 
 	// register protocols
 	callback := func(conn network.Conn, peer *network.Peer) protocol.Handler {
-		return ethereum.NewEthereumProtocol(conn, peer, syncer.GetStatus, blockchain)
+		eth := ethereum.NewEthereumProtocol(conn, peer, syncer.GetStatus, blockchain)
+		if m != nil {
+			eth.SetTxPool(m)
+		}
+		return eth
 	}
 
 	server.RegisterProtocol(protocol.ETH63, callback)
@@ -191,6 +233,10 @@ func main() {
 
 	handleSignals(server)
 
+	if m != nil {
+		m.Close()
+	}
+
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
 		if err != nil {
@@ -230,10 +276,41 @@ func handleSignals(s *network.Server) int {
 	}
 }
 
-func readFile(s string) []string {
-	data, err := ioutil.ReadFile(s)
+// importChain loads one of the built-in chains or a chain file
+func importChain(name string) (*chain.Chain, error) {
+	if _, err := os.Stat(name); err == nil {
+		return chain.ImportFromFile(name)
+	}
+	return chain.ImportFromName(name)
+}
+
+// isDevChain returns true if the chain uses the dev engine
+func isDevChain(p *chain.Params) bool {
+	engine, err := p.EngineName()
+	return err == nil && engine == "dev"
+}
+
+// newConsensus creates the consensus engine of the chain
+func newConsensus(p *chain.Params, db *storage.Storage, key *ecdsa.PrivateKey, dataDir string) (consensus.Consensus, error) {
+	engine, err := p.EngineName()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	switch engine {
+	case "ethash":
+		config := ethash.DefaultConfig()
+		config.CacheDir = filepath.Join(dataDir, "ethash")
+		config.DatasetDir = filepath.Join(dataDir, "ethash")
+
+		return ethash.NewEthHash(p, config), nil
+	case "clique":
+		return clique.NewClique(p, db, key)
+	case "dev":
+		return dev.NewDev(p)
+	case "noproof":
+		return &consensus.NoProof{}, nil
+	default:
+		return nil, fmt.Errorf("consensus engine '%s' not supported", engine)
 	}
-	return strings.Split(string(data), "\n")
 }
@@ -0,0 +1,183 @@
+package miner
+
+import (
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/umbracle/minimal/consensus"
+)
+
+// Blockchain is the reference the miner needs to build and write blocks
+type Blockchain interface {
+	Header() *types.Header
+	BuildBlock(header *types.Header, txs []*types.Transaction) (*types.Block, []*types.Transaction, error)
+	WriteBlocks(blocks []*types.Block) error
+}
+
+// Config is the configuration of the miner
+type Config struct {
+	// Coinbase is the address credited with the rewards of the blocks
+	Coinbase common.Address
+
+	// RetryInterval is the time to wait before sealing again after a failure
+	RetryInterval time.Duration
+
+	// Logger is a logger for operator messages.
+	Logger *log.Logger
+}
+
+// DefaultConfig is the default miner config
+func DefaultConfig() *Config {
+	c := &Config{
+		RetryInterval: 5 * time.Second,
+		Logger:        log.New(os.Stderr, "", log.LstdFlags),
+	}
+	return c
+}
+
+// Miner builds blocks on top of the head of the chain with the pending
+// transactions and seals them with the engine. The engine decides when
+// the block is sealed: engines with a period wait for its time and the
+// ones without it return ErrEmptyBlock until there are transactions.
+type Miner struct {
+	config     *Config
+	logger     *log.Logger
+	blockchain Blockchain
+	engine     consensus.Sealer
+
+	lock    sync.Mutex
+	pending []*types.Transaction
+
+	notifyCh chan struct{}
+	closeCh  chan struct{}
+}
+
+// NewMiner creates a new miner
+func NewMiner(config *Config, blockchain Blockchain, engine consensus.Sealer) *Miner {
+	return &Miner{
+		config:     config,
+		logger:     config.Logger,
+		blockchain: blockchain,
+		engine:     engine,
+		pending:    []*types.Transaction{},
+		notifyCh:   make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+	}
+}
+
+// AddTxs adds the transactions to the next block and wakes up the miner
+func (m *Miner) AddTxs(txs []*types.Transaction) {
+	m.lock.Lock()
+	m.pending = append(m.pending, txs...)
+	m.lock.Unlock()
+
+	select {
+	case m.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run is the main entry point, it seals blocks until the miner is closed
+func (m *Miner) Run() {
+	for {
+		block, err := m.sealBlock()
+
+		select {
+		case <-m.closeCh:
+			return
+		default:
+		}
+
+		var wait <-chan time.Time
+		switch {
+		case err == consensus.ErrEmptyBlock:
+			// wait for transactions
+		case err != nil:
+			m.logger.Printf("[ERR] miner: failed to seal the block: %v", err)
+			wait = time.After(m.config.RetryInterval)
+		default:
+			m.logger.Printf("[INFO] miner: sealed block %d (%s) with %d txs", block.NumberU64(), block.Hash().String(), len(block.Transactions()))
+			continue
+		}
+
+		select {
+		case <-m.notifyCh:
+		case <-wait:
+		case <-m.closeCh:
+			return
+		}
+	}
+}
+
+// sealBlock builds the next block with the pending transactions, seals it
+// and writes it in the chain
+func (m *Miner) sealBlock() (*types.Block, error) {
+	parent := m.blockchain.Header()
+
+	m.lock.Lock()
+	txs := m.pending
+	m.lock.Unlock()
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		GasLimit:   parent.GasLimit,
+		Coinbase:   m.config.Coinbase,
+		Extra:      []byte{},
+	}
+	if err := m.engine.Prepare(parent, header); err != nil {
+		return nil, err
+	}
+
+	block, failed, err := m.blockchain.BuildBlock(header, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transactions that cannot be applied are dropped
+	if len(failed) != 0 {
+		m.logger.Printf("[WARN] miner: dropped %d invalid txs", len(failed))
+		m.removePending(failed)
+	}
+
+	sealed, err := m.engine.Seal(block, m.closeCh)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.blockchain.WriteBlocks([]*types.Block{sealed}); err != nil {
+		return nil, err
+	}
+
+	m.removePending(sealed.Transactions())
+	return sealed, nil
+}
+
+// removePending removes the transactions from the pending ones, the
+// transactions that did not fit in the block are kept for the next one
+func (m *Miner) removePending(txs []*types.Transaction) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	remove := map[common.Hash]struct{}{}
+	for _, tx := range txs {
+		remove[tx.Hash()] = struct{}{}
+	}
+
+	pending := []*types.Transaction{}
+	for _, tx := range m.pending {
+		if _, ok := remove[tx.Hash()]; !ok {
+			pending = append(pending, tx)
+		}
+	}
+	m.pending = pending
+}
+
+// Close stops the miner
+func (m *Miner) Close() {
+	close(m.closeCh)
+}
@@ -0,0 +1,149 @@
+package miner
+
+import (
+	"io/ioutil"
+	"log"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/umbracle/minimal/blockchain"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/consensus/dev"
+	"github.com/umbracle/minimal/storage"
+)
+
+func newTestMiner(t *testing.T, period uint64) (*Miner, *blockchain.Blockchain, *chain.Chain) {
+	c, err := chain.ImportFromName("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Params.Engine = map[string]interface{}{
+		"dev": map[string]interface{}{
+			"period": period,
+		},
+	}
+
+	st := state.NewDatabase(ethdb.NewMemDatabase())
+	genesis, err := c.Genesis.Commit(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := dev.NewDev(c.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := blockchain.NewBlockchain(db, st, engine, c.Params)
+	if err := b.WriteGenesis(genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Logger = log.New(ioutil.Discard, "", 0)
+	config.RetryInterval = 100 * time.Millisecond
+
+	return NewMiner(config, b, engine), b, c
+}
+
+func waitForHead(t *testing.T, b *blockchain.Blockchain, number uint64) {
+	timeout := time.After(5 * time.Second)
+	for b.Header().Number.Uint64() < number {
+		select {
+		case <-timeout:
+			t.Fatalf("block %d was not sealed", number)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestMinerSealPendingTxs(t *testing.T) {
+	m, b, c := newTestMiner(t, 0)
+
+	go m.Run()
+	defer m.Close()
+
+	// without transactions nothing is sealed
+	time.Sleep(100 * time.Millisecond)
+	if b.Header().Number.Uint64() != 0 {
+		t.Fatal("empty blocks should not be sealed")
+	}
+
+	// the first account of the dev chain is prefunded
+	key, _ := crypto.ToECDSA(common.LeftPadBytes([]byte{1}, 32))
+	signer := types.NewEIP155Signer(big.NewInt(int64(c.Params.ChainID)))
+
+	tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("1234"), big.NewInt(10), 21000, big.NewInt(1), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddTxs([]*types.Transaction{tx})
+
+	waitForHead(t, b, 1)
+
+	block, err := b.GetBlockByNumber(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions()) != 1 || block.Transactions()[0].Hash() != tx.Hash() {
+		t.Fatal("the block should include the pending transaction")
+	}
+}
+
+func TestMinerSealPeriod(t *testing.T) {
+	m, b, _ := newTestMiner(t, 1)
+
+	go m.Run()
+	defer m.Close()
+
+	// empty blocks are sealed on every period
+	waitForHead(t, b, 2)
+}
+
+func TestMinerDropInvalidTxs(t *testing.T) {
+	m, b, c := newTestMiner(t, 0)
+
+	signer := types.NewEIP155Signer(big.NewInt(int64(c.Params.ChainID)))
+	newTx := func(key byte, nonce uint64) *types.Transaction {
+		k, _ := crypto.ToECDSA(common.LeftPadBytes([]byte{key}, 32))
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.HexToAddress("1234"), big.NewInt(10), 21000, big.NewInt(1), nil), signer, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// the account of the key 0x10 is not funded
+	valid0, invalid, valid1 := newTx(1, 0), newTx(0x10, 0), newTx(1, 1)
+	m.AddTxs([]*types.Transaction{valid0, invalid, valid1})
+
+	go m.Run()
+	defer m.Close()
+
+	waitForHead(t, b, 1)
+
+	block, err := b.GetBlockByNumber(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := block.Transactions()
+	if len(txs) != 2 || txs[0].Hash() != valid0.Hash() || txs[1].Hash() != valid1.Hash() {
+		t.Fatalf("expected the valid transactions in the block but found %d", len(txs))
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.pending) != 0 {
+		t.Fatalf("expected no pending transactions but found %d", len(m.pending))
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/umbracle/minimal/network"
+	"github.com/umbracle/minimal/storage"
 )
 
 const (
@@ -53,12 +54,17 @@ type Downloader interface {
 	Data([][]byte)
 }
 
+// TxPool receives the transactions sent by the peers
+type TxPool interface {
+	AddTxs(txs []*types.Transaction)
+}
+
 // Blockchain is the interface the ethereum protocol needs to work
 type Blockchain interface {
-	GetHeaderByHash(hash common.Hash) *types.Header
-	GetHeaderByNumber(n *big.Int) *types.Header
-	GetReceiptsByHash(hash common.Hash) types.Receipts
-	GetBodyByHash(hash common.Hash) *types.Body
+	GetHeaderByHash(hash common.Hash) (*types.Header, error)
+	GetHeaderByNumber(n *big.Int) (*types.Header, error)
+	GetReceiptsByHash(hash common.Hash) (types.Receipts, error)
+	GetBodyByHash(hash common.Hash) (*types.Body, error)
 }
 
 // Ethereum is the protocol for etheruem
@@ -69,6 +75,7 @@ type Ethereum struct {
 	status     *Status
 	blockchain Blockchain
 	downloader Downloader
+	txPool     TxPool
 
 	// pendin objects
 	pending     map[string]*callback
@@ -96,6 +103,11 @@ func (e *Ethereum) SetDownloader(downloader Downloader) {
 	e.downloader = downloader
 }
 
+// SetTxPool changes the pool that receives the transactions of the peer
+func (e *Ethereum) SetTxPool(txPool TxPool) {
+	e.txPool = txPool
+}
+
 func (e *Ethereum) Header() common.Hash {
 	return e.peer.HeaderHash()
 }
@@ -270,13 +282,15 @@ func (e *Ethereum) HandleMsg(msg network.Message) error {
 
 		var origin *types.Header
 		if query.Origin.IsHash() {
-			origin = e.blockchain.GetHeaderByHash(query.Origin.Hash)
+			origin, err = e.blockchain.GetHeaderByHash(query.Origin.Hash)
 		} else {
-			origin = e.blockchain.GetHeaderByNumber(big.NewInt(int64(query.Origin.Number)))
+			origin, err = e.blockchain.GetHeaderByNumber(big.NewInt(int64(query.Origin.Number)))
 		}
 
-		if origin == nil {
+		if err == storage.ErrNotFound {
 			return e.sendBlockHeaders([]*types.Header{})
+		} else if err != nil {
+			return err
 		}
 
 		headers := []*types.Header{origin}
@@ -296,9 +310,11 @@ func (e *Ethereum) HandleMsg(msg network.Message) error {
 			if block < 0 {
 				break
 			}
-			origin = e.blockchain.GetHeaderByNumber(big.NewInt(block))
-			if origin == nil {
+			origin, err = e.blockchain.GetHeaderByNumber(big.NewInt(block))
+			if err == storage.ErrNotFound {
 				break
+			} else if err != nil {
+				return err
 			}
 
 			headers = append(headers, origin)
@@ -328,16 +344,20 @@ func (e *Ethereum) HandleMsg(msg network.Message) error {
 		for i := 0; i < len(hashes) && bytes < softResponseLimit && len(bodies) < downloader.MaxBlockFetch; i++ {
 			hash := hashes[i]
 
-			body := e.blockchain.GetBodyByHash(hash)
-			if body != nil {
-				data, err := rlp.EncodeToBytes(body)
-				if err != nil {
-					return err
-				}
+			body, err := e.blockchain.GetBodyByHash(hash)
+			if err == storage.ErrNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
 
-				bodies = append(bodies, data)
-				bytes += len(data)
+			data, err := rlp.EncodeToBytes(body)
+			if err != nil {
+				return err
 			}
+
+			bodies = append(bodies, data)
+			bytes += len(data)
 		}
 		return e.sendBlockBodies(bodies)
 
@@ -373,12 +393,19 @@ func (e *Ethereum) HandleMsg(msg network.Message) error {
 		for i := 0; i < len(hashes) && bytes < softResponseLimit && len(receipts) < downloader.MaxReceiptFetch; i++ {
 			hash := hashes[i]
 
-			res := e.blockchain.GetReceiptsByHash(hash)
-			if res == nil {
-				header := e.blockchain.GetHeaderByHash(hash)
-				if header == nil || header.ReceiptHash != types.EmptyRootHash {
+			res, err := e.blockchain.GetReceiptsByHash(hash)
+			if err == storage.ErrNotFound {
+				header, err := e.blockchain.GetHeaderByHash(hash)
+				if err == storage.ErrNotFound {
+					continue
+				} else if err != nil {
+					return err
+				}
+				if header.ReceiptHash != types.EmptyRootHash {
 					continue
 				}
+			} else if err != nil {
+				return err
 			}
 
 			data, err := rlp.EncodeToBytes(res)
@@ -415,7 +442,13 @@ func (e *Ethereum) HandleMsg(msg network.Message) error {
 		// TODO: notify the syncer about the new block (syncer interface as in blockchain?)
 
 	case code == TxMsg:
-		// TODO: deliver
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return err
+		}
+		if e.txPool != nil {
+			e.txPool.AddTxs(txs)
+		}
 
 	default:
 		return fmt.Errorf("Message code %d not found", code)
@@ -439,3 +439,38 @@ func TestPeerCloseConnection(t *testing.T) {
 		t.Fatal("it should fail after the connection has been closed")
 	}
 }
+
+type testTxPool struct {
+	txsCh chan []*types.Transaction
+}
+
+func (t *testTxPool) AddTxs(txs []*types.Transaction) {
+	t.txsCh <- txs
+}
+
+func TestEthereumTxMsg(t *testing.T) {
+	headers := blockchain.NewTestHeaderChain(5)
+
+	b0 := blockchain.NewTestBlockchain(t, headers)
+	b1 := blockchain.NewTestBlockchain(t, headers)
+
+	s0, s1 := network.TestServers()
+	p0, p1 := testEthHandshake(t, s0, &status, b0, s1, &status, b1)
+
+	pool := &testTxPool{txsCh: make(chan []*types.Transaction, 1)}
+	p1.SetTxPool(pool)
+
+	tx := types.NewTransaction(0, common.HexToAddress("1"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	if err := p0.conn.WriteMsg(TxMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case txs := <-pool.txsCh:
+		if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Fatal("bad transactions")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the transactions were not delivered")
+	}
+}
@@ -0,0 +1,202 @@
+package state
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/umbracle/minimal/chain"
+	"github.com/umbracle/minimal/evm"
+)
+
+// Based on geth state_processor.go and block_validator.go
+
+// Processor executes the transactions of a block on top of the state of its parent
+type Processor struct {
+	config *chain.Params
+}
+
+// NewProcessor creates a new block processor
+func NewProcessor(config *chain.Params) *Processor {
+	return &Processor{config}
+}
+
+// Process applies all the transactions of the block to the state. The rewards
+// are credited afterwards by the consensus engine.
+// It returns the receipts and the amount of gas used in the block.
+func (p *Processor) Process(statedb *state.StateDB, block *types.Block, getHash evm.GetHashByNumber) (types.Receipts, uint64, error) {
+	e := p.newExecutor(statedb, block.Header(), block.Hash(), getHash)
+
+	for indx, tx := range block.Transactions() {
+		if err := e.apply(tx); err != nil {
+			return nil, 0, fmt.Errorf("failed to apply tx %d: %v", indx, err)
+		}
+	}
+	return e.receipts, e.usedGas, nil
+}
+
+// Build applies the transactions to the state to fill a new block with the header.
+// The transactions that fail are skipped and returned apart, the ones that do not
+// fit in the gas left in the block are neither included nor failed.
+// It returns the included transactions with their receipts and the gas used.
+func (p *Processor) Build(statedb *state.StateDB, header *types.Header, txs []*types.Transaction, getHash evm.GetHashByNumber) (types.Transactions, types.Receipts, uint64, []*types.Transaction) {
+	e := p.newExecutor(statedb, header, common.Hash{}, getHash)
+
+	included := types.Transactions{}
+	failed := []*types.Transaction{}
+
+	for _, tx := range txs {
+		if tx.Gas() > e.gaspool.Gas() {
+			if tx.Gas() > header.GasLimit {
+				// it would not fit in any block
+				failed = append(failed, tx)
+				continue
+			}
+			// the block is full
+			break
+		}
+		if err := e.apply(tx); err != nil {
+			failed = append(failed, tx)
+			continue
+		}
+		included = append(included, tx)
+	}
+	return included, e.receipts, e.usedGas, failed
+}
+
+// executor applies the transactions of a block one by one
+type executor struct {
+	statedb   *state.StateDB
+	config    chain.ForksInTime
+	env       *evm.Env
+	signer    types.Signer
+	gaspool   *core.GasPool
+	getHash   evm.GetHashByNumber
+	blockHash common.Hash
+
+	receipts types.Receipts
+	usedGas  uint64
+}
+
+func (p *Processor) newExecutor(statedb *state.StateDB, header *types.Header, blockHash common.Hash, getHash evm.GetHashByNumber) *executor {
+	number := header.Number.Uint64()
+
+	if p.config.Forks.IsDAO(number) {
+		misc.ApplyDAOHardFork(statedb)
+	}
+
+	env := &evm.Env{
+		Coinbase:   header.Coinbase,
+		Timestamp:  header.Time,
+		Number:     header.Number,
+		Difficulty: header.Difficulty,
+		GasLimit:   new(big.Int).SetUint64(header.GasLimit),
+		ChainID:    big.NewInt(int64(p.config.ChainID)),
+	}
+
+	gaspool := new(core.GasPool)
+	gaspool.AddGas(header.GasLimit)
+
+	return &executor{
+		statedb:   statedb,
+		config:    p.config.Forks.At(number),
+		env:       env,
+		signer:    p.signer(number),
+		gaspool:   gaspool,
+		getHash:   getHash,
+		blockHash: blockHash,
+		receipts:  types.Receipts{},
+	}
+}
+
+// apply applies the transaction and adds its receipt. If the
+// transaction fails the state and the gas pool are reverted.
+func (e *executor) apply(tx *types.Transaction) error {
+	msg, err := tx.AsMessage(e.signer)
+	if err != nil {
+		return fmt.Errorf("failed to get the sender: %v", err)
+	}
+	e.env.GasPrice = msg.GasPrice()
+
+	snapshot := e.statedb.Snapshot()
+	gas := e.gaspool.Gas()
+
+	e.statedb.Prepare(tx.Hash(), e.blockHash, len(e.receipts))
+
+	t := &Transition{
+		State:   e.statedb,
+		Env:     e.env,
+		Config:  e.config,
+		Msg:     &msg,
+		Gp:      e.gaspool,
+		GetHash: e.getHash,
+	}
+	if err := t.Apply(); err != nil {
+		e.statedb.RevertToSnapshot(snapshot)
+		*e.gaspool = core.GasPool(gas)
+		return err
+	}
+
+	e.usedGas += t.GasUsed()
+
+	// the intermediate root is only part of the receipt before byzantium
+	var root []byte
+	if e.config.Byzantium {
+		e.statedb.Finalise(true)
+	} else {
+		root = e.statedb.IntermediateRoot(e.config.EIP158).Bytes()
+	}
+
+	receipt := types.NewReceipt(root, t.Failed(), e.usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = t.GasUsed()
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From(), tx.Nonce())
+	}
+	receipt.Logs = e.statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	e.receipts = append(e.receipts, receipt)
+	return nil
+}
+
+// Validate checks the result of processing the block against its header
+func (p *Processor) Validate(statedb *state.StateDB, block *types.Block, receipts types.Receipts, usedGas uint64) error {
+	header := block.Header()
+
+	if header.GasUsed != usedGas {
+		return fmt.Errorf("gas used not correct: expected %d but found %d", header.GasUsed, usedGas)
+	}
+	if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+		return fmt.Errorf("bloom not correct: expected %x but found %x", header.Bloom, bloom)
+	}
+	if root := types.DeriveSha(receipts); root != header.ReceiptHash {
+		return fmt.Errorf("receipts root not correct: expected %s but found %s", header.ReceiptHash.String(), root.String())
+	}
+	if root := statedb.IntermediateRoot(p.config.Forks.IsEIP158(header.Number.Uint64())); root != header.Root {
+		return fmt.Errorf("state root not correct: expected %s but found %s", header.Root.String(), root.String())
+	}
+	return nil
+}
+
+// DeleteEmptyObjects returns true if the empty accounts are removed from the state at the block number
+func (p *Processor) DeleteEmptyObjects(number *big.Int) bool {
+	return p.config.Forks.IsEIP158(number.Uint64())
+}
+
+// signer returns the transaction signer for the block number
+func (p *Processor) signer(number uint64) types.Signer {
+	switch {
+	case p.config.Forks.IsEIP155(number):
+		return types.NewEIP155Signer(big.NewInt(int64(p.config.ChainID)))
+	case p.config.Forks.IsHomestead(number):
+		return types.HomesteadSigner{}
+	default:
+		return types.FrontierSigner{}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/evm"
 )
 
@@ -18,8 +19,12 @@ var (
 	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for gas")
 )
 
+// TxDataNonZeroGasEIP2028 is the cost of the non zero bytes of the data
+// of a transaction after istanbul
+const TxDataNonZeroGasEIP2028 uint64 = 16
+
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+func IntrinsicGas(data []byte, contractCreation, homestead, istanbul bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && homestead {
@@ -36,11 +41,15 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 				nz++
 			}
 		}
+		nonZeroGas := params.TxDataNonZeroGas
+		if istanbul {
+			nonZeroGas = TxDataNonZeroGasEIP2028
+		}
 		// Make sure we don't exceed uint64 for all data combinations
-		if (math.MaxUint64-gas)/params.TxDataNonZeroGas < nz {
+		if (math.MaxUint64-gas)/nonZeroGas < nz {
 			return 0, vm.ErrOutOfGas
 		}
-		gas += nz * params.TxDataNonZeroGas
+		gas += nz * nonZeroGas
 
 		z := uint64(len(data)) - nz
 		if (math.MaxUint64-gas)/params.TxDataZeroGas < z {
@@ -55,12 +64,17 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 type Transition struct {
 	State      *state.StateDB
 	Env        *evm.Env
-	Config     *params.ChainConfig
+	Config     chain.ForksInTime
 	Gas        uint64
 	initialGas uint64
 	Msg        *types.Message
 	Gp         *core.GasPool
 	GetHash    evm.GetHashByNumber
+
+	// Tracer traces the execution of the message if set
+	Tracer evm.Tracer
+
+	failed bool
 }
 
 func (t *Transition) useGas(amount uint64) error {
@@ -105,12 +119,11 @@ func (t *Transition) Apply() error {
 		return err
 	}
 
-	homestead := t.Config.IsHomestead(t.Env.Number)
 	contractCreation := t.Msg.To() == nil
 
 	sender := t.Msg.From()
 
-	gas, err := IntrinsicGas(t.Msg.Data(), contractCreation, homestead)
+	gas, err := IntrinsicGas(t.Msg.Data(), contractCreation, t.Config.Homestead, t.Config.Istanbul)
 	if err != nil {
 		return err
 	}
@@ -119,7 +132,8 @@ func (t *Transition) Apply() error {
 		return err
 	}
 
-	e := evm.NewEVM(t.State, t.Env, t.Config, t.Config.GasTable(t.Env.Number), t.GetHash)
+	e := evm.NewEVM(t.State, t.Env, t.Config, *t.Config.GasTable(), t.GetHash)
+	e.Tracer = t.Tracer
 
 	var vmerr error
 	if contractCreation {
@@ -133,6 +147,7 @@ func (t *Transition) Apply() error {
 		if vmerr == evm.ErrNotEnoughFunds {
 			return vmerr
 		}
+		t.failed = true
 	}
 
 	t.refundGas()
@@ -164,3 +179,13 @@ func (t *Transition) refundGas() {
 func (t *Transition) gasUsed() uint64 {
 	return t.initialGas - t.Gas
 }
+
+// GasUsed returns the amount of gas used by the applied message
+func (t *Transition) GasUsed() uint64 {
+	return t.gasUsed()
+}
+
+// Failed returns true if the execution of the applied message failed
+func (t *Transition) Failed() bool {
+	return t.failed
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"math/big"
 	"os"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 
@@ -14,10 +15,42 @@ import (
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
-// prefix
-
+// ErrNotFound is returned when the key is not found in the storage
 var ErrNotFound = fmt.Errorf("not found")
 
+// IOError is returned when the underlying database fails to read or write a key
+type IOError struct {
+	Key []byte
+	Err error
+}
+
+func (e *IOError) Error() string {
+	return fmt.Sprintf("failed to access %s: %v", hexutil.Encode(e.Key), e.Err)
+}
+
+// EncodeError is returned when an object cannot be encoded before it is written
+type EncodeError struct {
+	Key []byte
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("failed to encode %s: %v", hexutil.Encode(e.Key), e.Err)
+}
+
+// DecodeError is returned when a stored object cannot be decoded, which
+// usually means the data is corrupted
+type DecodeError struct {
+	Key []byte
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode %s: %v", hexutil.Encode(e.Key), e.Err)
+}
+
+// prefix
+
 var (
 	// DIFFICULTY is the difficulty prefix
 	DIFFICULTY = []byte("d")
@@ -39,6 +72,12 @@ var (
 
 	// RECEIPTS is the prefix for receipts
 	RECEIPTS = []byte("r")
+
+	// TXLOOKUP is the prefix for the transaction lookups
+	TXLOOKUP = []byte("l")
+
+	// SNAPSHOTS is the prefix for the consensus snapshots
+	SNAPSHOTS = []byte("s")
 )
 
 // sub-prefix
@@ -53,6 +92,15 @@ var (
 type KV interface {
 	set(p []byte, v []byte) error
 	get(p []byte) ([]byte, error)
+	del(p []byte) error
+	batch() kvBatch
+}
+
+// kvBatch is a set of writes that are applied atomically to the kv storage
+type kvBatch interface {
+	set(p []byte, v []byte)
+	del(p []byte)
+	write() error
 }
 
 // levelDBKV is the leveldb implementation of the kv storage
@@ -74,17 +122,50 @@ func (l *levelDBKV) get(p []byte) ([]byte, error) {
 	return data, err
 }
 
+func (l *levelDBKV) del(p []byte) error {
+	return l.db.Delete(p, nil)
+}
+
+func (l *levelDBKV) batch() kvBatch {
+	return &levelDBBatch{db: l.db, batch: new(leveldb.Batch)}
+}
+
+// levelDBBatch is the leveldb implementation of the kv batch
+type levelDBBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelDBBatch) set(p []byte, v []byte) {
+	b.batch.Put(p, v)
+}
+
+func (b *levelDBBatch) del(p []byte) {
+	b.batch.Delete(p)
+}
+
+func (b *levelDBBatch) write() error {
+	return b.db.Write(b.batch, nil)
+}
+
 // memoryKV is an in memory implementation of the kv storage
 type memoryKV struct {
-	db map[string][]byte
+	lock sync.RWMutex
+	db   map[string][]byte
 }
 
 func (m *memoryKV) set(p []byte, v []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	m.db[hexutil.Encode(p)] = v
 	return nil
 }
 
 func (m *memoryKV) get(p []byte) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	v, ok := m.db[hexutil.Encode(p)]
 	if !ok {
 		return []byte{}, ErrNotFound
@@ -92,6 +173,104 @@ func (m *memoryKV) get(p []byte) ([]byte, error) {
 	return v, nil
 }
 
+func (m *memoryKV) del(p []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.db, hexutil.Encode(p))
+	return nil
+}
+
+func (m *memoryKV) batch() kvBatch {
+	return newStagedBatch(m)
+}
+
+// stagedBatch keeps the writes in memory and applies them to the kv storage
+// on write. It is only atomic for kv implementations whose set cannot fail.
+type stagedBatch struct {
+	kv      KV
+	keys    []string
+	staged  map[string][]byte
+	deleted map[string]bool
+}
+
+func newStagedBatch(kv KV) *stagedBatch {
+	return &stagedBatch{kv: kv, keys: []string{}, staged: map[string][]byte{}, deleted: map[string]bool{}}
+}
+
+func (b *stagedBatch) track(k string) {
+	_, staged := b.staged[k]
+	if !staged && !b.deleted[k] {
+		b.keys = append(b.keys, k)
+	}
+}
+
+func (b *stagedBatch) set(p []byte, v []byte) {
+	k := string(p)
+	b.track(k)
+	delete(b.deleted, k)
+	b.staged[k] = v
+}
+
+func (b *stagedBatch) del(p []byte) {
+	k := string(p)
+	b.track(k)
+	delete(b.staged, k)
+	b.deleted[k] = true
+}
+
+func (b *stagedBatch) write() error {
+	for _, k := range b.keys {
+		if b.deleted[k] {
+			if err := b.kv.del([]byte(k)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := b.kv.set([]byte(k), b.staged[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchKV stages the writes in a kv batch and serves the reads from the
+// staged values before falling back to the parent kv storage
+type batchKV struct {
+	parent  KV
+	b       kvBatch
+	staged  map[string][]byte
+	deleted map[string]bool
+}
+
+func (b *batchKV) set(p []byte, v []byte) error {
+	delete(b.deleted, string(p))
+	b.staged[string(p)] = v
+	b.b.set(p, v)
+	return nil
+}
+
+func (b *batchKV) get(p []byte) ([]byte, error) {
+	if b.deleted[string(p)] {
+		return nil, ErrNotFound
+	}
+	if v, ok := b.staged[string(p)]; ok {
+		return v, nil
+	}
+	return b.parent.get(p)
+}
+
+func (b *batchKV) del(p []byte) error {
+	delete(b.staged, string(p))
+	b.deleted[string(p)] = true
+	b.b.del(p)
+	return nil
+}
+
+func (b *batchKV) batch() kvBatch {
+	return newStagedBatch(b)
+}
+
 // Storage is the blockchain storage using boltdb
 type Storage struct {
 	logger *log.Logger
@@ -115,10 +294,29 @@ func NewLevelDBStorage(path string, logger *log.Logger) (*Storage, error) {
 
 // NewMemoryStorage creates the new storage reference with inmemory
 func NewMemoryStorage(logger *log.Logger) (*Storage, error) {
-	db := &memoryKV{map[string][]byte{}}
+	db := &memoryKV{db: map[string][]byte{}}
 	return &Storage{logger, db}, nil
 }
 
+// Batch groups writes to the storage so that they are committed atomically.
+// Reads done through the batch see the values staged in it. A batch that is
+// not committed is discarded and leaves the storage untouched.
+type Batch struct {
+	*Storage
+	kv *batchKV
+}
+
+// NewBatch creates a new batch on top of the storage
+func (s *Storage) NewBatch() *Batch {
+	kv := &batchKV{parent: s.db, b: s.db.batch(), staged: map[string][]byte{}, deleted: map[string]bool{}}
+	return &Batch{&Storage{s.logger, kv}, kv}
+}
+
+// Commit writes all the values staged in the batch
+func (b *Batch) Commit() error {
+	return b.kv.b.write()
+}
+
 // Close closes the storage connection
 func (s *Storage) Close() error {
 	return s.Close()
@@ -127,242 +325,257 @@ func (s *Storage) Close() error {
 // -- canonical hash --
 
 // ReadCanonicalHash gets the hash from the number of the canonical chain
-func (s *Storage) ReadCanonicalHash(n *big.Int) common.Hash {
-	data := s.get(CANONICAL, n.Bytes())
-	if len(data) == 0 {
-		return common.Hash{}
+func (s *Storage) ReadCanonicalHash(n *big.Int) (common.Hash, error) {
+	data, err := s.get(CANONICAL, n.Bytes())
+	if err != nil {
+		return common.Hash{}, err
 	}
-	return common.BytesToHash(data)
+	return common.BytesToHash(data), nil
 }
 
 // WriteCanonicalHash writes a hash for a number block in the canonical chain
-func (s *Storage) WriteCanonicalHash(n *big.Int, hash common.Hash) {
-	s.set(CANONICAL, n.Bytes(), hash.Bytes())
+func (s *Storage) WriteCanonicalHash(n *big.Int, hash common.Hash) error {
+	return s.set(CANONICAL, n.Bytes(), hash.Bytes())
+}
+
+// DeleteCanonicalHash removes the hash of a number from the canonical chain
+func (s *Storage) DeleteCanonicalHash(n *big.Int) error {
+	return s.del(CANONICAL, n.Bytes())
 }
 
 // -- head --
 
 // ReadHeadHash returns the hash of the head
-func (s *Storage) ReadHeadHash() *common.Hash {
-	data := s.get(HEAD, HASH)
-	if len(data) == 0 {
-		return nil
+func (s *Storage) ReadHeadHash() (common.Hash, error) {
+	data, err := s.get(HEAD, HASH)
+	if err != nil {
+		return common.Hash{}, err
 	}
-	hash := common.BytesToHash(data)
-	return &hash
+	return common.BytesToHash(data), nil
 }
 
 // ReadHeadNumber returns the number of the head
-func (s *Storage) ReadHeadNumber() *big.Int {
-	data := s.get(HEAD, HASH)
-	if len(data) == 0 {
-		return nil
+func (s *Storage) ReadHeadNumber() (*big.Int, error) {
+	data, err := s.get(HEAD, NUMBER)
+	if err != nil {
+		return nil, err
 	}
-	return big.NewInt(1).SetBytes(data)
+	return big.NewInt(0).SetBytes(data), nil
 }
 
 // WriteHeadHash writes the hash of the head
-func (s *Storage) WriteHeadHash(h common.Hash) {
-	s.set(HEAD, HASH, h.Bytes())
+func (s *Storage) WriteHeadHash(h common.Hash) error {
+	return s.set(HEAD, HASH, h.Bytes())
 }
 
 // WriteHeadNumber writes the number of the head
-func (s *Storage) WriteHeadNumber(n *big.Int) {
-	s.set(HEAD, NUMBER, n.Bytes())
+func (s *Storage) WriteHeadNumber(n *big.Int) error {
+	return s.set(HEAD, NUMBER, n.Bytes())
 }
 
 // -- fork --
 
 // WriteForks writes the current forks
-func (s *Storage) WriteForks(forks []common.Hash) {
-	s.write(FORK, EMPTY, forks)
-
-	/*
-		data, err := rlp.EncodeToBytes(forks)
-		if err != nil {
-			return err
-		}
-		return s.set(FORK, EMPTY, data)
-	*/
+func (s *Storage) WriteForks(forks []common.Hash) error {
+	return s.write(FORK, EMPTY, forks)
 }
 
-// ReadForks read the current forks
-func (s *Storage) ReadForks() []common.Hash {
+// ReadForks read the current forks. It returns an empty list if no forks
+// have been written yet.
+func (s *Storage) ReadForks() ([]common.Hash, error) {
 	var forks []common.Hash
-	s.read(FORK, EMPTY, &forks)
-	return forks
-
-	/*
-		data, err := s.get(FORK, EMPTY)
-		if err != nil {
-			return nil, err
-		}
-		var forks []common.Hash
-		err = rlp.DecodeBytes(data, &forks)
-		return forks, err
-	*/
+	if err := s.read(FORK, EMPTY, &forks); err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	return forks, nil
 }
 
 // -- difficulty --
 
 // WriteDiff writes the difficulty
-func (s *Storage) WriteDiff(hash common.Hash, diff *big.Int) {
-	s.set(DIFFICULTY, hash.Bytes(), diff.Bytes())
+func (s *Storage) WriteDiff(hash common.Hash, diff *big.Int) error {
+	return s.set(DIFFICULTY, hash.Bytes(), diff.Bytes())
+}
+
+// DeleteDiff removes the difficulty
+func (s *Storage) DeleteDiff(hash common.Hash) error {
+	return s.del(DIFFICULTY, hash.Bytes())
 }
 
 // ReadDiff reads the difficulty
-func (s *Storage) ReadDiff(hash common.Hash) *big.Int {
-	v := s.get(DIFFICULTY, hash.Bytes())
-	if len(v) == 0 {
-		return nil
+func (s *Storage) ReadDiff(hash common.Hash) (*big.Int, error) {
+	v, err := s.get(DIFFICULTY, hash.Bytes())
+	if err != nil {
+		return nil, err
 	}
-	return big.NewInt(0).SetBytes(v)
+	return big.NewInt(0).SetBytes(v), nil
 }
 
 // -- header --
 
 // WriteHeader writes the header
-func (s *Storage) WriteHeader(h *types.Header) {
-	s.write(HEADER, h.Hash().Bytes(), h)
+func (s *Storage) WriteHeader(h *types.Header) error {
+	return s.write(HEADER, h.Hash().Bytes(), h)
+}
 
-	/*
-		data, err := rlp.EncodeToBytes(h)
-		if err != nil {
-			return err
-		}
-		return s.set(HEADER, h.Hash().Bytes(), data)
-	*/
+// DeleteHeader removes the header
+func (s *Storage) DeleteHeader(hash common.Hash) error {
+	return s.del(HEADER, hash.Bytes())
 }
 
 // ReadHeader reads the header
-func (s *Storage) ReadHeader(hash common.Hash) *types.Header {
+func (s *Storage) ReadHeader(hash common.Hash) (*types.Header, error) {
 	var header *types.Header
-	s.read(HEADER, hash.Bytes(), &header)
-	return header
-
-	/*
-		data, err := s.get(HEADER, hash.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		var header *types.Header
-		err = rlp.DecodeBytes(data, &header)
-		return header, err
-	*/
+	if err := s.read(HEADER, hash.Bytes(), &header); err != nil {
+		return nil, err
+	}
+	return header, nil
 }
 
 // -- body --
 
 // WriteBody writes the body
-func (s *Storage) WriteBody(hash common.Hash, body *types.Body) {
-	s.write(BODY, hash.Bytes(), body)
+func (s *Storage) WriteBody(hash common.Hash, body *types.Body) error {
+	return s.write(BODY, hash.Bytes(), body)
+}
 
-	/*
-		data, err := rlp.EncodeToBytes(body)
-		if err != nil {
-			return err
-		}
-		return s.set(BODY, hash.Bytes(), data)
-	*/
+// DeleteBody removes the body
+func (s *Storage) DeleteBody(hash common.Hash) error {
+	return s.del(BODY, hash.Bytes())
 }
 
 // ReadBody reads the body
-func (s *Storage) ReadBody(hash common.Hash) *types.Body {
+func (s *Storage) ReadBody(hash common.Hash) (*types.Body, error) {
 	var body *types.Body
-	s.read(BODY, hash.Bytes(), &body)
-	return body
-
-	/*
-		data, err := s.get(BODY, hash.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		var body *types.Body
-		err = rlp.DecodeBytes(data, &body)
-		return body, err
-	*/
+	if err := s.read(BODY, hash.Bytes(), &body); err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 // -- receipts --
 
 // WriteReceipts writes the receipts
-func (s *Storage) WriteReceipts(hash common.Hash, receipts []*types.Receipt) {
+func (s *Storage) WriteReceipts(hash common.Hash, receipts []*types.Receipt) error {
 	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
 	for i, receipt := range receipts {
 		storageReceipts[i] = (*types.ReceiptForStorage)(receipt)
 	}
+	return s.write(RECEIPTS, hash.Bytes(), storageReceipts)
+}
 
-	s.write(RECEIPTS, hash.Bytes(), storageReceipts)
-
-	/*
-		data, err := rlp.EncodeToBytes(storageReceipts)
-		if err != nil {
-			return err
-		}
-
-		return s.set(RECEIPTS, hash.Bytes(), data)
-	*/
+// DeleteReceipts removes the receipts
+func (s *Storage) DeleteReceipts(hash common.Hash) error {
+	return s.del(RECEIPTS, hash.Bytes())
 }
 
 // ReadReceipts reads the receipts
-func (s *Storage) ReadReceipts(hash common.Hash) []*types.Receipt {
+func (s *Storage) ReadReceipts(hash common.Hash) ([]*types.Receipt, error) {
 	var storage []*types.ReceiptForStorage
-	s.read(RECEIPTS, hash.Bytes(), &storage)
-
-	/*
-		data, err := s.get(RECEIPTS, hash.Bytes())
-		if err != nil {
-			return nil, err
-		}
-
-		var storage []*types.ReceiptForStorage
-		if err = rlp.DecodeBytes(data, &storage); err != nil {
-			return nil, err
-		}
-	*/
+	if err := s.read(RECEIPTS, hash.Bytes(), &storage); err != nil {
+		return nil, err
+	}
 
 	receipts := make([]*types.Receipt, len(storage))
 	for i, receipt := range storage {
 		receipts[i] = (*types.Receipt)(receipt)
 	}
+	return receipts, nil
+}
+
+// -- tx lookup --
+
+// TxLookupEntry is the position of a transaction in the canonical chain
+type TxLookupEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+}
+
+// WriteTxLookup writes the position of a transaction
+func (s *Storage) WriteTxLookup(hash common.Hash, entry *TxLookupEntry) error {
+	return s.write(TXLOOKUP, hash.Bytes(), entry)
+}
+
+// ReadTxLookup reads the position of a transaction
+func (s *Storage) ReadTxLookup(hash common.Hash) (*TxLookupEntry, error) {
+	var entry *TxLookupEntry
+	if err := s.read(TXLOOKUP, hash.Bytes(), &entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
 
-	return receipts
+// DeleteTxLookup removes the position of a transaction
+func (s *Storage) DeleteTxLookup(hash common.Hash) error {
+	return s.del(TXLOOKUP, hash.Bytes())
+}
+
+// -- snapshots --
+
+// WriteSnapshot writes the encoded consensus snapshot of a block
+func (s *Storage) WriteSnapshot(hash common.Hash, data []byte) error {
+	return s.set(SNAPSHOTS, hash.Bytes(), data)
+}
+
+// ReadSnapshot reads the encoded consensus snapshot of a block
+func (s *Storage) ReadSnapshot(hash common.Hash) ([]byte, error) {
+	return s.get(SNAPSHOTS, hash.Bytes())
 }
 
 // -- write ops --
 
-func (s *Storage) write(p []byte, k []byte, obj interface{}) {
+func (s *Storage) write(p []byte, k []byte, obj interface{}) error {
 	data, err := rlp.EncodeToBytes(obj)
 	if err != nil {
-		s.logger.Printf("failed to encode rlp: %v", err)
-	} else {
-		s.set(p, k, data)
+		return &EncodeError{Key: key(p, k), Err: err}
 	}
+	return s.set(p, k, data)
 }
 
-func (s *Storage) read(p []byte, k []byte, obj interface{}) {
-	data := s.get(p, k)
-	if len(data) != 0 {
-		if err := rlp.DecodeBytes(data, obj); err != nil {
-			s.logger.Printf("failed to decode rlp: %v", err)
-		}
+func (s *Storage) read(p []byte, k []byte, obj interface{}) error {
+	data, err := s.get(p, k)
+	if err != nil {
+		return err
+	}
+	if err := rlp.DecodeBytes(data, obj); err != nil {
+		return &DecodeError{Key: key(p, k), Err: err}
 	}
+	return nil
 }
 
-func (s *Storage) set(p []byte, k []byte, v []byte) {
-	p = append(p, k...)
+func (s *Storage) set(p []byte, k []byte, v []byte) error {
+	p = key(p, k)
 	if err := s.db.set(p, v); err != nil {
-		s.logger.Printf("failed to write: %v", err)
+		return &IOError{Key: p, Err: err}
 	}
+	return nil
 }
 
-func (s *Storage) get(p []byte, k []byte) []byte {
-	p = append(p, k...)
+func (s *Storage) del(p []byte, k []byte) error {
+	p = key(p, k)
+	if err := s.db.del(p); err != nil {
+		return &IOError{Key: p, Err: err}
+	}
+	return nil
+}
+
+func (s *Storage) get(p []byte, k []byte) ([]byte, error) {
+	p = key(p, k)
 	data, err := s.db.get(p)
 	if err != nil {
-		if err != ErrNotFound {
-			s.logger.Printf("failed to read: %v", err)
+		if err == ErrNotFound {
+			return nil, ErrNotFound
 		}
+		return nil, &IOError{Key: p, Err: err}
 	}
-	return data
+	return data, nil
+}
+
+// key joins the prefix and the key in a new slice so that the
+// prefix backing array is never shared between calls
+func key(p []byte, k []byte) []byte {
+	res := make([]byte, 0, len(p)+len(k))
+	res = append(res, p...)
+	return append(res, k...)
 }
@@ -51,8 +51,13 @@ func TestCanonicalChain(t *testing.T) {
 	}
 
 	for _, cc := range cases {
-		s.WriteCanonicalHash(cc.Number, cc.Hash)
-		data := s.ReadCanonicalHash(cc.Number)
+		if err := s.WriteCanonicalHash(cc.Number, cc.Hash); err != nil {
+			t.Fatal(err)
+		}
+		data, err := s.ReadCanonicalHash(cc.Number)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if !reflect.DeepEqual(data, cc.Hash) {
 			t.Fatal("not match")
@@ -83,8 +88,13 @@ func TestDifficulty(t *testing.T) {
 	}
 
 	for _, cc := range cases {
-		s.WriteDiff(cc.Hash, cc.Diff)
-		diff := s.ReadDiff(cc.Hash)
+		if err := s.WriteDiff(cc.Hash, cc.Diff); err != nil {
+			t.Fatal(err)
+		}
+		diff, err := s.ReadDiff(cc.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if !reflect.DeepEqual(cc.Diff, diff) {
 			t.Fatal("bad")
@@ -105,10 +115,15 @@ func TestHead(t *testing.T) {
 	}
 
 	for _, cc := range cases {
-		s.WriteHeadHash(cc.Hash)
-		hash := s.ReadHeadHash()
+		if err := s.WriteHeadHash(cc.Hash); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := s.ReadHeadHash()
+		if err != nil {
+			t.Fatal(err)
+		}
 
-		if !reflect.DeepEqual(cc.Hash, *hash) {
+		if !reflect.DeepEqual(cc.Hash, hash) {
 			t.Fatal("bad")
 		}
 	}
@@ -126,8 +141,13 @@ func TestForks(t *testing.T) {
 	}
 
 	for _, cc := range cases {
-		s.WriteForks(cc.Forks)
-		forks := s.ReadForks()
+		if err := s.WriteForks(cc.Forks); err != nil {
+			t.Fatal(err)
+		}
+		forks, err := s.ReadForks()
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if !reflect.DeepEqual(cc.Forks, forks) {
 			t.Fatal("bad")
@@ -147,8 +167,13 @@ func TestHeader(t *testing.T) {
 		Extra:      []byte{}, // if not set it will fail
 	}
 
-	s.WriteHeader(header)
-	header1 := s.ReadHeader(header.Hash())
+	if err := s.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	header1, err := s.ReadHeader(header.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !reflect.DeepEqual(header.Hash(), header1.Hash()) {
 		t.Fatal("bad")
@@ -173,8 +198,13 @@ func TestBody(t *testing.T) {
 	block := types.NewBlock(header, []*types.Transaction{t0, t1}, nil, nil)
 	hash := block.Hash()
 
-	s.WriteBody(hash, block.Body())
-	body := s.ReadBody(hash)
+	if err := s.WriteBody(hash, block.Body()); err != nil {
+		t.Fatal(err)
+	}
+	body, err := s.ReadBody(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// NOTE: reflect.DeepEqual does not seem to work, check the hash of the transactions
 	tx0, tx1 := block.Body().Transactions, body.Transactions
@@ -201,8 +231,13 @@ func TestReceipts(t *testing.T) {
 	receipts := []*types.Receipt{r0, r1}
 	hash := common.HexToHash("11")
 
-	s.WriteReceipts(hash, receipts)
-	r := s.ReadReceipts(hash)
+	if err := s.WriteReceipts(hash, receipts); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.ReadReceipts(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// NOTE: reflect.DeepEqual does not seem to work, check the hash of the receipt
 	if len(r) != len(receipts) {
@@ -219,13 +254,143 @@ func TestEmptyData(t *testing.T) {
 	s, close := newStorage(t)
 	defer close()
 
-	if s.ReadBody(common.HexToHash("1")) != nil {
+	if _, err := s.ReadBody(common.HexToHash("1")); err != ErrNotFound {
 		t.Fatal("body should be empty")
 	}
-	if s.ReadHeadHash() != nil {
-		t.Fatal("head hash should be nil")
+	if _, err := s.ReadHeadHash(); err != ErrNotFound {
+		t.Fatal("head hash should be empty")
+	}
+	if _, err := s.ReadHeadNumber(); err != ErrNotFound {
+		t.Fatal("head number should be empty")
+	}
+	if forks, err := s.ReadForks(); err != nil || len(forks) != 0 {
+		t.Fatal("forks should be empty")
+	}
+}
+
+func TestHeadNumber(t *testing.T) {
+	s, close := newStorage(t)
+	defer close()
+
+	if err := s.WriteHeadHash(common.HexToHash("111")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteHeadNumber(big.NewInt(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	number, err := s.ReadHeadNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number.Uint64() != 10 {
+		t.Fatalf("expected head number 10 but found %d", number.Uint64())
+	}
+}
+
+func TestCorruptedData(t *testing.T) {
+	s, close := newStorage(t)
+	defer close()
+
+	hash := common.HexToHash("11")
+	if err := s.set(HEADER, hash.Bytes(), []byte{0x1, 0x2}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.ReadHeader(hash)
+	if _, ok := err.(*DecodeError); !ok {
+		t.Fatalf("expected a decode error but found %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	s, close := newStorage(t)
+	defer close()
+
+	if err := s.WriteHeadHash(common.HexToHash("111")); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := s.NewBatch()
+	if err := batch.WriteHeadHash(common.HexToHash("222")); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.WriteCanonicalHash(big.NewInt(1), common.HexToHash("222")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the batch sees its own writes
+	if hash, _ := batch.ReadHeadHash(); hash != common.HexToHash("222") {
+		t.Fatal("batch should read the staged head")
+	}
+
+	// the storage does not see them until commit
+	if hash, _ := s.ReadHeadHash(); hash != common.HexToHash("111") {
+		t.Fatal("storage should not read the staged head")
+	}
+	if _, err := s.ReadCanonicalHash(big.NewInt(1)); err != ErrNotFound {
+		t.Fatal("storage should not read the staged canonical hash")
+	}
+
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if hash, _ := s.ReadHeadHash(); hash != common.HexToHash("222") {
+		t.Fatal("storage should read the committed head")
+	}
+	if hash, _ := s.ReadCanonicalHash(big.NewInt(1)); hash != common.HexToHash("222") {
+		t.Fatal("storage should read the committed canonical hash")
+	}
+}
+
+func TestMemoryBatch(t *testing.T) {
+	s, err := NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := s.NewBatch()
+	if err := batch.WriteHeadHash(common.HexToHash("111")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ReadHeadHash(); err != ErrNotFound {
+		t.Fatal("head should be empty before commit")
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := s.ReadHeadHash(); hash != common.HexToHash("111") {
+		t.Fatal("head should be set after commit")
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	s, close := newStorage(t)
+	defer close()
+
+	if err := s.WriteCanonicalHash(big.NewInt(1), common.HexToHash("111")); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := s.NewBatch()
+	if err := batch.DeleteCanonicalHash(big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the batch sees its own deletes
+	if _, err := batch.ReadCanonicalHash(big.NewInt(1)); err != ErrNotFound {
+		t.Fatal("batch should not read the deleted canonical hash")
+	}
+	if hash, _ := s.ReadCanonicalHash(big.NewInt(1)); hash != common.HexToHash("111") {
+		t.Fatal("storage should read the canonical hash before commit")
+	}
+
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
 	}
-	if s.ReadHeadNumber() != nil {
-		t.Fatal("head hash should be nil")
+	if _, err := s.ReadCanonicalHash(big.NewInt(1)); err != ErrNotFound {
+		t.Fatal("storage should not read the deleted canonical hash")
 	}
 }
@@ -46,6 +46,32 @@ func newQueue() *queue {
 	return &queue{lock: &sync.Mutex{}}
 }
 
+// reset drops all the pending elements and starts the queue again after the head,
+// the target block of the sync is kept
+func (q *queue) reset(head *types.Header) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	back := q.back
+
+	q.front = q.newItem(head.Number.Uint64() + 1)
+	q.back = nil
+	q.head = head.Hash()
+
+	if back != nil {
+		q.addBack(back.block)
+	}
+}
+
+// hasElement returns true if the element with the given id is in the queue
+func (q *queue) hasElement(id uint32) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, err := q.findElement(id)
+	return err == nil
+}
+
 func (q *queue) addBack(block uint64) {
 	if q.back == nil {
 		q.back = q.newItem(block)
@@ -489,6 +515,21 @@ func (e *element) Completed() bool {
 	return e.headersStatus == completedX && e.bodiesStatus == completedX && e.receiptsStatus == completedX
 }
 
+// Blocks returns the blocks of the element with their bodies, the headers
+// without transactions and uncles have an empty body
+func (e *element) Blocks() []*types.Block {
+	blocks := make([]*types.Block, len(e.headers))
+	for i, h := range e.headers {
+		blocks[i] = types.NewBlockWithHeader(h)
+	}
+	for i, indx := range e.bodiesHeaders {
+		if i < len(e.bodies) {
+			blocks[indx] = blocks[indx].WithBody(e.bodies[i].Transactions, e.bodies[i].Uncles)
+		}
+	}
+	return blocks
+}
+
 func (e *element) Len() uint64 {
 	return e.next.block - e.block
 }
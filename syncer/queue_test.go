@@ -363,3 +363,60 @@ func TestQueueDeliverReceiptsAndBodies(t *testing.T) {
 // Test combined, what happenes after u receive the other one
 // Test to check the headers
 // check after you commit the data the head changes
+
+func TestElementBlocks(t *testing.T) {
+	headers, blocks, _ := blockchain.NewTestBodyChain(5)
+
+	// headers[1] and headers[3] have a body, the others are empty
+	empty := &types.Header{
+		ParentHash:  headers[1].Hash(),
+		Number:      headers[2].Number,
+		TxHash:      types.EmptyRootHash,
+		UncleHash:   types.EmptyUncleHash,
+		ReceiptHash: types.EmptyRootHash,
+	}
+
+	elem := &element{
+		headers:       []*types.Header{headers[1], empty, headers[3]},
+		bodies:        []*types.Body{blocks[1].Body(), blocks[3].Body()},
+		bodiesHeaders: []int{0, 2},
+	}
+
+	res := elem.Blocks()
+	if len(res) != 3 {
+		t.Fatalf("expected 3 blocks but found %d", len(res))
+	}
+	if res[0].Hash() != blocks[1].Hash() || len(res[0].Transactions()) != 1 {
+		t.Fatal("bad block 0")
+	}
+	if res[1].Hash() != empty.Hash() || len(res[1].Transactions()) != 0 {
+		t.Fatal("bad block 1")
+	}
+	if res[2].Hash() != blocks[3].Hash() || len(res[2].Transactions()) != 1 {
+		t.Fatal("bad block 2")
+	}
+}
+
+func TestQueueReset(t *testing.T) {
+	headers := blockchain.NewTestHeaderChain(1000)
+	q := newTestQueue(headers[0], 100)
+
+	job := dequeue(t, q)
+	if err := q.deliverHeaders(job.id, headers[1:50]); err != nil {
+		t.Fatal(err)
+	}
+
+	q.reset(headers[10])
+	if q.hasElement(job.id) {
+		t.Fatal("the jobs before the reset should be dropped")
+	}
+
+	// the sync starts again after the head and keeps the target
+	job = dequeue(t, q)
+	if !reflect.DeepEqual(job.payload, &HeadersJob{11, 100}) {
+		t.Fatal("bad headers job after the reset")
+	}
+	if !q.hasElement(job.id) {
+		t.Fatal("the new job should be in the queue")
+	}
+}
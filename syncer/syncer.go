@@ -2,28 +2,36 @@ package syncer
 
 import (
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"os"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/umbracle/minimal/network"
 	"github.com/umbracle/minimal/protocol/ethereum"
+	"github.com/umbracle/minimal/storage"
 )
 
 type Config struct {
 	MaxRequests int
 	NumWorkers  int
+
+	// Logger is a logger for operator messages.
+	Logger *log.Logger
 }
 
 func DefaultConfig() *Config {
 	c := &Config{
 		MaxRequests: 5,
 		NumWorkers:  2,
+		Logger:      log.New(os.Stderr, "", log.LstdFlags),
 	}
 	return c
 }
@@ -32,8 +40,10 @@ func DefaultConfig() *Config {
 type Blockchain interface {
 	Header() *types.Header
 	Genesis() *types.Header
-	WriteHeaders(headers []*types.Header) error
-	GetHeaderByNumber(number *big.Int) *types.Header
+	WriteBlocks(blocks []*types.Block) error
+	GetHeaderByNumber(number *big.Int) (*types.Header, error)
+	GetTD(hash common.Hash) (*big.Int, error)
+	SetHead(number uint64) error
 }
 
 type Peer struct {
@@ -60,6 +70,7 @@ func newPeer(id string, peer *network.Peer) *Peer {
 type Syncer struct {
 	NetworkID uint64
 	config    *Config
+	logger    *log.Logger
 
 	blockchain Blockchain
 	queue      *queue
@@ -78,6 +89,7 @@ type Syncer struct {
 func NewSyncer(networkID uint64, blockchain Blockchain, config *Config) (*Syncer, error) {
 	s := &Syncer{
 		config:      config,
+		logger:      config.Logger,
 		NetworkID:   networkID,
 		peers:       map[string]*Peer{},
 		peersLock:   sync.Mutex{},
@@ -91,8 +103,7 @@ func NewSyncer(networkID uint64, blockchain Blockchain, config *Config) (*Syncer
 
 	header := blockchain.Header()
 
-	s.queue.front = s.queue.newItem(header.Number.Uint64() + 1)
-	s.queue.head = header.Hash()
+	s.queue.reset(header)
 
 	// Maybe start s.back as s.front and calls to dequeue would block
 
@@ -144,8 +155,14 @@ func (s *Syncer) AddNode(peer *network.Peer) {
 	// fmt.Printf("Ancestor: %d\n", ancestor.Number.Uint64())
 
 	// check that the difficulty is higher than ours
-	if peer.HeaderDiff().Cmp(s.blockchain.Header().Difficulty) < 0 {
-		fmt.Printf("Difficulty %s is lower than ours %s, skip it\n", peer.HeaderDiff().String(), s.blockchain.Header().Difficulty.String())
+	td, err := s.blockchain.GetTD(s.blockchain.Header().Hash())
+	if err != nil {
+		s.logger.Printf("[ERR] syncer: failed to read the local total difficulty: %v", err)
+		return
+	}
+	if peer.HeaderDiff().Cmp(td) < 0 {
+		s.logger.Printf("[INFO] syncer: difficulty of %s (%s) is lower than ours (%s), skip it", p.pretty, peer.HeaderDiff().String(), td.String())
+		return
 	}
 
 	fmt.Println("Difficulty higher than ours")
@@ -345,6 +362,12 @@ func (s *Syncer) deliver(peer string, context string, id uint32, data interface{
 	s.deliverLock.Lock()
 	defer s.deliverLock.Unlock()
 
+	// the queue was reset while the job was in flight
+	if !s.queue.hasElement(id) {
+		s.logger.Printf("[INFO] syncer: drop %s of job %d, it is not in the queue anymore", context, id)
+		return
+	}
+
 	if err != nil {
 		// log
 		// TODO, we need to set here the thing that was not deliver as waiting to be dequeued again
@@ -403,31 +426,48 @@ func (s *Syncer) deliver(peer string, context string, id uint32, data interface{
 		fmt.Printf("Commit data: %d\n", len(data)*maxElements)
 		fmt.Printf("New Head: %s\n", s.queue.head.String())
 
-		// write the headers
-		for indx, elem := range data {
-			if err := s.blockchain.WriteHeaders(elem.headers); err != nil {
-				fmt.Printf("Failed to write headers batch: %v", err)
-
+		// execute and write the blocks
+		for _, elem := range data {
+			if err := s.blockchain.WriteBlocks(elem.Blocks()); err != nil {
 				first, last := elem.headers[0], elem.headers[len(elem.headers)-1]
+				s.logger.Printf("[ERR] syncer: failed to write the blocks %s to %s: %v", first.Number.String(), last.Number.String(), err)
 
-				fmt.Printf("Error at step: %d\n", indx)
-				fmt.Printf("First block we have is %s (%s) %s (%s)\n", first.Hash().String(), first.Number.String(), last.Hash().String(), last.Number.String())
-
-				panic("")
+				// the next batches build on top of this one, drop
+				// them and sync again from the head of the chain
+				s.queue.reset(s.blockchain.Header())
 				return
 			}
 		}
 	}
 }
 
+// SetHead rewinds the chain to the given number and restarts the sync from there.
+// It holds the deliver lock so that the workers do not commit data meanwhile, the
+// jobs in flight are dropped once they are delivered.
+func (s *Syncer) SetHead(number uint64) error {
+	s.deliverLock.Lock()
+	defer s.deliverLock.Unlock()
+
+	if err := s.blockchain.SetHead(number); err != nil {
+		return err
+	}
+	s.queue.reset(s.blockchain.Header())
+	return nil
+}
+
 // GetStatus returns the current ethereum status
 func (s *Syncer) GetStatus() (*ethereum.Status, error) {
 	header := s.blockchain.Header()
 
+	td, err := s.blockchain.GetTD(header.Hash())
+	if err != nil {
+		return nil, err
+	}
+
 	status := &ethereum.Status{
 		ProtocolVersion: 63,
 		NetworkID:       s.NetworkID,
-		TD:              header.Difficulty,
+		TD:              td,
 		CurrentBlock:    header.Hash(),
 		GenesisBlock:    s.blockchain.Genesis().Hash(),
 	}
@@ -504,9 +544,11 @@ func (s *Syncer) FindCommonAncestor(peer *ethereum.Ethereum) (*types.Header, err
 				return nil, fmt.Errorf("header response number not correct, asked %d but retrieved %d", m, header.Number.Uint64())
 			}
 
-			expectedHeader := s.blockchain.GetHeaderByNumber(big.NewInt(int64(m)))
-			if expectedHeader == nil {
-				return nil, fmt.Errorf("cannot find the header in local chain")
+			expectedHeader, err := s.blockchain.GetHeaderByNumber(big.NewInt(int64(m)))
+			if err == storage.ErrNotFound {
+				return nil, fmt.Errorf("cannot find the header %d in local chain", m)
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to read the header %d from local chain: %v", m, err)
 			}
 
 			if expectedHeader.Hash() == header.Hash() {
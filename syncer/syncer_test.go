@@ -1,7 +1,11 @@
 package syncer
 
 import (
+	"errors"
+	"io/ioutil"
+	"log"
 	"math/big"
+	"reflect"
 	"testing"
 	"time"
 
@@ -222,3 +226,41 @@ func TestDequeuPeerWithAwake(t *testing.T) {
 		t.Fatal("it did not wake up")
 	}
 }
+
+// failingBlockchain fails to write any block
+type failingBlockchain struct {
+	*blockchain.Blockchain
+}
+
+func (f *failingBlockchain) WriteBlocks(blocks []*types.Block) error {
+	return errors.New("bad block")
+}
+
+func TestDeliverWriteBlocksFails(t *testing.T) {
+	headers := blockchain.NewTestHeaderChain(1000)
+
+	// b0 with only the genesis
+	b0 := blockchain.NewTestBlockchain(t, headers[:1])
+
+	config := DefaultConfig()
+	config.Logger = log.New(ioutil.Discard, "", 0)
+
+	s, err := NewSyncer(1, &failingBlockchain{b0}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.updateChain(1000)
+
+	// complete enough batches to commit them
+	for i := 0; i < 3; i++ {
+		job := dequeue(t, s.queue)
+		from := job.payload.(*HeadersJob).block
+		s.deliver("a", "headers", job.id, headers[from:from+maxElements], nil)
+	}
+
+	// the failed batches are dropped and the sync starts again from the head
+	job := dequeue(t, s.queue)
+	if !reflect.DeepEqual(job.payload, &HeadersJob{1, 100}) {
+		t.Fatal("bad headers job after the failed write")
+	}
+}
@@ -6,9 +6,15 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/umbracle/minimal/consensus/ethash"
 
 	"github.com/umbracle/minimal/blockchain"
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/consensus"
 	"github.com/umbracle/minimal/storage"
 )
@@ -40,13 +46,24 @@ type BlockchainTest struct {
 	Pre  stateSnapshop `json:"pre"`
 }
 
-// NOTE, only checks the last header, it needs to check the state root too.
+func (b *block) decode() (*types.Block, error) {
+	data, err := hexutil.Decode(b.Rlp)
+	if err != nil {
+		return nil, err
+	}
+	var block types.Block
+	if err := rlp.DecodeBytes(data, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
 
 func testBlockChainCase(t *testing.T, c *BlockchainTest) {
-	_, ok := Forks[c.Network]
+	forks, ok := Forks[c.Network]
 	if !ok {
 		t.Fatalf("config %s not found", c.Network)
 	}
+	params := &chain.Params{Forks: forks, ChainID: 1}
 
 	s, err := storage.NewMemoryStorage(nil)
 	if err != nil {
@@ -57,21 +74,30 @@ func testBlockChainCase(t *testing.T, c *BlockchainTest) {
 	if c.SealEngine == "NoProof" {
 		engine = &consensus.NoProof{}
 	} else {
-		engine = ethash.NewEthHash(&consensus.ChainConfig{})
+		engine = ethash.NewEthHash(params, nil)
 	}
 
-	b := blockchain.NewBlockchain(s, engine)
+	st := state.NewDatabase(ethdb.NewMemDatabase())
+	if root := writeState(t, st, c.Pre); root != c.Genesis.header.Root {
+		t.Fatalf("genesis root mismatch: found %s but expected %s", root.String(), c.Genesis.header.Root.String())
+	}
+
+	b := blockchain.NewBlockchain(s, st, engine, params)
 	if err := b.WriteGenesis(c.Genesis.header); err != nil {
 		t.Fatal(err)
 	}
 
-	// Write blocks
+	// Write blocks, the ones without header are expected to fail
 	for _, block := range c.Blocks {
 		if block.Header == nil {
 			continue
 		}
 
-		if err := b.WriteHeader(block.Header.header); err != nil {
+		blk, err := block.decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.WriteBlocks([]*types.Block{blk}); err != nil {
 			t.Fatal(err)
 		}
 	}
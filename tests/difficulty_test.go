@@ -9,14 +9,17 @@ import (
 
 	"github.com/ethereum/go-ethereum/core/types"
 
-	"github.com/umbracle/minimal/consensus"
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/consensus/ethash"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
-const difficultyTests = "BasicTests"
+const (
+	difficultyTests     = "BasicTests"
+	difficultyForkTests = "DifficultyTests"
+)
 
 type difficultyCase struct {
 	ParentTimestamp    *big.Int
@@ -63,15 +66,15 @@ func (d *difficultyCase) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
-var testnetConfig = &consensus.ChainConfig{
-	HomesteadBlock:      consensus.NewFork(0),
-	ByzantiumBlock:      consensus.NewFork(1700000),
-	ConstantinopleBlock: consensus.NewFork(4230000),
+var testnetConfig = &chain.Forks{
+	Homestead:      chain.NewFork(0),
+	Byzantium:      chain.NewFork(1700000),
+	Constantinople: chain.NewFork(4230000),
 }
 
-var mainnetConfig = &consensus.ChainConfig{
-	HomesteadBlock: consensus.NewFork(1150000),
-	ByzantiumBlock: consensus.NewFork(4370000),
+var mainnetConfig = &chain.Forks{
+	Homestead: chain.NewFork(1150000),
+	Byzantium: chain.NewFork(4370000),
 }
 
 func TestDifficultyRopsten(t *testing.T) {
@@ -91,24 +94,165 @@ func TestDifficultyMainnet1(t *testing.T) {
 }
 
 func TestDifficultyHomestead(t *testing.T) {
-	testDifficultyCase(t, "difficultyHomestead.json", &consensus.ChainConfig{
-		HomesteadBlock: consensus.NewFork(0),
+	testDifficultyCase(t, "difficultyHomestead.json", &chain.Forks{
+		Homestead: chain.NewFork(0),
 	})
 }
 
 func TestDifficultyByzantium(t *testing.T) {
-	testDifficultyCase(t, "difficultyByzantium.json", &consensus.ChainConfig{
-		ByzantiumBlock: consensus.NewFork(0),
+	testDifficultyCase(t, "difficultyByzantium.json", &chain.Forks{
+		Byzantium: chain.NewFork(0),
 	})
 }
 
 func TestDifficultyConstantinople(t *testing.T) {
-	testDifficultyCase(t, "difficultyConstantinople.json", &consensus.ChainConfig{
-		ConstantinopleBlock: consensus.NewFork(0),
+	testDifficultyCase(t, "difficultyConstantinople.json", &chain.Forks{
+		Constantinople: chain.NewFork(0),
 	})
 }
 
-func testDifficultyCase(t *testing.T, file string, config *consensus.ChainConfig) {
+var muirGlacierConfig = &chain.Forks{
+	Homestead:      chain.NewFork(0),
+	Byzantium:      chain.NewFork(0),
+	Constantinople: chain.NewFork(0),
+	Petersburg:     chain.NewFork(0),
+	Istanbul:       chain.NewFork(0),
+	MuirGlacier:    chain.NewFork(0),
+}
+
+func TestDifficultyEIP2384(t *testing.T) {
+	testDifficultyCase(t, "difficultyEIP2384.json", muirGlacierConfig)
+}
+
+func TestDifficultyEIP2384Random(t *testing.T) {
+	testDifficultyCase(t, "difficultyEIP2384_random.json", muirGlacierConfig)
+}
+
+func TestDifficultyEIP2384RandomTo20M(t *testing.T) {
+	testDifficultyCase(t, "difficultyEIP2384_random_to20M.json", muirGlacierConfig)
+}
+
+// difficultyForks are the forks of the fixtures in DifficultyTests,
+// each of them with all the previous forks active
+var difficultyForks = map[string]*chain.Forks{
+	"Frontier":  {},
+	"Homestead": {Homestead: chain.NewFork(0)},
+	"Byzantium": {
+		Homestead: chain.NewFork(0),
+		Byzantium: chain.NewFork(0),
+	},
+	"Constantinople": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+	},
+	"ConstantinopleFix": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+	},
+	"Istanbul": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(0),
+	},
+	"MuirGlacier": muirGlacierConfig,
+	"London": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(0),
+		MuirGlacier:    chain.NewFork(0),
+		London:         chain.NewFork(0),
+	},
+	"ArrowGlacier": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(0),
+		MuirGlacier:    chain.NewFork(0),
+		London:         chain.NewFork(0),
+		ArrowGlacier:   chain.NewFork(0),
+	},
+	"GrayGlacier": {
+		Homestead:      chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(0),
+		MuirGlacier:    chain.NewFork(0),
+		London:         chain.NewFork(0),
+		ArrowGlacier:   chain.NewFork(0),
+		GrayGlacier:    chain.NewFork(0),
+	},
+}
+
+func TestDifficultyArrowGlacier(t *testing.T) {
+	testDifficultyForkCase(t, "dfArrowGlacier")
+}
+
+func TestDifficultyGrayGlacier(t *testing.T) {
+	testDifficultyForkCase(t, "dfGrayGlacier")
+}
+
+func TestDifficultyEIP2384Forks(t *testing.T) {
+	testDifficultyForkCase(t, "dfEIP2384")
+}
+
+func TestDifficultyConstantinopleForks(t *testing.T) {
+	testDifficultyForkCase(t, "dfConstantinople")
+}
+
+// testDifficultyForkCase runs the fixtures in the directory. Each fixture
+// groups the cases by fork, the forks not supported are reported as skipped.
+func testDifficultyForkCase(t *testing.T, dir string) {
+	files, err := filepath.Glob(filepath.Join(TESTS, difficultyForkTests, dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no fixtures found in %s", dir)
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fixtures map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fixture := range fixtures {
+			for fork, raw := range fixture {
+				config, ok := difficultyForks[fork]
+				if !ok {
+					t.Run(fork, func(t *testing.T) {
+						t.Skipf("fork %s is not supported", fork)
+					})
+					continue
+				}
+
+				var cases map[string]*difficultyCase
+				if err := json.Unmarshal(raw, &cases); err != nil {
+					t.Fatal(err)
+				}
+				t.Run(fork, func(t *testing.T) {
+					runDifficultyCases(t, cases, config)
+				})
+			}
+		}
+	}
+}
+
+func testDifficultyCase(t *testing.T, file string, config *chain.Forks) {
 	data, err := ioutil.ReadFile(filepath.Join(TESTS, difficultyTests, file))
 	if err != nil {
 		t.Fatal(err)
@@ -118,8 +262,11 @@ func testDifficultyCase(t *testing.T, file string, config *consensus.ChainConfig
 	if err := json.Unmarshal(data, &cases); err != nil {
 		t.Fatal(err)
 	}
+	runDifficultyCases(t, cases, config)
+}
 
-	engine := ethash.NewEthHash(config)
+func runDifficultyCases(t *testing.T, cases map[string]*difficultyCase, config *chain.Forks) {
+	engine := ethash.NewEthHash(&chain.Params{Forks: config}, nil)
 	for name, i := range cases {
 		t.Run(name, func(tt *testing.T) {
 			parentNumber := i.CurrentBlockNumber
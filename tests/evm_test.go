@@ -12,12 +12,24 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/evm"
 )
 
 var vmTests = "VMTests"
 
+// the vm tests run with the fork schedule of mainnet
+var mainnetForks = &chain.Forks{
+	Homestead:      chain.NewFork(1150000),
+	DAO:            chain.NewFork(1920000),
+	EIP150:         chain.NewFork(2463000),
+	EIP155:         chain.NewFork(2675000),
+	EIP158:         chain.NewFork(2675000),
+	Byzantium:      chain.NewFork(4370000),
+	Constantinople: chain.NewFork(7280000),
+	Petersburg:     chain.NewFork(7280000),
+}
+
 type VMCase struct {
 	Info *info `json:"_info"`
 	Env  *env  `json:"env"`
@@ -53,7 +65,7 @@ func testVMCase(t *testing.T, name string, c *VMCase) {
 
 	state := buildState(t, c.Pre)
 
-	e := evm.NewEVM(state, env, params.MainnetChainConfig, params.GasTableHomestead, vmTestBlockHash)
+	e := evm.NewEVM(state, env, mainnetForks.At(env.Number.Uint64()), *chain.GasTableHomestead, vmTestBlockHash)
 	e.CanTransfer = canTransfer
 	e.Transfer = transfer
 
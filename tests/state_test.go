@@ -2,13 +2,19 @@ package tests
 
 import (
 	"encoding/json"
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/umbracle/minimal/evm"
 	transition "github.com/umbracle/minimal/state"
 )
 
@@ -22,13 +28,32 @@ type stateCase struct {
 	Transaction *stTransaction       `json:"transaction"`
 }
 
+// traceDir is the directory where the EIP-3155 traces of the failing cases are written
+var traceDir = flag.String("trace", "", "directory to write the traces of the failing state tests")
+
 func RunSpecificTest(t *testing.T, c stateCase, id, fork string, index int, p postEntry) {
-	config, ok := Forks[fork]
+	state, root := runStateCase(t, c, fork, p, nil)
+
+	if root != p.Root {
+		if *traceDir != "" {
+			traceStateCase(t, c, id, fork, index, p)
+		}
+		t.Fatalf("root mismatch: expected %s but found %s", p.Root, root)
+	}
+
+	if logs := rlpHash(state.Logs()); logs != common.Hash(p.Logs) {
+		t.Fatalf("logs mismatch: expected %s but found %s", p.Logs, logs.String())
+	}
+}
+
+func runStateCase(t *testing.T, c stateCase, fork string, p postEntry, tracer evm.Tracer) (*state.StateDB, common.Hash) {
+	forks, ok := Forks[fork]
 	if !ok {
 		t.Fatalf("config %s not found", fork)
 	}
 
 	env := c.Env.ToEnv(t)
+	config := forks.At(env.Number.Uint64())
 
 	msg, err := c.Transaction.At(p.Indexes)
 	if err != nil {
@@ -48,6 +73,7 @@ func RunSpecificTest(t *testing.T, c stateCase, id, fork string, index int, p po
 		Msg:     msg,
 		Gp:      gaspool,
 		GetHash: vmTestBlockHash,
+		Tracer:  tracer,
 	}
 
 	snapshot := state.Snapshot()
@@ -56,15 +82,21 @@ func RunSpecificTest(t *testing.T, c stateCase, id, fork string, index int, p po
 	}
 
 	state.AddBalance(env.Coinbase, new(big.Int))
-	root := state.IntermediateRoot(config.IsEIP158(env.Number))
+	return state, state.IntermediateRoot(config.EIP158)
+}
 
-	if root != p.Root {
-		t.Fatalf("root mismatch: expected %s but found %s", p.Root, root)
-	}
+// traceStateCase runs the case again and writes its trace in the trace directory
+func traceStateCase(t *testing.T, c stateCase, id, fork string, index int, p postEntry) {
+	filename := filepath.Join(*traceDir, fmt.Sprintf("%s-%s-%d.jsonl", id, fork, index))
 
-	if logs := rlpHash(state.Logs()); logs != common.Hash(p.Logs) {
-		t.Fatalf("logs mismatch: expected %s but found %s", p.Logs, logs.String())
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer f.Close()
+
+	runStateCase(t, c, fork, p, evm.NewJSONTracer(f))
+	t.Logf("trace written to %s", filename)
 }
 
 func TestState(t *testing.T) {
@@ -115,10 +147,10 @@ func TestState(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				for _, i := range c {
+				for name, i := range c {
 					for fork, f := range i.Post {
 						for indx, e := range f {
-							RunSpecificTest(t, i, "id", fork, indx, e)
+							RunSpecificTest(t, i, name, fork, indx, e)
 						}
 					}
 				}
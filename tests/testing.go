@@ -15,7 +15,6 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/ethdb"
-	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -23,6 +22,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/umbracle/minimal/chain"
 	"github.com/umbracle/minimal/evm"
 )
 
@@ -132,6 +132,7 @@ func (e *env) ToEnv(t *testing.T) *evm.Env {
 		GasLimit:   stringToBigIntT(t, e.GasLimit),
 		Number:     stringToBigIntT(t, e.Number),
 		Timestamp:  stringToBigIntT(t, e.Timestamp),
+		ChainID:    big.NewInt(1),
 	}
 }
 
@@ -201,6 +202,17 @@ func (e *exec) UnmarshalJSON(input []byte) error {
 
 func buildState(t *testing.T, pre stateSnapshop) *state.StateDB {
 	db := state.NewDatabase(ethdb.NewMemDatabase())
+	root := writeState(t, db, pre)
+
+	statedb, err := state.New(root, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb
+}
+
+// writeState commits the accounts to the state database and returns the root
+func writeState(t *testing.T, db state.Database, pre stateSnapshop) common.Hash {
 	statedb, err := state.New(common.Hash{}, db)
 	if err != nil {
 		t.Fatal(err)
@@ -223,11 +235,10 @@ func buildState(t *testing.T, pre stateSnapshop) *state.StateDB {
 	if err != nil {
 		t.Fatalf("failed to commit pre state: %v", err)
 	}
-	statedb, err = state.New(root, db)
-	if err != nil {
-		t.Fatal(err)
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to write pre state: %v", err)
 	}
-	return statedb
+	return root
 }
 
 func rlpHash(x interface{}) (h common.Hash) {
@@ -357,67 +368,91 @@ func (t *stTransaction) UnmarshalJSON(input []byte) error {
 
 // forks
 
-var Forks = map[string]*params.ChainConfig{
-	"Frontier": {
-		ChainID: big.NewInt(1),
-	},
+var Forks = map[string]*chain.Forks{
+	"Frontier": {},
 	"Homestead": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
+		Homestead: chain.NewFork(0),
 	},
 	"EIP150": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		EIP150Block:    big.NewInt(0),
+		Homestead: chain.NewFork(0),
+		EIP150:    chain.NewFork(0),
 	},
 	"EIP158": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		EIP150Block:    big.NewInt(0),
-		EIP155Block:    big.NewInt(0),
-		EIP158Block:    big.NewInt(0),
+		Homestead: chain.NewFork(0),
+		EIP150:    chain.NewFork(0),
+		EIP155:    chain.NewFork(0),
+		EIP158:    chain.NewFork(0),
 	},
 	"Byzantium": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		EIP150Block:    big.NewInt(0),
-		EIP155Block:    big.NewInt(0),
-		EIP158Block:    big.NewInt(0),
-		DAOForkBlock:   big.NewInt(0),
-		ByzantiumBlock: big.NewInt(0),
+		Homestead: chain.NewFork(0),
+		EIP150:    chain.NewFork(0),
+		EIP155:    chain.NewFork(0),
+		EIP158:    chain.NewFork(0),
+		Byzantium: chain.NewFork(0),
 	},
 	"Constantinople": {
-		ChainID:             big.NewInt(1),
-		HomesteadBlock:      big.NewInt(0),
-		EIP150Block:         big.NewInt(0),
-		EIP155Block:         big.NewInt(0),
-		EIP158Block:         big.NewInt(0),
-		DAOForkBlock:        big.NewInt(0),
-		ByzantiumBlock:      big.NewInt(0),
-		ConstantinopleBlock: big.NewInt(0),
+		Homestead:      chain.NewFork(0),
+		EIP150:         chain.NewFork(0),
+		EIP155:         chain.NewFork(0),
+		EIP158:         chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+	},
+	"ConstantinopleFix": {
+		Homestead:      chain.NewFork(0),
+		EIP150:         chain.NewFork(0),
+		EIP155:         chain.NewFork(0),
+		EIP158:         chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+	},
+	"Istanbul": {
+		Homestead:      chain.NewFork(0),
+		EIP150:         chain.NewFork(0),
+		EIP155:         chain.NewFork(0),
+		EIP158:         chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(0),
 	},
 	"FrontierToHomesteadAt5": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(5),
+		Homestead: chain.NewFork(5),
 	},
 	"HomesteadToEIP150At5": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		EIP150Block:    big.NewInt(5),
+		Homestead: chain.NewFork(0),
+		EIP150:    chain.NewFork(5),
 	},
 	"HomesteadToDaoAt5": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		DAOForkBlock:   big.NewInt(5),
-		DAOForkSupport: true,
+		Homestead: chain.NewFork(0),
+		DAO:       chain.NewFork(5),
 	},
 	"EIP158ToByzantiumAt5": {
-		ChainID:        big.NewInt(1),
-		HomesteadBlock: big.NewInt(0),
-		EIP150Block:    big.NewInt(0),
-		EIP155Block:    big.NewInt(0),
-		EIP158Block:    big.NewInt(0),
-		ByzantiumBlock: big.NewInt(5),
+		Homestead: chain.NewFork(0),
+		EIP150:    chain.NewFork(0),
+		EIP155:    chain.NewFork(0),
+		EIP158:    chain.NewFork(0),
+		Byzantium: chain.NewFork(5),
+	},
+	"ByzantiumToConstantinopleFixAt5": {
+		Homestead:      chain.NewFork(0),
+		EIP150:         chain.NewFork(0),
+		EIP155:         chain.NewFork(0),
+		EIP158:         chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(5),
+		Petersburg:     chain.NewFork(5),
+	},
+	"ConstantinopleFixToIstanbulAt5": {
+		Homestead:      chain.NewFork(0),
+		EIP150:         chain.NewFork(0),
+		EIP155:         chain.NewFork(0),
+		EIP158:         chain.NewFork(0),
+		Byzantium:      chain.NewFork(0),
+		Constantinople: chain.NewFork(0),
+		Petersburg:     chain.NewFork(0),
+		Istanbul:       chain.NewFork(5),
 	},
 }
 